@@ -0,0 +1,95 @@
+// cmd/ezexport/main.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"github.com/ze674/EZLine/internal/config"
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/export"
+	"github.com/ze674/EZLine/internal/services"
+	"log"
+	"os"
+)
+
+// Выгрузка агрегации задания из базы линии в файл:
+// go run ./cmd/ezexport -task 3 -o codenames.xml
+// go run ./cmd/ezexport -task 3 -format gismt -o aggregation.json
+func main() {
+	configPath := flag.String("config", "config.json", "файл настроек линии")
+	taskID := flag.Int("task", 0, "ID задания")
+	format := flag.String("format", "codenames", "формат: codenames - XML CodeNamesSerial, gismt - документ агрегации ГИС МТ")
+	outID := flag.String("out-id", "", "cOutID файла CodeNamesSerial, по умолчанию codenames_out_id из настроек")
+	inn := flag.String("inn", "", "ИНН участника оборота для ГИС МТ, по умолчанию gismt_participant_inn из настроек")
+	serialLength := flag.Int("serial-length", 0, "длина серийного номера в кодах без GS, по умолчанию gismt_serial_length из настроек")
+	outputPath := flag.String("o", "", "выходной файл, по умолчанию стандартный вывод")
+	flag.Parse()
+
+	if *taskID <= 0 || (*format != "codenames" && *format != "gismt") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	}
+	if *outID == "" {
+		*outID = cfg.CodeNamesOutID
+	}
+	if *inn == "" {
+		*inn = cfg.GISMTParticipantINN
+	}
+	if *serialLength == 0 {
+		*serialLength = cfg.GISMTSerialLength
+	}
+
+	if err := database.Connect(cfg.DbPath); err != nil {
+		log.Fatalf("Ошибка подключения к базе данных: %v", err)
+	}
+	defer database.Close()
+
+	exportService := services.NewExportService()
+
+	// Документ ГИС МТ проверяется до записи
+	var document export.GISMTAggregationDocument
+	if *format == "gismt" {
+		document, err = exportService.GISMTAggregation(*taskID, *inn, *serialLength)
+		if err != nil {
+			log.Fatalf("Ошибка выгрузки документа агрегации: %v", err)
+		}
+	}
+
+	// Файл собирается в памяти и создается только после успешной выгрузки, чтобы не оставить неполный файл
+	var output bytes.Buffer
+	if *format == "gismt" {
+		if err := export.WriteGISMTAggregation(&output, document); err != nil {
+			log.Fatalf("Ошибка записи документа агрегации: %v", err)
+		}
+		writeOutput(*outputPath, output.Bytes())
+		log.Printf("Документ агрегации задания %d выгружен, агрегатов: %d", *taskID, len(document.AggregationUnits))
+		return
+	}
+
+	count, err := exportService.WriteCodeNamesSerial(&output, *taskID, *outID)
+	if err != nil {
+		log.Fatalf("Ошибка выгрузки агрегации: %v", err)
+	}
+	writeOutput(*outputPath, output.Bytes())
+
+	log.Printf("Агрегация задания %d выгружена, кодов: %d", *taskID, count)
+}
+
+// writeOutput записывает выгрузку в файл или, если файл не задан, в стандартный вывод
+func writeOutput(path string, data []byte) {
+	if path == "" {
+		if _, err := os.Stdout.Write(data); err != nil {
+			log.Fatalf("Ошибка записи выгрузки: %v", err)
+		}
+		return
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Fatalf("Ошибка записи выходного файла: %v", err)
+	}
+}
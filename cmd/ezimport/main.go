@@ -0,0 +1,93 @@
+// cmd/ezimport/main.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ze674/EZLine/internal/config"
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/importer"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/services"
+	"log"
+	"os"
+)
+
+// Импорт журналов агрегации старого формата (input.txt) в задание:
+// go run ./cmd/ezimport -task 3 -dry-run input.txt
+// При -dry-run база открывается только для чтения и не обновляется миграциями
+func main() {
+	configPath := flag.String("config", "config.json", "файл настроек линии")
+	taskID := flag.Int("task", 0, "ID задания, в которое импортируются короба")
+	dryRun := flag.Bool("dry-run", false, "только проверить журналы, не изменяя базу")
+	serialLength := flag.Int("serial-length", 0, "длина серийного номера в кодах без GS, по умолчанию gismt_serial_length из настроек")
+	flag.Parse()
+
+	if *taskID <= 0 || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Использование: ezimport -task ID [-dry-run] журнал.txt...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	}
+	if *serialLength == 0 {
+		*serialLength = cfg.GISMTSerialLength
+	}
+
+	// Разбираем все журналы до записи, чтобы найти повторы между файлами
+	var boxes []models.LegacyBox
+	var problems []models.ImportProblem
+	for _, path := range flag.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Ошибка открытия журнала: %v", err)
+		}
+
+		fileBoxes, fileProblems, err := importer.ParseLegacyLog(file, path, *serialLength)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Ошибка чтения журнала %s: %v", path, err)
+		}
+
+		boxes = append(boxes, fileBoxes...)
+		problems = append(problems, fileProblems...)
+	}
+
+	connect := database.Connect
+	if *dryRun {
+		connect = database.OpenReadOnly
+	}
+	if err := connect(cfg.DbPath); err != nil {
+		log.Fatalf("Ошибка подключения к базе данных: %v", err)
+	}
+	defer database.Close()
+
+	// Импортированные короба получают SSCC так же, как закрытые на линии
+	sscc, err := cfg.SSCCPrefix()
+	if err != nil {
+		log.Fatalf("Ошибка настройки SSCC: %v", err)
+	}
+
+	importService := services.NewImportService()
+	importService.UseSSCC(sscc)
+
+	result, err := importService.ImportLegacyBoxes(*taskID, boxes, *dryRun)
+	if err != nil {
+		log.Fatalf("Ошибка импорта: %v", err)
+	}
+
+	for _, problem := range append(problems, result.Problems...) {
+		fmt.Println(problem)
+	}
+
+	if result.DryRun {
+		log.Printf("Проверка завершена, база не изменена. Будет импортировано в задание %d: коробов %d, кодов %d, пропущено строк: %d",
+			*taskID, result.Boxes, result.Items, len(problems)+len(result.Problems))
+	} else {
+		log.Printf("Импорт в задание %d завершен: коробов %d, кодов %d, пропущено строк: %d",
+			*taskID, result.Boxes, result.Items, len(problems)+len(result.Problems))
+	}
+}
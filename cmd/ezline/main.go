@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/ze674/EZLine/internal/adapters"
@@ -10,13 +11,16 @@ import (
 	"github.com/ze674/EZLine/internal/database"
 	"github.com/ze674/EZLine/internal/handlers"
 	"github.com/ze674/EZLine/internal/processors"
+	"github.com/ze674/EZLine/internal/repository"
 	"github.com/ze674/EZLine/internal/services"
 	"log"
 	"net/http"
-	"strconv"
 	"time"
 )
 
+// Версия EZLine, задается при сборке: go build -ldflags "-X main.version=1.2.0" ./cmd/ezline
+var version = "dev"
+
 func main() {
 	// Загружаем конфигурацию
 	cfg, err := config.LoadConfig("config.json")
@@ -32,24 +36,94 @@ func main() {
 	defer database.Close()
 
 	factoryClient := api.NewFactoryClient(cfg.FactoryURL)
+	if cfg.FactoryTimeout > 0 {
+		factoryClient.RequestTimeout = time.Duration(cfg.FactoryTimeout) * time.Millisecond
+	}
+	if cfg.FactoryRetries > 0 {
+		factoryClient.MaxRetries = cfg.FactoryRetries
+	}
+	if err := configureFactoryAuth(factoryClient, cfg); err != nil {
+		log.Fatalf("Ошибка настройки доступа к EZFactory: %v", err)
+	}
+
+	// Исходящие вызовы EZFactory отправляются в фоне, линия продолжает работать без связи с сервером
+	outboxRetry := time.Duration(cfg.OutboxRetry) * time.Millisecond
+	if outboxRetry <= 0 {
+		outboxRetry = 5 * time.Second
+	}
+	outboxMaxAttempts := cfg.OutboxMaxAttempts
+	if outboxMaxAttempts <= 0 {
+		outboxMaxAttempts = 20
+	}
+	outboxSender := services.NewOutboxSender(outboxRetry, outboxMaxAttempts)
 
-	taskService := services.NewTaskService(factoryClient, cfg.LineID)
+	codeService := services.NewMarkingCodeService(factoryClient, outboxSender, cfg.CodePageSize, cfg.GISMTSerialLength)
+	taskService := services.NewTaskService(factoryClient, outboxSender, codeService, cfg.LineID)
+	services.NewAggregationUploader(factoryClient, outboxSender, cfg.UploadChunk)
+	go outboxSender.Run(context.Background())
 
-	camera := adapters.NewScanner(cfg.ScannerAddress, cfg.ScanCommand)
-	pusherReg, err := strconv.Atoi(cfg.PusherRegister)
+	// Восстанавливаем активное задание после перезапуска.
+	// Несобранный короб задания восстанавливается процессором при запуске
+	if err := taskService.LoadActiveTask(); err != nil {
+		log.Printf("Ошибка загрузки активного задания: %v", err)
+	}
+
+	// Общий кэш использованных кодов для процессоров и редактирования контейнеров
+	uniqueValidator := services.NewCodeUniquenessValidator()
+
+	// Принтер, считыватель этикеток и кэш кодов общие для линии и ручной станции,
+	// поэтому одновременно ими пользуется только один процессор
+	equipment := processors.NewEquipmentLock()
+
+	printer := adapters.NewPrinter(cfg.PrinterAddress)
+	labelService := services.NewLabelService(printer, cfg.TemplatePath, "")
+	verifier := newLabelVerifier(cfg)
+
+	// SSCC закрытых коробов для документа агрегации ГИС МТ
+	sscc, err := cfg.SSCCPrefix()
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("Ошибка настройки SSCC: %v", err)
 	}
-	sensorReg, err := strconv.Atoi(cfg.SensorRegister)
+
+	manualStation := processors.NewManualAggregationProcessor(taskService, labelService, verifier, uniqueValidator, cfg.CodeLength)
+	manualStation.UseEquipmentLock(equipment)
+	manualStation.UseSSCC(sscc)
+
+	// Процессор линии выбирается по режиму из профиля продукта или настроек
+	scanService, err := newProcessorRegistry(cfg, taskService, uniqueValidator, labelService, verifier, equipment, manualStation)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("Ошибка настройки процессоров: %v", err)
 	}
 
-	plc := adapters.NewModbusPLC(cfg.PlcAddress, 5*time.Second, 5*time.Millisecond, uint16(sensorReg), uint16(pusherReg), 5)
+	// Приостановка задания приостанавливает линию и ручную станцию
+	taskService.UseProcessors(scanService, manualStation)
+
+	// Журналируем переходы состояний процессоров
+	logStateChanges("линии", scanService)
+	logStateChanges("ручной агрегации", manualStation)
 
-	scanService := processors.NewAutomaticSerializationProcessor(taskService, camera, plc)
+	// Периодически сообщаем EZFactory состояние линии, без связи снимки копятся в базе
+	heartbeatInterval := time.Duration(cfg.HeartbeatInterval) * time.Millisecond
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = 15 * time.Second
+	}
+	heartbeatBuffer := cfg.HeartbeatBuffer
+	if heartbeatBuffer <= 0 {
+		heartbeatBuffer = 5760
+	}
+	heartbeatService := services.NewHeartbeatService(factoryClient, taskService, cfg.LineID, version, heartbeatInterval, heartbeatBuffer)
+	heartbeatService.AddProcessor("line", scanService)
+	heartbeatService.AddProcessor("manual_station", manualStation)
+	heartbeatService.AddDevice(printer)
+	go heartbeatService.Run(context.Background())
 
-	taskHandlers := handlers.NewTaskHandler(taskService, scanService)
+	taskHandlers := handlers.NewTaskHandler(taskService, scanService, manualStation)
+	scanEventHandlers := handlers.NewScanEventHandler(repository.NewScanEventRepository())
+	manualStationHandlers := handlers.NewManualStationHandler(taskService, manualStation)
+	outboxHandlers := handlers.NewOutboxHandler(outboxSender)
+	webhookHandlers := handlers.NewWebhookHandler(taskService, cfg.FactoryWebhookSecret, scanService, manualStation)
+	exportHandlers := handlers.NewExportHandler(services.NewExportService(), cfg.CodeNamesOutID, cfg.GISMTParticipantINN, cfg.GISMTSerialLength)
+	containerHandlers := handlers.NewContainerHandler(services.NewContainerService(taskService, uniqueValidator, cfg.CodeLength))
 
 	// Создаем роутер
 	r := chi.NewRouter()
@@ -63,12 +137,44 @@ func main() {
 	fileServer := http.FileServer(http.Dir("./static"))
 	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
 
-	handlers.SetupRoutes(r, taskHandlers)
+	handlers.SetupRoutes(r, taskHandlers, scanEventHandlers, manualStationHandlers, containerHandlers, outboxHandlers, webhookHandlers, exportHandlers)
 
 	// Запускаем сервер
-	log.Printf("Запуск сервера на http://localhost:8080 (Линия ID: %d, EZFactory: %s)",
-		cfg.LineID, cfg.FactoryURL)
+	log.Printf("Запуск EZLine %s на http://localhost:8080 (Линия ID: %d, EZFactory: %s, режим: %s)",
+		version, cfg.LineID, cfg.FactoryURL, scanService.Mode().Title())
 	if err := http.ListenAndServe(":8080", r); err != nil {
 		log.Fatal("Ошибка запуска сервера: ", err)
 	}
 }
+
+// logStateChanges выводит в лог изменения состояния процессора
+func logStateChanges(name string, processor processors.TaskProcessor) {
+	changes, _ := processor.Subscribe()
+	go func() {
+		for change := range changes {
+			if change.Reason != "" {
+				log.Printf("Процессор %s: %s -> %s (%s)", name, change.Previous, change.State, change.Reason)
+			} else {
+				log.Printf("Процессор %s: %s -> %s", name, change.Previous, change.State)
+			}
+		}
+	}()
+}
+
+// configureFactoryAuth настраивает TLS и токены доступа линии к EZFactory
+func configureFactoryAuth(factoryClient *api.FactoryClient, cfg config.Config) error {
+	if cfg.FactoryCACert != "" || cfg.FactoryClientCert != "" || cfg.FactoryClientKey != "" {
+		if err := factoryClient.UseTLS(cfg.FactoryCACert, cfg.FactoryClientCert, cfg.FactoryClientKey); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case cfg.FactoryAPIKey != "":
+		factoryClient.UseTokens(api.NewLineTokenSource(factoryClient, cfg.LineID, cfg.FactoryAPIKey))
+	case cfg.FactoryToken != "":
+		factoryClient.UseTokens(api.StaticToken(cfg.FactoryToken))
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ze674/EZLine/internal/adapters"
+	"github.com/ze674/EZLine/internal/config"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/processors"
+	"github.com/ze674/EZLine/internal/services"
+	"github.com/ze674/EZLine/internal/utils"
+	"strconv"
+	"time"
+)
+
+// newProcessorRegistry регистрирует процессоры всех режимов линии.
+// Оборудование создается только для режима, в котором линия реально запускается
+func newProcessorRegistry(
+	cfg config.Config,
+	dataService processors.DataService,
+	uniqueValidator *services.CodeUniquenessValidator,
+	labelService *services.LabelService,
+	verifier *processors.LabelVerifier,
+	equipment *processors.EquipmentLock,
+	manualStation *processors.ManualAggregationProcessor,
+) (*processors.Registry, error) {
+	defaultMode, err := models.ParseProcessorMode(cfg.ProcessorMode)
+	if err != nil {
+		return nil, err
+	}
+	if defaultMode == "" {
+		defaultMode = models.ProcessorModeItemSerialization
+	}
+
+	registry := processors.NewRegistry(dataService, defaultMode)
+
+	// Поштучная сериализация: сканер и ПЛК с датчиком продукции и отбраковщиком
+	registry.Register(models.ProcessorModeItemSerialization, func() (processors.TaskProcessor, error) {
+		pusherReg, err := strconv.Atoi(cfg.PusherRegister)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный регистр пушера: %w", err)
+		}
+		sensorReg, err := strconv.Atoi(cfg.SensorRegister)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный регистр датчика: %w", err)
+		}
+
+		camera := adapters.NewScanner(cfg.ScannerAddress, cfg.ScanCommand)
+		plc := adapters.NewModbusPLC(cfg.PlcAddress, 5*time.Second, 5*time.Millisecond, uint16(sensorReg), uint16(pusherReg), 5)
+		if cfg.ConveyorStopRegister != "" {
+			stopReg, err := strconv.Atoi(cfg.ConveyorStopRegister)
+			if err != nil {
+				return nil, fmt.Errorf("некорректный регистр останова конвейера: %w", err)
+			}
+			plc.WithConveyorStop(uint16(stopReg))
+		}
+
+		processor := processors.NewAutomaticSerializationProcessor(dataService, camera, plc, uniqueValidator, cfg.CodeLength, cfg.AnswerNoRead)
+		processor.UseEquipmentLock(equipment)
+		return processor, nil
+	})
+
+	// Послойная агрегация: камера слоя, сканирование по таймеру и печать этикеток коробов
+	registry.Register(models.ProcessorModeLayerAggregation, func() (processors.TaskProcessor, error) {
+		period := time.Duration(cfg.TriggerPeriod) * time.Millisecond
+		if period <= 0 {
+			period = time.Second
+		}
+
+		camera := newLayerCamera(cfg)
+		trigger := utils.NewTimerTrigger(period)
+
+		sscc, err := cfg.SSCCPrefix()
+		if err != nil {
+			return nil, err
+		}
+
+		processor := processors.NewLayerAggregationProcessor(dataService, camera, trigger, labelService, verifier, uniqueValidator, cfg.CodeLength)
+		processor.UseEquipmentLock(equipment)
+		processor.UseSSCC(sscc)
+		return processor, nil
+	})
+
+	// Ручная агрегация: та же станция, что открывается со страницы ручного сканирования
+	registry.Register(models.ProcessorModeManualAggregation, func() (processors.TaskProcessor, error) {
+		return manualStation, nil
+	})
+
+	return registry, nil
+}
+
+// newLayerCamera создает камеру слоя. Если задано несколько адресов, слой снимает группа камер
+func newLayerCamera(cfg config.Config) processors.CodeReader {
+	if len(cfg.CameraAddresses) == 0 {
+		return adapters.NewScanner(cfg.ScannerAddress, cfg.ScanCommand)
+	}
+
+	timeout := time.Duration(cfg.CameraTimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+
+	readers := make([]processors.CodeReader, len(cfg.CameraAddresses))
+	for i, address := range cfg.CameraAddresses {
+		readers[i] = adapters.NewScanner(address, cfg.ScanCommand)
+	}
+	return processors.NewCameraGroup(timeout, readers...)
+}
+
+// newLabelVerifier создает проверку этикеток коробов, если в настройках задан адрес считывателя
+func newLabelVerifier(cfg config.Config) *processors.LabelVerifier {
+	if cfg.VerifierAddress == "" {
+		return nil
+	}
+
+	reader := adapters.NewScanner(cfg.VerifierAddress, cfg.ScanCommand)
+	return processors.NewLabelVerifier(reader, cfg.VerifyAttempts, time.Duration(cfg.VerifyDelay)*time.Millisecond)
+}
@@ -0,0 +1,49 @@
+// cmd/mockfactory/main.go
+package main
+
+import (
+	"flag"
+	"github.com/ze674/EZLine/internal/mockfactory"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Имитация EZFactory для локальной разработки: go run ./cmd/mockfactory -fixture cmd/mockfactory/fixture.json
+func main() {
+	addr := flag.String("addr", ":8081", "адрес, на котором слушает сервер")
+	fixturePath := flag.String("fixture", "cmd/mockfactory/fixture.json", "JSON-файл с заданиями и продуктами")
+	tokenTTL := flag.Duration("token-ttl", time.Hour, "срок действия токенов линий, если в данных заданы ключи линий")
+	certFile := flag.String("tls-cert", "", "сертификат сервера для HTTPS")
+	keyFile := flag.String("tls-key", "", "закрытый ключ сертификата сервера")
+	clientCA := flag.String("client-ca", "", "корневые сертификаты для проверки сертификатов линий (mTLS)")
+	webhookURL := flag.String("webhook", "", "адрес уведомлений линии, например http://localhost:8080/webhook/factory")
+	webhookSecret := flag.String("webhook-secret", "", "ключ подписи уведомлений")
+	flag.Parse()
+
+	fixture, err := mockfactory.LoadFixture(*fixturePath)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки данных: %v", err)
+	}
+
+	server := mockfactory.NewServer(fixture)
+	server.SetTokenTTL(*tokenTTL)
+	server.SetWebhook(*webhookURL, *webhookSecret)
+
+	log.Printf("Имитация EZFactory на %s (заданий: %d, продуктов: %d)", *addr, len(fixture.Tasks), len(fixture.Products))
+
+	if *certFile == "" {
+		err = http.ListenAndServe(*addr, server.Handler())
+	} else {
+		httpServer := &http.Server{Addr: *addr, Handler: server.Handler()}
+		if *clientCA != "" {
+			if httpServer.TLSConfig, err = mockfactory.ClientAuthTLSConfig(*clientCA); err != nil {
+				log.Fatalf("Ошибка загрузки корневых сертификатов: %v", err)
+			}
+		}
+		err = httpServer.ListenAndServeTLS(*certFile, *keyFile)
+	}
+	if err != nil {
+		log.Fatal("Ошибка запуска сервера: ", err)
+	}
+}
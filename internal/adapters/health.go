@@ -0,0 +1,38 @@
+package adapters
+
+import (
+	"github.com/ze674/EZLine/internal/models"
+	"sync"
+	"time"
+)
+
+// deviceHealth запоминает результат последнего обмена с устройством для передачи в EZFactory
+type deviceHealth struct {
+	mu     sync.Mutex
+	status models.DeviceHealth
+}
+
+func newDeviceHealth(name string) *deviceHealth {
+	return &deviceHealth{status: models.DeviceHealth{Name: name, Error: "нет подключения"}}
+}
+
+// record сохраняет результат обмена: nil - устройство ответило
+func (h *deviceHealth) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.status.OK = err == nil
+	h.status.Error = ""
+	if err != nil {
+		h.status.Error = err.Error()
+	}
+	h.status.LastAt = time.Now()
+}
+
+// get возвращает копию состояния
+func (h *deviceHealth) get() models.DeviceHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.status
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/goburrow/modbus"
+	"github.com/ze674/EZLine/internal/models"
 )
 
 const (
@@ -22,11 +23,15 @@ type ModbusPLC struct {
 
 	productSensorRegister uint16
 	rejectorRegister      uint16
+	conveyorStopRegister  uint16
+	hasConveyorStop       bool // Регистр останова конвейера задан
 
 	client  modbus.Client
 	handler *modbus.TCPClientHandler
 
 	bufferSize int
+
+	health *deviceHealth
 }
 
 // NewModbusPLC возвращает адаптер для PLC через Modbus TCP
@@ -38,9 +43,22 @@ func NewModbusPLC(address string, timeout, scanInterval time.Duration, productSe
 		productSensorRegister: productSensorRegister,
 		rejectorRegister:      rejectorRegister,
 		bufferSize:            bufferSize,
+		health:                newDeviceHealth("ПЛК"),
 	}
 }
 
+// Health возвращает результат последнего обмена с PLC
+func (p *ModbusPLC) Health() models.DeviceHealth {
+	return p.health.get()
+}
+
+// WithConveyorStop задает регистр, которым ПЛК останавливает конвейер по выполнению плана
+func (p *ModbusPLC) WithConveyorStop(register uint16) *ModbusPLC {
+	p.conveyorStopRegister = register
+	p.hasConveyorStop = true
+	return p
+}
+
 // Connect устанавливает соединение с PLC
 func (p *ModbusPLC) Connect() error {
 	op := "plc.modbus.Connect"
@@ -49,10 +67,13 @@ func (p *ModbusPLC) Connect() error {
 	p.handler.Timeout = p.timeout
 
 	if err := p.handler.Connect(); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		err = fmt.Errorf("%s: %w", op, err)
+		p.health.record(err)
+		return err
 	}
 
 	p.client = modbus.NewClient(p.handler)
+	p.health.record(nil)
 	return nil
 }
 
@@ -87,6 +108,7 @@ func (p *ModbusPLC) HandleProductSignal(ctx context.Context) (<-chan struct{}, e
 			select {
 			case <-time.After(p.sensorScanTime):
 				res, err := p.client.ReadCoils(p.productSensorRegister, 1)
+				p.health.record(err)
 				if err != nil {
 					fmt.Printf("%s: %s\n", op, err) // заменим на logger если появится
 					continue
@@ -121,6 +143,7 @@ func (p *ModbusPLC) RejectorOn() error {
 	op := "plc.modbus.RejectorOn"
 
 	_, err := p.client.WriteSingleCoil(p.rejectorRegister, modbusOn)
+	p.health.record(err)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -132,6 +155,39 @@ func (p *ModbusPLC) RejectorOff() error {
 	op := "plc.modbus.RejectorOff"
 
 	_, err := p.client.WriteSingleCoil(p.rejectorRegister, modbusOff)
+	p.health.record(err)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// StopConveyor включает сигнал останова конвейера. Если регистр не задан, ничего не делает
+func (p *ModbusPLC) StopConveyor() error {
+	op := "plc.modbus.StopConveyor"
+
+	if !p.hasConveyorStop {
+		return nil
+	}
+
+	_, err := p.client.WriteSingleCoil(p.conveyorStopRegister, modbusOn)
+	p.health.record(err)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ResumeConveyor снимает сигнал останова конвейера. Если регистр не задан, ничего не делает
+func (p *ModbusPLC) ResumeConveyor() error {
+	op := "plc.modbus.ResumeConveyor"
+
+	if !p.hasConveyorStop {
+		return nil
+	}
+
+	_, err := p.client.WriteSingleCoil(p.conveyorStopRegister, modbusOff)
+	p.health.record(err)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
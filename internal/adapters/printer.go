@@ -2,6 +2,7 @@ package adapters
 
 import (
 	"fmt"
+	"github.com/ze674/EZLine/internal/models"
 	"net"
 	"sync"
 	"time"
@@ -13,10 +14,16 @@ type Printer struct {
 	conn     net.Conn
 	mu       sync.Mutex
 	isClosed bool
+	health   *deviceHealth
 }
 
 func NewPrinter(address string) *Printer {
-	return &Printer{address: address}
+	return &Printer{address: address, health: newDeviceHealth("Принтер этикеток")}
+}
+
+// Health возвращает результат последнего подключения или печати
+func (p *Printer) Health() models.DeviceHealth {
+	return p.health.get()
 }
 
 // Connect устанавливает соединение с принтером
@@ -30,11 +37,14 @@ func (p *Printer) Connect() error {
 
 	conn, err := net.DialTimeout("tcp", p.address, 5*time.Second)
 	if err != nil {
-		return fmt.Errorf("не удалось подключиться к принтеру (%s): %v", p.address, err)
+		err = fmt.Errorf("не удалось подключиться к принтеру (%s): %v", p.address, err)
+		p.health.record(err)
+		return err
 	}
 
 	p.conn = conn
 	p.isClosed = false
+	p.health.record(nil)
 	return nil
 }
 
@@ -50,9 +60,12 @@ func (p *Printer) Print(data string) error {
 	_, err := p.conn.Write([]byte(data))
 	if err != nil {
 		p.isClosed = true
-		return fmt.Errorf("ошибка отправки данных: %v", err)
+		err = fmt.Errorf("ошибка отправки данных: %v", err)
+		p.health.record(err)
+		return err
 	}
 
+	p.health.record(nil)
 	return nil
 }
 
@@ -68,9 +81,12 @@ func (p *Printer) Send(data string) error {
 	_, err := p.conn.Write([]byte(data))
 	if err != nil {
 		p.isClosed = true
-		return fmt.Errorf("ошибка отправки данных: %v", err)
+		err = fmt.Errorf("ошибка отправки данных: %v", err)
+		p.health.record(err)
+		return err
 	}
 
+	p.health.record(nil)
 	return nil
 }
 
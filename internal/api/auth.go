@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Токен обновляется заранее, чтобы не истечь во время запроса
+const tokenRefreshMargin = 30 * time.Second
+
+// TokenSource выдает токен доступа линии к EZFactory
+type TokenSource interface {
+	// Token возвращает действующий токен
+	Token(ctx context.Context) (string, error)
+	// Invalidate сбрасывает токен, отклоненный сервером, чтобы следующий запрос получил новый
+	Invalidate()
+}
+
+// StaticToken - постоянный токен линии, выданный администратором EZFactory
+type StaticToken string
+
+// Token возвращает постоянный токен
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// Invalidate ничего не делает: постоянный токен обновить нельзя
+func (t StaticToken) Invalidate() {}
+
+// AccessToken - временный токен доступа, выданный EZFactory по ключу линии
+type AccessToken struct {
+	Token     string `json:"token"`
+	ExpiresIn int    `json:"expires_in"` // Срок действия в секундах
+}
+
+// LineTokenSource получает у EZFactory временные токены по ключу линии и обновляет их до истечения срока
+type LineTokenSource struct {
+	mu        sync.Mutex
+	client    *FactoryClient
+	lineID    int
+	apiKey    string
+	token     string
+	expiresAt time.Time
+}
+
+// NewLineTokenSource создает источник токенов линии
+func NewLineTokenSource(client *FactoryClient, lineID int, apiKey string) *LineTokenSource {
+	return &LineTokenSource{
+		client: client,
+		lineID: lineID,
+		apiKey: apiKey,
+	}
+}
+
+// Token возвращает действующий токен, при необходимости запрашивая новый
+func (s *LineTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expiresAt.Add(-tokenRefreshMargin)) {
+		return s.token, nil
+	}
+
+	token, err := s.client.requestToken(ctx, s.lineID, s.apiKey)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token.Token
+	s.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return s.token, nil
+}
+
+// Invalidate сбрасывает токен
+func (s *LineTokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = ""
+}
+
+// UseTokens включает авторизацию запросов токеном линии
+func (c *FactoryClient) UseTokens(tokens TokenSource) *FactoryClient {
+	c.Tokens = tokens
+	return c
+}
+
+// UseTLS настраивает проверку сервера по корневым сертификатам из caFile и,
+// если заданы certFile и keyFile, предъявляет серверу сертификат линии (mTLS)
+func (c *FactoryClient) UseTLS(caFile, certFile, keyFile string) error {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения корневых сертификатов: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("в файле %s нет сертификатов PEM", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("ошибка загрузки сертификата линии: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	c.HTTPClient.Transport = transport
+
+	return nil
+}
+
+// requestToken обменивает ключ линии на временный токен доступа
+func (c *FactoryClient) requestToken(ctx context.Context, lineID int, apiKey string) (AccessToken, error) {
+	data := url.Values{}
+	data.Set("line_id", strconv.Itoa(lineID))
+	data.Set("api_key", apiKey)
+
+	var token AccessToken
+	err := c.call(ctx, "RequestToken", request{
+		method:      http.MethodPost,
+		path:        "/api/auth/token",
+		contentType: "application/x-www-form-urlencoded",
+		body:        []byte(data.Encode()),
+		anonymous:   true,
+	}, &token)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	if token.Token == "" {
+		return AccessToken{}, &Error{Kind: ErrInvalidResponse, Op: "RequestToken", Message: "пустой токен"}
+	}
+
+	return token, nil
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ze674/EZLine/internal/models"
+)
+
+// tokenServer выдает токены token-1, token-2, ... и принимает запросы только с токенами из valid
+type tokenServer struct {
+	issued   atomic.Int32
+	requests atomic.Int32
+	valid    func(token string) bool
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/auth/token" {
+		if r.Header.Get("Authorization") != "" {
+			writeResponse(w, http.StatusBadRequest, nil, "запрос токена с токеном")
+			return
+		}
+		if r.FormValue("line_id") != "2" || r.FormValue("api_key") != "secret" {
+			writeResponse(w, http.StatusUnauthorized, nil, "неверный ключ линии")
+			return
+		}
+		n := s.issued.Add(1)
+		writeResponse(w, http.StatusOK, AccessToken{Token: fmt.Sprintf("token-%d", n), ExpiresIn: 3600}, "")
+		return
+	}
+
+	s.requests.Add(1)
+	token := r.Header.Get("Authorization")
+	if len(token) < len("Bearer ") || !s.valid(token[len("Bearer "):]) {
+		writeResponse(w, http.StatusUnauthorized, nil, "токен отклонен")
+		return
+	}
+	writeResponse(w, http.StatusOK, models.Task{ID: 7}, "")
+}
+
+func TestCallRefreshesRejectedToken(t *testing.T) {
+	server := &tokenServer{valid: func(token string) bool { return token == "token-2" }}
+	client := newTestClient(t, server)
+	client.UseTokens(NewLineTokenSource(client, 2, "secret"))
+
+	if _, err := client.GetTaskByID(context.Background(), 7); err != nil {
+		t.Fatalf("GetTaskByID: %v", err)
+	}
+	if got := server.issued.Load(); got != 2 {
+		t.Errorf("выдано токенов = %d, ожидалось 2", got)
+	}
+	if got := server.requests.Load(); got != 2 {
+		t.Errorf("запросов = %d, ожидалось 2", got)
+	}
+
+	// Обновленный токен используется в следующих запросах без повторного получения
+	if _, err := client.GetTaskByID(context.Background(), 7); err != nil {
+		t.Fatalf("повторный GetTaskByID: %v", err)
+	}
+	if got := server.issued.Load(); got != 2 {
+		t.Errorf("выдано токенов после повторного запроса = %d, ожидалось 2", got)
+	}
+}
+
+func TestCallRefreshesTokenOnlyOnce(t *testing.T) {
+	server := &tokenServer{valid: func(token string) bool { return false }}
+	client := newTestClient(t, server)
+	client.UseTokens(NewLineTokenSource(client, 2, "secret"))
+
+	_, err := client.GetTaskByID(context.Background(), 7)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("ошибка = %v, ожидалась ErrUnauthorized", err)
+	}
+	if got := server.issued.Load(); got != 2 {
+		t.Errorf("выдано токенов = %d, ожидалось 2", got)
+	}
+	if got := server.requests.Load(); got != 2 {
+		t.Errorf("запросов = %d, ожидалось 2", got)
+	}
+}
+
+func TestCallDoesNotRefreshStaticToken(t *testing.T) {
+	server := &tokenServer{valid: func(token string) bool { return token == "static" }}
+	client := newTestClient(t, server)
+	client.UseTokens(StaticToken("wrong"))
+
+	_, err := client.GetTaskByID(context.Background(), 7)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("ошибка = %v, ожидалась ErrUnauthorized", err)
+	}
+	if got := server.requests.Load(); got != 2 {
+		t.Errorf("запросов = %d, ожидалось 2: постоянный токен повторяется один раз", got)
+	}
+}
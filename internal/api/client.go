@@ -1,14 +1,32 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/ze674/EZLine/internal/models"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// IdempotencyKeyHeader - заголовок с ключом идемпотентности повторяемых запросов
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Значения по умолчанию для запросов к EZFactory
+const (
+	defaultRequestTimeout = 10 * time.Second
+	defaultMaxRetries     = 2
+	defaultRetryDelay     = 500 * time.Millisecond
+
+	// Сколько символов тела ответа не в формате Response попадает в сообщение об ошибке
+	maxErrorBody = 200
+)
+
 // Response общая структура ответа от API
 type Response struct {
 	Success bool            `json:"success"`
@@ -16,133 +34,288 @@ type Response struct {
 	Error   string          `json:"error,omitempty"`
 }
 
-// FactoryClient предоставляет методы для взаимодействия с API EZFactory
+// FactoryClient предоставляет методы для взаимодействия с API EZFactory.
+// Идемпотентные запросы (чтение и запросы с ключом идемпотентности) повторяются
+// при недоступности сервера с увеличивающейся задержкой.
+// Если сервер отклонил токен линии, токен обновляется и запрос повторяется один раз
 type FactoryClient struct {
-	BaseURL    string
-	HTTPClient *http.Client
+	BaseURL        string
+	HTTPClient     *http.Client
+	RequestTimeout time.Duration // Время ожидания одной попытки
+	MaxRetries     int           // Количество повторов идемпотентного запроса
+	RetryDelay     time.Duration // Задержка перед первым повтором, удваивается с каждым повтором
+	Tokens         TokenSource   // Токены доступа линии, nil - запросы без авторизации
 }
 
 // NewFactoryClient создает новый экземпляр клиента для работы с EZFactory
 func NewFactoryClient(baseURL string) *FactoryClient {
 	return &FactoryClient{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		BaseURL:        baseURL,
+		HTTPClient:     &http.Client{},
+		RequestTimeout: defaultRequestTimeout,
+		MaxRetries:     defaultMaxRetries,
+		RetryDelay:     defaultRetryDelay,
 	}
 }
 
 // GetTasks получает список заданий для указанной линии
-func (c *FactoryClient) GetTasks(lineID int) ([]models.Task, error) {
-	urlStr := fmt.Sprintf("%s/api/tasks?line_id=%d", c.BaseURL, lineID)
-
+func (c *FactoryClient) GetTasks(ctx context.Context, lineID int) ([]models.Task, error) {
 	tasks := make([]models.Task, 0)
 
-	resp, err := c.HTTPClient.Get(urlStr)
-	if err != nil {
-		return tasks, fmt.Errorf("ошибка при запросе к API: %w", err)
+	path := fmt.Sprintf("/api/tasks?line_id=%d", lineID)
+	if err := c.call(ctx, "GetTasks", request{method: http.MethodGet, path: path}, &tasks); err != nil {
+		return []models.Task{}, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return tasks, fmt.Errorf("неожиданный HTTP статус: %d", resp.StatusCode)
-	}
+	return tasks, nil
+}
 
-	var response Response
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return tasks, fmt.Errorf("ошибка при декодировании ответа: %w", err)
-	}
+// GetTaskByID получает информацию о задании по ID
+func (c *FactoryClient) GetTaskByID(ctx context.Context, taskID int) (models.Task, error) {
+	var task models.Task
 
-	if !response.Success {
-		return tasks, fmt.Errorf("ошибка API: %s", response.Error)
+	path := fmt.Sprintf("/api/tasks/%d", taskID)
+	if err := c.call(ctx, "GetTaskByID", request{method: http.MethodGet, path: path}, &task); err != nil {
+		return models.Task{}, err
 	}
 
-	if err := json.Unmarshal(response.Data, &tasks); err != nil {
-		return []models.Task{}, fmt.Errorf("ошибка при демаршалинге заданий: %w", err)
-	}
+	return task, nil
+}
 
-	return tasks, nil
+// UpdateTaskStatus обновляет статус задания
+func (c *FactoryClient) UpdateTaskStatus(ctx context.Context, taskID int, newStatus string) error {
+	return c.UpdateTaskStatusWithKey(ctx, taskID, newStatus, "")
 }
 
-// GetTaskByID получает информацию о задании по ID
-func (c *FactoryClient) GetTaskByID(taskID int) (models.Task, error) {
-	urlStr := fmt.Sprintf("%s/api/tasks/%d", c.BaseURL, taskID)
+// UpdateTaskStatusWithKey обновляет статус задания с ключом идемпотентности.
+// EZFactory не применяет повторно запрос с уже обработанным ключом, поэтому его можно безопасно повторять
+func (c *FactoryClient) UpdateTaskStatusWithKey(ctx context.Context, taskID int, newStatus string, idempotencyKey string) error {
+	// Подготовка данных формы
+	data := url.Values{}
+	data.Set("status", newStatus)
 
-	resp, err := c.HTTPClient.Get(urlStr)
+	return c.call(ctx, "UpdateTaskStatus", request{
+		method:         http.MethodPost,
+		path:           fmt.Sprintf("/api/tasks/%d/status", taskID),
+		contentType:    "application/x-www-form-urlencoded",
+		body:           []byte(data.Encode()),
+		idempotencyKey: idempotencyKey,
+	}, nil)
+}
+
+// UploadAggregation отправляет часть иерархии короб -> товары задания.
+// Ключ идемпотентности позволяет повторить отправку части после сбоя без дублей
+func (c *FactoryClient) UploadAggregation(ctx context.Context, chunk models.AggregationChunk, idempotencyKey string) error {
+	body, err := json.Marshal(chunk)
 	if err != nil {
-		return models.Task{}, fmt.Errorf("ошибка при запросе к API: %w", err)
+		return fmt.Errorf("ошибка при кодировании агрегации: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return models.Task{}, fmt.Errorf("неожиданный HTTP статус: %d", resp.StatusCode)
-	}
+	return c.call(ctx, "UploadAggregation", request{
+		method:         http.MethodPost,
+		path:           fmt.Sprintf("/api/tasks/%d/aggregation", chunk.TaskID),
+		contentType:    "application/json",
+		body:           body,
+		idempotencyKey: idempotencyKey,
+	}, nil)
+}
 
-	var response Response
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return models.Task{}, fmt.Errorf("ошибка при декодировании ответа: %w", err)
+// GetTaskCodes получает страницу кодов маркировки, выданных для задания.
+// Пустой курсор запрашивает первую страницу, курсор следующей страницы возвращается в ответе
+func (c *FactoryClient) GetTaskCodes(ctx context.Context, taskID int, cursor string, limit int) (models.CodePage, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	if cursor != "" {
+		query.Set("cursor", cursor)
 	}
 
-	if !response.Success {
-		return models.Task{}, fmt.Errorf("ошибка API: %s", response.Error)
-	}
+	var page models.CodePage
 
-	var task models.Task
-	if err := json.Unmarshal(response.Data, &task); err != nil {
-		return models.Task{}, fmt.Errorf("ошибка при демаршалинге задания: %w", err)
+	path := fmt.Sprintf("/api/tasks/%d/codes?%s", taskID, query.Encode())
+	if err := c.call(ctx, "GetTaskCodes", request{method: http.MethodGet, path: path}, &page); err != nil {
+		return models.CodePage{}, err
 	}
 
-	return task, nil
+	return page, nil
 }
 
-// UpdateTaskStatus обновляет статус задания
-func (c *FactoryClient) UpdateTaskStatus(taskID int, newStatus string) error {
-	urlStr := fmt.Sprintf("%s/api/tasks/%d/status", c.BaseURL, taskID)
+// ReportCodeUsage отправляет отчет об использованных, испорченных и возвращенных кодах задания
+func (c *FactoryClient) ReportCodeUsage(ctx context.Context, report models.CodeUsageReport, idempotencyKey string) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("ошибка при кодировании отчета: %w", err)
+	}
 
-	// Подготовка данных формы
-	data := url.Values{}
-	data.Set("status", newStatus)
+	return c.call(ctx, "ReportCodeUsage", request{
+		method:         http.MethodPost,
+		path:           fmt.Sprintf("/api/tasks/%d/codes/report", report.TaskID),
+		contentType:    "application/json",
+		body:           body,
+		idempotencyKey: idempotencyKey,
+	}, nil)
+}
 
-	// Отправка POST запроса
-	resp, err := c.HTTPClient.PostForm(urlStr, data)
+// SendHeartbeats отправляет снимки состояния линии в порядке их создания.
+// Ключ идемпотентности позволяет повторить отправку без дублей
+func (c *FactoryClient) SendHeartbeats(ctx context.Context, lineID int, heartbeats []models.LineHeartbeat, idempotencyKey string) error {
+	body, err := json.Marshal(heartbeats)
 	if err != nil {
-		return fmt.Errorf("ошибка при отправке запроса: %w", err)
+		return fmt.Errorf("ошибка при кодировании состояния линии: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("неожиданный HTTP статус: %d", resp.StatusCode)
+	return c.call(ctx, "SendHeartbeats", request{
+		method:         http.MethodPost,
+		path:           fmt.Sprintf("/api/lines/%d/heartbeats", lineID),
+		contentType:    "application/json",
+		body:           body,
+		idempotencyKey: idempotencyKey,
+	}, nil)
+}
+
+// GetProductByID получает карточку продукта вместе с данными этикетки
+func (c *FactoryClient) GetProductByID(ctx context.Context, productID int) (models.Product, error) {
+	var product models.Product
+
+	path := fmt.Sprintf("/api/product/%d", productID)
+	if err := c.call(ctx, "GetProductByID", request{method: http.MethodGet, path: path}, &product); err != nil {
+		return models.Product{}, err
 	}
 
-	return nil
+	return product, nil
+}
+
+// request описывает запрос к EZFactory. Тело хранится целиком, чтобы запрос можно было повторить
+type request struct {
+	method         string
+	path           string
+	contentType    string
+	body           []byte
+	idempotencyKey string
+	anonymous      bool // Запрос без токена, например получение самого токена
+}
+
+// idempotent сообщает, можно ли безопасно повторить запрос
+func (r request) idempotent() bool {
+	return r.method == http.MethodGet || r.idempotencyKey != ""
 }
 
-func (c *FactoryClient) GetProductByID(productID int) (models.Product, error) {
-	urlStr := fmt.Sprintf("%s/api/product/%d", c.BaseURL, productID)
+// call выполняет запрос с повторами и разбирает ответ в формате Response.
+// Если out равен nil, данные ответа не разбираются
+func (c *FactoryClient) call(ctx context.Context, op string, req request, out any) error {
+	retries := 0
+	if req.idempotent() {
+		retries = c.MaxRetries
+	}
+
+	delay := c.RetryDelay
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		err := c.do(ctx, op, req, out)
 
-	resp, err := c.HTTPClient.Get(urlStr)
+		// Сервер не принял токен: запрос не выполнен, поэтому его можно повторить с новым токеном
+		if isTokenRejected(err) && c.Tokens != nil && !req.anonymous && !refreshed {
+			refreshed = true
+			c.Tokens.Invalidate()
+			attempt--
+			continue
+		}
+
+		if err == nil || attempt >= retries || !IsUnavailable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// do выполняет одну попытку запроса
+func (c *FactoryClient) do(ctx context.Context, op string, req request, out any) error {
+	if c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.BaseURL+req.path, body)
 	if err != nil {
-		return models.Product{}, fmt.Errorf("ошибка при запросе к API: %w", err)
+		return fmt.Errorf("ошибка при создании запроса: %w", err)
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if req.idempotencyKey != "" {
+		httpReq.Header.Set(IdempotencyKeyHeader, req.idempotencyKey)
+	}
+	if c.Tokens != nil && !req.anonymous {
+		token, err := c.Tokens.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("ошибка получения токена доступа: %w", err)
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return &Error{Kind: kindByTransportError(err), Op: op, Err: err}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return models.Product{}, fmt.Errorf("неожиданный HTTP статус: %d", resp.StatusCode)
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &Error{Kind: ErrNetwork, Op: op, StatusCode: resp.StatusCode, Err: err}
 	}
 
 	var response Response
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return models.Product{}, fmt.Errorf("ошибка при декодировании ответа: %w", err)
+	decodeErr := json.Unmarshal(raw, &response)
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &Error{Kind: kindByStatus(resp.StatusCode), Op: op, StatusCode: resp.StatusCode}
+		if decodeErr == nil {
+			apiErr.Message = response.Error
+		} else {
+			apiErr.Message = truncate(strings.TrimSpace(string(raw)), maxErrorBody)
+		}
+		return apiErr
+	}
+
+	// Ответ без данных может прийти не в формате Response, проверяем только явный отказ
+	if out == nil {
+		if decodeErr == nil && !response.Success {
+			return &Error{Kind: ErrRejected, Op: op, StatusCode: resp.StatusCode, Message: response.Error}
+		}
+		return nil
+	}
+
+	if decodeErr != nil {
+		return &Error{Kind: ErrInvalidResponse, Op: op, StatusCode: resp.StatusCode, Err: decodeErr}
 	}
 
 	if !response.Success {
-		return models.Product{}, fmt.Errorf("ошибка API: %s", response.Error)
+		return &Error{Kind: ErrRejected, Op: op, StatusCode: resp.StatusCode, Message: response.Error}
 	}
 
-	var product models.Product
-	if err := json.Unmarshal(response.Data, &product); err != nil {
-		return models.Product{}, fmt.Errorf("ошибка при демаршалинге продукта: %w", err)
+	if err := json.Unmarshal(response.Data, out); err != nil {
+		return &Error{Kind: ErrInvalidResponse, Op: op, StatusCode: resp.StatusCode, Err: err}
 	}
 
-	return product, nil
+	return nil
+}
+
+// truncate обрезает строку до указанного количества символов
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit]) + "..."
 }
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ze674/EZLine/internal/models"
+)
+
+// newTestClient создает клиент к тестовому серверу с короткой задержкой повторов
+func newTestClient(t *testing.T, handler http.Handler) *FactoryClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewFactoryClient(server.URL)
+	client.RetryDelay = time.Millisecond
+	return client
+}
+
+// writeResponse отвечает в формате Response
+func writeResponse(w http.ResponseWriter, status int, data any, errMsg string) {
+	raw, _ := json.Marshal(data)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{Success: errMsg == "", Data: raw, Error: errMsg})
+}
+
+func TestCallRetriesIdempotentRequestWhileUnavailable(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			writeResponse(w, http.StatusServiceUnavailable, nil, "перегрузка")
+			return
+		}
+		writeResponse(w, http.StatusOK, models.Task{ID: 7}, "")
+	}))
+
+	task, err := client.GetTaskByID(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetTaskByID: %v", err)
+	}
+	if task.ID != 7 {
+		t.Errorf("ID задания = %d, ожидалось 7", task.ID)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("запросов = %d, ожидалось 3", got)
+	}
+}
+
+func TestCallGivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeResponse(w, http.StatusBadGateway, nil, "нет связи")
+	}))
+
+	_, err := client.GetTaskByID(context.Background(), 7)
+	if !errors.Is(err, ErrServer) {
+		t.Fatalf("ошибка = %v, ожидалась ErrServer", err)
+	}
+	if got, want := calls.Load(), int32(client.MaxRetries+1); got != want {
+		t.Errorf("запросов = %d, ожидалось %d", got, want)
+	}
+}
+
+func TestCallDoesNotRetryRequestWithoutIdempotencyKey(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeResponse(w, http.StatusServiceUnavailable, nil, "перегрузка")
+	}))
+
+	err := client.UpdateTaskStatus(context.Background(), 7, models.TaskStatusCompleted)
+	if !IsUnavailable(err) {
+		t.Fatalf("ошибка = %v, ожидалась недоступность", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("запросов = %d, ожидался 1", got)
+	}
+}
+
+func TestCallRetriesRequestWithIdempotencyKey(t *testing.T) {
+	var calls atomic.Int32
+	var keys []string
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		if calls.Add(1) == 1 {
+			writeResponse(w, http.StatusInternalServerError, nil, "сбой")
+			return
+		}
+		writeResponse(w, http.StatusOK, nil, "")
+	}))
+
+	err := client.UpdateTaskStatusWithKey(context.Background(), 7, models.TaskStatusCompleted, "status-7")
+	if err != nil {
+		t.Fatalf("UpdateTaskStatusWithKey: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "status-7" || keys[1] != "status-7" {
+		t.Errorf("ключи запросов = %v, ожидался повтор с ключом status-7", keys)
+	}
+}
+
+func TestCallDoesNotRetryRejectedRequest(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict} {
+		t.Run(fmt.Sprint(status), func(t *testing.T) {
+			var calls atomic.Int32
+			client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				writeResponse(w, status, nil, "отказ")
+			}))
+
+			_, err := client.GetTaskByID(context.Background(), 7)
+			if err == nil || IsUnavailable(err) {
+				t.Fatalf("ошибка = %v, ожидался отказ", err)
+			}
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != status || apiErr.Message != "отказ" {
+				t.Errorf("ошибка = %#v, ожидался HTTP %d с сообщением сервера", err, status)
+			}
+			if got := calls.Load(); got != 1 {
+				t.Errorf("запросов = %d, ожидался 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Виды ошибок обращения к EZFactory, проверяются через errors.Is
+var (
+	ErrNetwork         = errors.New("EZFactory недоступен")
+	ErrUnauthorized    = errors.New("нет доступа к EZFactory")
+	ErrNotFound        = errors.New("не найдено в EZFactory")
+	ErrServer          = errors.New("ошибка на стороне EZFactory")
+	ErrRejected        = errors.New("EZFactory отклонил запрос")
+	ErrInvalidResponse = errors.New("некорректный ответ EZFactory")
+)
+
+// Error описывает неудачный вызов EZFactory: вид ошибки, HTTP статус и сообщение сервера
+type Error struct {
+	Kind       error  // Один из видов ошибок Err*
+	Op         string // Вызов клиента, например "GetTaskByID"
+	StatusCode int    // HTTP статус, 0 - ответ не получен
+	Message    string // Сообщение об ошибке из ответа EZFactory
+	Err        error  // Исходная ошибка
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s (%s", e.Kind, e.Op)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(", HTTP %d", e.StatusCode)
+	}
+	msg += ")"
+
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap позволяет проверять и вид ошибки, и исходную ошибку
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// IsUnavailable сообщает, что EZFactory недоступен или не смог обработать запрос,
+// то есть запрос имеет смысл повторить позже
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrNetwork) || errors.Is(err, ErrServer)
+}
+
+// isTokenRejected сообщает, что сервер отклонил токен доступа (HTTP 401).
+// При HTTP 403 токен действителен, но линии не разрешен запрос, и новый токен не поможет
+func isTokenRejected(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
+
+// kindByTransportError определяет вид ошибки, при которой ответ не получен.
+// Отказ в TLS рукопожатии означает ошибку сертификатов, а не недоступность сервера
+func kindByTransportError(err error) error {
+	var verifyErr *tls.CertificateVerificationError
+	if errors.As(err, &verifyErr) {
+		return ErrUnauthorized
+	}
+
+	// Отказ сервера в рукопожатии (например, сертификат линии не предъявлен или не принят)
+	// приходит как неэкспортируемый тип tls, поэтому распознается по тексту
+	if strings.Contains(err.Error(), "remote error: tls:") {
+		return ErrUnauthorized
+	}
+
+	return ErrNetwork
+}
+
+// kindByStatus определяет вид ошибки по HTTP статусу ответа
+func kindByStatus(status int) error {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrUnauthorized
+	case status == http.StatusNotFound:
+		return ErrNotFound
+	case status == http.StatusTooManyRequests || status >= http.StatusInternalServerError:
+		return ErrServer
+	default:
+		return ErrRejected
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Заголовки уведомлений EZFactory. Подпись - HMAC-SHA256 от строки "<время>.<тело запроса>" в hex
+const (
+	WebhookTimestampHeader = "X-EZFactory-Timestamp"
+	WebhookSignatureHeader = "X-EZFactory-Signature"
+)
+
+// Допустимое расхождение времени уведомления, старые уведомления считаются повтором
+const webhookMaxSkew = 5 * time.Minute
+
+// Сколько хранится ID принятого уведомления: уведомление старше допустимого расхождения
+// отклоняется по времени, поэтому дольше помнить ID не нужно
+const webhookReplayWindow = 2 * webhookMaxSkew
+
+// Ошибки проверки уведомлений
+var (
+	ErrInvalidSignature = errors.New("неверная подпись уведомления EZFactory")
+	ErrStaleWebhook     = errors.New("уведомление EZFactory устарело")
+	ErrReplayedWebhook  = errors.New("уведомление EZFactory уже получено")
+	ErrMissingWebhookID = errors.New("у уведомления EZFactory нет ID")
+)
+
+// SignWebhook вычисляет подпись уведомления
+func SignWebhook(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook проверяет подпись и время уведомления
+func VerifyWebhook(secret []byte, timestamp string, signature string, body []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	expected := SignWebhook(secret, ts, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	skew := now.Sub(time.Unix(ts, 0))
+	if skew > webhookMaxSkew || skew < -webhookMaxSkew {
+		return ErrStaleWebhook
+	}
+
+	return nil
+}
+
+// WebhookReplayGuard отклоняет повторную доставку уведомления с тем же ID,
+// пока подпись уведомления считается действительной
+type WebhookReplayGuard struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // ID принятых уведомлений и время приема
+}
+
+// NewWebhookReplayGuard создает защиту от повтора уведомлений
+func NewWebhookReplayGuard() *WebhookReplayGuard {
+	return &WebhookReplayGuard{seen: make(map[string]time.Time)}
+}
+
+// Accept запоминает ID уведомления. Если уведомление с таким ID уже принято, возвращает ErrReplayedWebhook
+func (g *WebhookReplayGuard) Accept(id string, now time.Time) error {
+	if id == "" {
+		return ErrMissingWebhookID
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for seenID, at := range g.seen {
+		if now.Sub(at) > webhookReplayWindow {
+			delete(g.seen, seenID)
+		}
+	}
+
+	if _, ok := g.seen[id]; ok {
+		return ErrReplayedWebhook
+	}
+
+	g.seen[id] = now
+	return nil
+}
+
+// Forget забывает ID уведомления, которое не удалось применить, чтобы EZFactory мог доставить его повторно
+func (g *WebhookReplayGuard) Forget(id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.seen, id)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWebhookReplayGuardRejectsRepeatedID(t *testing.T) {
+	guard := NewWebhookReplayGuard()
+	now := time.Now()
+
+	if err := guard.Accept("event-1", now); err != nil {
+		t.Fatalf("первая доставка: %v", err)
+	}
+	if err := guard.Accept("event-1", now.Add(time.Minute)); !errors.Is(err, ErrReplayedWebhook) {
+		t.Errorf("повторная доставка: %v, ожидалась ErrReplayedWebhook", err)
+	}
+	if err := guard.Accept("event-2", now.Add(time.Minute)); err != nil {
+		t.Errorf("другое уведомление: %v", err)
+	}
+	if err := guard.Accept("", now); !errors.Is(err, ErrMissingWebhookID) {
+		t.Errorf("уведомление без ID: %v, ожидалась ErrMissingWebhookID", err)
+	}
+}
+
+func TestWebhookReplayGuardForgetsFailedAndExpiredIDs(t *testing.T) {
+	guard := NewWebhookReplayGuard()
+	now := time.Now()
+
+	// Непримененное уведомление EZFactory доставит повторно
+	if err := guard.Accept("event-1", now); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	guard.Forget("event-1")
+	if err := guard.Accept("event-1", now); err != nil {
+		t.Errorf("доставка после неудачной обработки: %v", err)
+	}
+
+	// Повтор после окна отклоняется по времени подписи, ID больше не хранится
+	later := now.Add(webhookReplayWindow + time.Second)
+	if err := guard.Accept("event-2", later); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if _, ok := guard.seen["event-1"]; ok {
+		t.Error("ID уведомления хранится дольше окна повтора")
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"id":"event-1","type":"task_assigned","task_id":1}`)
+	now := time.Now()
+	signature := SignWebhook(secret, now.Unix(), body)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	if err := VerifyWebhook(secret, timestamp, signature, body, now); err != nil {
+		t.Errorf("действительное уведомление: %v", err)
+	}
+	if err := VerifyWebhook([]byte("other"), timestamp, signature, body, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("чужой ключ: %v, ожидалась ErrInvalidSignature", err)
+	}
+	if err := VerifyWebhook(secret, timestamp, signature, append(body, ' '), now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("измененное тело: %v, ожидалась ErrInvalidSignature", err)
+	}
+	if err := VerifyWebhook(secret, timestamp, signature, body, now.Add(webhookMaxSkew+time.Second)); !errors.Is(err, ErrStaleWebhook) {
+		t.Errorf("старое уведомление: %v, ожидалась ErrStaleWebhook", err)
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"github.com/ze674/EZLine/internal/markingcode"
 	"os"
 )
 
@@ -20,6 +21,57 @@ type Config struct {
 	AnswerNoRead   string `json:"scanner_answer_noread"` // Ответ на команду сканирования
 	PusherRegister string `json:"plc_pusher_register"`   // Регистр пушера
 	SensorRegister string `json:"plc_sensor_register"`   // Регистр сенсора
+	ProcessorMode  string `json:"processor_mode"`        // Режим работы линии по умолчанию
+	TriggerPeriod  int    `json:"trigger_period_ms"`     // Период сканирования слоя (мс)
+	OutboxRetry    int    `json:"outbox_retry_ms"`       // Интервал повтора отправки в EZFactory (мс)
+	UploadChunk    int    `json:"upload_chunk_size"`     // Коробов в одной части выгрузки агрегации
+	CodePageSize   int    `json:"code_page_size"`        // Кодов маркировки на странице загрузки
+
+	// Сообщение EZFactory, не принятое за столько попыток, переносится в неотправленные, 0 - 20 попыток.
+	// Недоступность EZFactory попытки не ограничивает: без связи сообщения ждут в очереди
+	OutboxMaxAttempts int `json:"outbox_max_attempts"`
+
+	// Запросы к EZFactory, нулевые значения - настройки клиента по умолчанию
+	FactoryTimeout int `json:"factory_timeout_ms"` // Время ожидания ответа на один запрос (мс)
+	FactoryRetries int `json:"factory_retries"`    // Повторов запросов чтения и запросов с ключом идемпотентности
+
+	// Доступ линии к EZFactory: токен и/или сертификат линии (mTLS), пустые значения - без авторизации
+	FactoryAPIKey     string `json:"factory_api_key"`     // Ключ линии для получения временных токенов
+	FactoryToken      string `json:"factory_token"`       // Постоянный токен, если ключ линии не задан
+	FactoryCACert     string `json:"factory_ca_cert"`     // Файл корневых сертификатов EZFactory (PEM)
+	FactoryClientCert string `json:"factory_client_cert"` // Сертификат линии (PEM)
+	FactoryClientKey  string `json:"factory_client_key"`  // Закрытый ключ сертификата линии (PEM)
+
+	// Ключ подписи уведомлений EZFactory, пустой - уведомления не принимаются
+	FactoryWebhookSecret string `json:"factory_webhook_secret"`
+
+	// Периодическая отправка состояния линии в EZFactory
+	HeartbeatInterval int `json:"heartbeat_interval_ms"` // Период снятия состояния (мс)
+	HeartbeatBuffer   int `json:"heartbeat_buffer"`      // Снимков, сохраняемых без связи, старые отбрасываются
+
+	// cOutID файла агрегации CodeNamesSerial по умолчанию
+	CodeNamesOutID string `json:"codenames_out_id"`
+
+	// Документ агрегации ГИС МТ (Честный ЗНАК)
+	GISMTParticipantINN string `json:"gismt_participant_inn"` // ИНН участника оборота
+	GISMTSerialLength   int    `json:"gismt_serial_length"`   // Длина серийного номера в кодах без разделителей GS, 0 - только коды с GS
+
+	// SSCC коробов, обозначающий агрегат в документе ГИС МТ, пустой префикс - короба без SSCC.
+	// Линии с общим префиксом компании должны иметь разные цифры расширения
+	SSCCExtensionDigit int    `json:"sscc_extension_digit"` // Цифра расширения 0-9
+	SSCCCompanyPrefix  string `json:"sscc_company_prefix"`  // Префикс компании GS1
+
+	// Останов конвейера через ПЛК по выполнению плана задания, пустой регистр - только остановка процессора
+	ConveyorStopRegister string `json:"plc_conveyor_stop_register"`
+
+	// Группа камер для широкого слоя, если задана - используется вместо scanner_address
+	CameraAddresses []string `json:"camera_addresses"`  // IP адреса камер слоя
+	CameraTimeout   int      `json:"camera_timeout_ms"` // Время ожидания ответа каждой камеры (мс)
+
+	// Проверка напечатанных этикеток коробов, пустой адрес отключает проверку
+	VerifierAddress string `json:"verifier_address"` // IP адрес считывателя этикеток
+	VerifyAttempts  int    `json:"verify_attempts"`  // Количество попыток печати этикетки
+	VerifyDelay     int    `json:"verify_delay_ms"`  // Задержка от печати до чтения этикетки (мс)
 }
 
 // DefaultConfig возвращает конфигурацию по умолчанию
@@ -29,7 +81,27 @@ func DefaultConfig() Config {
 		LineID:         1,
 		StoragePath:    "./data",
 		ScannerAddress: "127.0.0.1:2001",
+		ProcessorMode:  "item_serialization",
+		TriggerPeriod:  1000,
+		OutboxRetry:    5000,
+		UploadChunk:    100,
+		CodePageSize:   1000,
+		VerifyAttempts: 2,
+
+		HeartbeatInterval: 15000,
+		HeartbeatBuffer:   5760,
+
+		OutboxMaxAttempts: 20,
+	}
+}
+
+// SSCCPrefix возвращает префикс SSCC коробов линии. Пустой префикс компании - нулевой префикс без ошибки
+func (c Config) SSCCPrefix() (markingcode.SSCCPrefix, error) {
+	prefix := markingcode.SSCCPrefix{Extension: c.SSCCExtensionDigit, CompanyPrefix: c.SSCCCompanyPrefix}
+	if prefix.IsZero() {
+		return prefix, nil
 	}
+	return prefix, prefix.Validate()
 }
 
 // LoadConfig загружает конфигурацию из файла
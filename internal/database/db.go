@@ -48,6 +48,27 @@ func Connect(dbPath string) error {
 	return nil
 }
 
+// OpenReadOnly открывает существующую базу данных только для чтения, без миграций.
+// Используется для проверок, которые не должны менять базу, например пробного импорта
+func OpenReadOnly(dbPath string) error {
+	var err error
+
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("база данных не найдена: %w", err)
+	}
+
+	DB, err = sql.Open("sqlite3", "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("не удалось открыть соединение с базой данных: %w", err)
+	}
+
+	if err = DB.Ping(); err != nil {
+		return fmt.Errorf("не удалось подключиться к базе данных: %w", err)
+	}
+
+	return nil
+}
+
 // Выполняет миграции базы данных
 func runMigrations(db *sql.DB) error {
 	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
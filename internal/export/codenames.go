@@ -0,0 +1,101 @@
+// Package export формирует файлы агрегации заданий для учетных систем
+package export
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/markingcode"
+	"github.com/ze674/EZLine/internal/models"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Ошибки формирования файла CodeNamesSerial
+var (
+	ErrNoOutID          = errors.New("не задан cOutID для файла CodeNamesSerial")
+	ErrInvalidXMLSymbol = errors.New("код содержит символ, недопустимый в XML")
+)
+
+// codeNameSerial - строка файла CodeNamesSerial: код товара и код его короба
+type codeNameSerial struct {
+	XMLName xml.Name `xml:"CodeNamesSerial"`
+	Code    string   `xml:"cCodeNameSerial"`
+	Parent  string   `xml:"cCodeNameSerialParent"`
+	OutID   string   `xml:"cOutID"`
+}
+
+// WriteCodeNamesSerial записывает агрегацию в XML CodeNamesSerial: по строке на каждый код товара
+// с кодом короба, в который он уложен. Коды экранируются, поэтому могут содержать &, < и кавычки.
+// Код записывается, как в файлах прежней программы агрегации: вместе с криптохвостом, без разделителей GS
+// и префикса сканера, поэтому результат не зависит от настройки сканера. Код с другими управляющими
+// символами не записывается: функция возвращает ошибку, и файл нужно выбросить.
+// Возвращает количество записанных кодов
+func WriteCodeNamesSerial(w io.Writer, containers []models.AggregationContainer, outID string) (int, error) {
+	if outID == "" {
+		return 0, ErrNoOutID
+	}
+
+	out := bufio.NewWriter(w)
+	if _, err := out.WriteString("<root>\n"); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for _, container := range containers {
+		for _, item := range container.Items {
+			code, err := codeNameSerialCode(item)
+			if err != nil {
+				return written, err
+			}
+			if !isXMLText(container.Code) {
+				return written, fmt.Errorf("%w: короб %q", ErrInvalidXMLSymbol, container.Code)
+			}
+
+			line, err := xml.Marshal(codeNameSerial{Code: code, Parent: container.Code, OutID: outID})
+			if err != nil {
+				return written, err
+			}
+
+			if _, err := fmt.Fprintf(out, "    %s\n", line); err != nil {
+				return written, err
+			}
+			written++
+		}
+	}
+
+	if _, err := out.WriteString("</root>\n"); err != nil {
+		return written, err
+	}
+
+	return written, out.Flush()
+}
+
+// codeNameSerialCode возвращает код товара в виде, допустимом в XML: без префикса сканера и разделителей GS.
+// Элементы криптохвоста (91, 92, 93) остаются в коде сразу после серийного номера
+func codeNameSerialCode(code string) (string, error) {
+	code = strings.ReplaceAll(markingcode.TrimSymbology(code), markingcode.GroupSeparator, "")
+
+	if !isXMLText(code) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidXMLSymbol, code)
+	}
+
+	return code, nil
+}
+
+// isXMLText сообщает, состоит ли строка только из символов, допустимых в XML 1.0.
+// xml.Marshal заменяет недопустимые символы на U+FFFD, и код молча портится
+func isXMLText(s string) bool {
+	for _, r := range s {
+		switch {
+		case r == utf8.RuneError:
+			return false
+		case r == '\t' || r == '\n' || r == '\r':
+		case r < 0x20, r >= 0xD800 && r <= 0xDFFF, r == 0xFFFE, r == 0xFFFF:
+			return false
+		}
+	}
+	return true
+}
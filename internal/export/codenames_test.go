@@ -0,0 +1,103 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ze674/EZLine/internal/models"
+)
+
+func TestWriteCodeNamesSerialWritesCodeWithoutSeparators(t *testing.T) {
+	// Один и тот же формат кода при любой настройке сканера: с GS, без GS, с префиксом символики
+	containers := []models.AggregationContainer{{
+		Code: "046070547612440000001",
+		Items: []string{
+			"0104607054761244215cBd25\x1d9378F2",
+			"\x1d0104607054761244215dF&y6\x1d93b2nc",
+			"0104607054761244215gAULJ93Tr5x",
+			"]C10104607054761244215hW7!k\x1d93aaaa",
+		},
+	}}
+
+	var buf bytes.Buffer
+	count, err := WriteCodeNamesSerial(&buf, containers, "out-1")
+	if err != nil {
+		t.Fatalf("WriteCodeNamesSerial: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("записано кодов = %d, ожидалось 4", count)
+	}
+
+	var root struct {
+		Rows []codeNameSerial `xml:"CodeNamesSerial"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &root); err != nil {
+		t.Fatalf("файл не разбирается как XML: %v\n%s", err, buf.String())
+	}
+
+	want := []string{
+		"0104607054761244215cBd259378F2",
+		"0104607054761244215dF&y693b2nc",
+		"0104607054761244215gAULJ93Tr5x",
+		"0104607054761244215hW7!k93aaaa",
+	}
+	if len(root.Rows) != len(want) {
+		t.Fatalf("строк = %d, ожидалось %d", len(root.Rows), len(want))
+	}
+	for i, row := range root.Rows {
+		if row.Code != want[i] || row.Parent != "046070547612440000001" || row.OutID != "out-1" {
+			t.Errorf("строка %d = %+v, ожидался код %q", i, row, want[i])
+		}
+	}
+	if strings.ContainsRune(buf.String(), '�') {
+		t.Error("в файле есть символ замены U+FFFD")
+	}
+	if !strings.Contains(buf.String(), "<cCodeNameSerial>0104607054761244215dF&amp;y693b2nc</cCodeNameSerial>") {
+		t.Errorf("строка с & записана не в формате прежней программы:\n%s", buf.String())
+	}
+}
+
+func TestWriteCodeNamesSerialRejectsInvalidXMLSymbol(t *testing.T) {
+	containers := []models.AggregationContainer{{
+		Code:  "046070547612440000001",
+		Items: []string{"0104607054761244215cBd25\x04"},
+	}}
+
+	var buf bytes.Buffer
+	if _, err := WriteCodeNamesSerial(&buf, containers, "out-1"); !errors.Is(err, ErrInvalidXMLSymbol) {
+		t.Errorf("ошибка = %v, ожидалась ErrInvalidXMLSymbol", err)
+	}
+}
+
+// failingWriter принимает limit байт, затем возвращает ошибку записи
+type failingWriter struct {
+	limit int
+}
+
+var errWriteFailed = errors.New("диск заполнен")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errWriteFailed
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriteCodeNamesSerialReportsWriteError(t *testing.T) {
+	items := make([]string, 200)
+	for i := range items {
+		items[i] = "0104607054761244215cBd25\x1d9378F2"
+	}
+	containers := []models.AggregationContainer{{Code: "046070547612440000001", Items: items}}
+
+	// Ошибка записи при сбросе буфера посреди файла не теряется
+	if _, err := WriteCodeNamesSerial(&failingWriter{limit: 100}, containers, "out-1"); !errors.Is(err, errWriteFailed) {
+		t.Errorf("ошибка = %v, ожидалась ошибка записи", err)
+	}
+}
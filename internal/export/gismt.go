@@ -0,0 +1,182 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/markingcode"
+	"github.com/ze674/EZLine/internal/models"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Тип агрегации в документе ГИС МТ
+const gismtAggregationType = "AGGREGATION"
+
+// ErrNoSSCC возвращается для короба, закрытого без SSCC: префикс компании GS1 линии не был задан
+var ErrNoSSCC = errors.New("у короба нет SSCC, задайте sscc_company_prefix в настройках линии")
+
+// Код идентификации без криптохвоста: 01 + GTIN + 21 + серийный номер из допустимых символов GS1
+var gismtKIPattern = regexp.MustCompile(`^01\d{14}21[!-z]{1,20}$`)
+
+// GISMTAggregationDocument - документ агрегации ГИС МТ (Честный ЗНАК)
+type GISMTAggregationDocument struct {
+	ParticipantID    string                 `json:"participantId"` // ИНН участника оборота
+	AggregationUnits []GISMTAggregationUnit `json:"aggregationUnits"`
+}
+
+// GISMTAggregationUnit - агрегат документа: код короба и коды идентификации вложенных товаров
+type GISMTAggregationUnit struct {
+	AggregatedItemsCount int      `json:"aggregatedItemsCount"`
+	AggregationType      string   `json:"aggregationType"`
+	UnitSerialNumber     string   `json:"unitSerialNumber"` // SSCC (00 + 18 цифр) или код идентификации упаковки
+	Sntins               []string `json:"sntins"`           // Коды идентификации без криптохвоста
+}
+
+// BuildGISMTAggregation формирует документ агрегации из коробов задания.
+// Агрегат обозначается SSCC короба, коды товаров приводятся к коду идентификации без криптохвоста.
+// Короба без товаров пропускаются
+func BuildGISMTAggregation(containers []models.AggregationContainer, inn string, serialLength int) (GISMTAggregationDocument, error) {
+	document := GISMTAggregationDocument{
+		ParticipantID:    inn,
+		AggregationUnits: []GISMTAggregationUnit{},
+	}
+
+	for _, container := range containers {
+		if len(container.Items) == 0 {
+			continue
+		}
+
+		if container.SSCC == "" {
+			return GISMTAggregationDocument{}, fmt.Errorf("короб %s: %w", container.Code, ErrNoSSCC)
+		}
+
+		unit := GISMTAggregationUnit{
+			AggregationType:  gismtAggregationType,
+			UnitSerialNumber: "00" + container.SSCC,
+			Sntins:           make([]string, 0, len(container.Items)),
+		}
+
+		for _, item := range container.Items {
+			code, err := markingcode.Parse(item, serialLength)
+			if err != nil {
+				return GISMTAggregationDocument{}, fmt.Errorf("короб %s: %w", container.Code, err)
+			}
+			unit.Sntins = append(unit.Sntins, code.KI())
+		}
+		unit.AggregatedItemsCount = len(unit.Sntins)
+
+		document.AggregationUnits = append(document.AggregationUnits, unit)
+	}
+
+	return document, nil
+}
+
+// Validate проверяет документ на соответствие структуре ГИС МТ и возвращает все найденные ошибки
+func (d GISMTAggregationDocument) Validate() error {
+	var errs []error
+
+	if !isValidINN(d.ParticipantID) {
+		errs = append(errs, fmt.Errorf("некорректный ИНН участника оборота: %q", d.ParticipantID))
+	}
+	if len(d.AggregationUnits) == 0 {
+		errs = append(errs, errors.New("в документе нет агрегатов"))
+	}
+
+	units := make(map[string]bool)
+	sntins := make(map[string]string)
+	for i, unit := range d.AggregationUnits {
+		if unit.UnitSerialNumber == "" {
+			errs = append(errs, fmt.Errorf("агрегат %d: не задан код агрегата", i+1))
+		} else if !isValidUnitCode(unit.UnitSerialNumber) {
+			errs = append(errs, fmt.Errorf("агрегат %s: код агрегата не является SSCC или кодом идентификации", unit.UnitSerialNumber))
+		} else if units[unit.UnitSerialNumber] {
+			errs = append(errs, fmt.Errorf("агрегат %s указан повторно", unit.UnitSerialNumber))
+		}
+		units[unit.UnitSerialNumber] = true
+
+		if unit.AggregationType != gismtAggregationType {
+			errs = append(errs, fmt.Errorf("агрегат %s: неизвестный тип агрегации %q", unit.UnitSerialNumber, unit.AggregationType))
+		}
+		if len(unit.Sntins) == 0 {
+			errs = append(errs, fmt.Errorf("агрегат %s: нет вложенных кодов", unit.UnitSerialNumber))
+		}
+		if unit.AggregatedItemsCount != len(unit.Sntins) {
+			errs = append(errs, fmt.Errorf("агрегат %s: количество %d не совпадает с числом кодов %d",
+				unit.UnitSerialNumber, unit.AggregatedItemsCount, len(unit.Sntins)))
+		}
+
+		for _, sntin := range unit.Sntins {
+			if !gismtKIPattern.MatchString(sntin) {
+				errs = append(errs, fmt.Errorf("агрегат %s: некорректный код идентификации %q", unit.UnitSerialNumber, sntin))
+			}
+			if first, ok := sntins[sntin]; ok {
+				errs = append(errs, fmt.Errorf("код %s вложен в агрегаты %s и %s", sntin, first, unit.UnitSerialNumber))
+				continue
+			}
+			sntins[sntin] = unit.UnitSerialNumber
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// WriteGISMTAggregation записывает документ в JSON
+func WriteGISMTAggregation(w io.Writer, document GISMTAggregationDocument) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(document)
+}
+
+// isValidUnitCode проверяет код агрегата: SSCC с идентификатором применения (00) или код идентификации
+// групповой или транспортной упаковки. Контрольная цифра SSCC и GTIN проверяется
+func isValidUnitCode(code string) bool {
+	if len(code) == 20 && strings.HasPrefix(code, "00") && isDigits(code) {
+		return markingcode.HasValidCheckDigit(code[2:])
+	}
+
+	return gismtKIPattern.MatchString(code) && markingcode.HasValidCheckDigit(code[2:16])
+}
+
+// isValidINN проверяет ИНН организации (10 цифр) или индивидуального предпринимателя (12 цифр)
+// по контрольным цифрам
+func isValidINN(inn string) bool {
+	if !isDigits(inn) {
+		return false
+	}
+
+	digits := make([]int, len(inn))
+	for i, r := range inn {
+		digits[i] = int(r - '0')
+	}
+
+	check := func(weights []int) int {
+		sum := 0
+		for i, weight := range weights {
+			sum += digits[i] * weight
+		}
+		return sum % 11 % 10
+	}
+
+	switch len(digits) {
+	case 10:
+		return check([]int{2, 4, 10, 3, 5, 9, 4, 6, 8}) == digits[9]
+	case 12:
+		return check([]int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) == digits[10] &&
+			check([]int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) == digits[11]
+	default:
+		return false
+	}
+}
+
+// isDigits сообщает, состоит ли строка только из цифр
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,43 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGISMTAggregationValidateUnitSerialNumber(t *testing.T) {
+	tests := []struct {
+		name  string
+		code  string
+		valid bool
+	}{
+		{"SSCC", "00046070547612345671", true},
+		{"SSCC с неверной контрольной цифрой", "00046070547612345672", false},
+		{"SSCC без идентификатора применения", "046070547612345671", false},
+		{"код идентификации упаковки", "0104607054761244215cBd25", true},
+		{"код идентификации с неверным GTIN", "0104607054761245215cBd25", false},
+		{"код короба линии", "046070547612440000001", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document := GISMTAggregationDocument{
+				ParticipantID: "7707083893",
+				AggregationUnits: []GISMTAggregationUnit{{
+					AggregatedItemsCount: 1,
+					AggregationType:      gismtAggregationType,
+					UnitSerialNumber:     tt.code,
+					Sntins:               []string{"0104607054766164215+5DqV9"},
+				}},
+			}
+
+			err := document.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			if !tt.valid && (err == nil || !strings.Contains(err.Error(), "не является SSCC")) {
+				t.Errorf("Validate = %v, ожидалась ошибка кода агрегата", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/ze674/EZLine/internal/services"
+	"github.com/ze674/EZLine/templates"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContainerHandler обрабатывает запросы просмотра и редактирования контейнеров
+type ContainerHandler struct {
+	containerService *services.ContainerService
+}
+
+// NewContainerHandler создает обработчик контейнеров
+func NewContainerHandler(containerService *services.ContainerService) *ContainerHandler {
+	return &ContainerHandler{
+		containerService: containerService,
+	}
+}
+
+// ListContainersHandler отображает контейнеры задания
+func (h *ContainerHandler) ListContainersHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Некорректный ID задания", http.StatusBadRequest)
+		return
+	}
+
+	containers, err := h.containerService.GetContainersByTaskID(taskID)
+	if err != nil {
+		http.Error(w, "Ошибка при получении контейнеров: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	component := templates.ContainersList(taskID, containers)
+
+	if r.Header.Get("HX-Request") == "true" {
+		component.Render(r.Context(), w)
+	} else {
+		templates.Page(component).Render(r.Context(), w)
+	}
+}
+
+// FindContainerHandler ищет контейнер по отсканированному коду
+func (h *ContainerHandler) FindContainerHandler(w http.ResponseWriter, r *http.Request) {
+	containerID, err := h.containerService.FindContainerByCode(r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	http.Redirect(w, r, containerURL(containerID), http.StatusSeeOther)
+}
+
+// ContainerHandler отображает состав контейнера и журнал его изменений
+func (h *ContainerHandler) ContainerHandler(w http.ResponseWriter, r *http.Request) {
+	containerID, ok := containerIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	details, err := h.containerService.GetContainerDetails(containerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	component := templates.ContainerDetails(details)
+
+	if r.Header.Get("HX-Request") == "true" {
+		component.Render(r.Context(), w)
+	} else {
+		templates.Page(component).Render(r.Context(), w)
+	}
+}
+
+// RemoveItemHandler удаляет товар из контейнера
+func (h *ContainerHandler) RemoveItemHandler(w http.ResponseWriter, r *http.Request) {
+	h.edit(w, r, func(containerID int64) error {
+		return h.containerService.RemoveItem(containerID, r.FormValue("code"), r.FormValue("reason"))
+	})
+}
+
+// ReplaceItemHandler заменяет товар в контейнере
+func (h *ContainerHandler) ReplaceItemHandler(w http.ResponseWriter, r *http.Request) {
+	h.edit(w, r, func(containerID int64) error {
+		return h.containerService.ReplaceItem(containerID, r.FormValue("code"), r.FormValue("new_code"), r.FormValue("reason"))
+	})
+}
+
+// AddItemsHandler добавляет товары в контейнер
+func (h *ContainerHandler) AddItemsHandler(w http.ResponseWriter, r *http.Request) {
+	h.edit(w, r, func(containerID int64) error {
+		return h.containerService.AddItems(containerID, strings.Fields(r.FormValue("codes")), r.FormValue("reason"))
+	})
+}
+
+// DisbandHandler расформировывает контейнер
+func (h *ContainerHandler) DisbandHandler(w http.ResponseWriter, r *http.Request) {
+	h.edit(w, r, func(containerID int64) error {
+		return h.containerService.DisbandContainer(containerID, r.FormValue("reason"))
+	})
+}
+
+// edit разбирает форму, выполняет операцию над контейнером и возвращает на его страницу
+func (h *ContainerHandler) edit(w http.ResponseWriter, r *http.Request, operation func(containerID int64) error) {
+	containerID, ok := containerIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Ошибка обработки формы", http.StatusBadRequest)
+		return
+	}
+
+	if err := operation(containerID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, containerURL(containerID), http.StatusSeeOther)
+}
+
+func containerIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	containerID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Некорректный ID контейнера", http.StatusBadRequest)
+		return 0, false
+	}
+	return containerID, true
+}
+
+func containerURL(containerID int64) string {
+	return fmt.Sprintf("/containers/%d", containerID)
+}
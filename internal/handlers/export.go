@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/ze674/EZLine/internal/export"
+	"github.com/ze674/EZLine/internal/services"
+	"net/http"
+	"strconv"
+)
+
+// ExportHandler отдает файлы агрегации задания для учетных систем
+type ExportHandler struct {
+	exportService *services.ExportService
+	outID         string // cOutID по умолчанию для файла CodeNamesSerial
+	gismtINN      string // ИНН участника оборота для документа ГИС МТ
+	serialLength  int    // Длина серийного номера в кодах без разделителей GS
+}
+
+// NewExportHandler создает обработчик выгрузки агрегации в файлы
+func NewExportHandler(exportService *services.ExportService, outID string, gismtINN string, serialLength int) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+		outID:         outID,
+		gismtINN:      gismtINN,
+		serialLength:  serialLength,
+	}
+}
+
+// CodeNamesSerialHandler отдает агрегацию задания в XML CodeNamesSerial.
+// Параметр out_id заменяет cOutID из настроек
+func (h *ExportHandler) CodeNamesSerialHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Некорректный ID задания", http.StatusBadRequest)
+		return
+	}
+
+	outID := h.outID
+	if value := r.URL.Query().Get("out_id"); value != "" {
+		outID = value
+	}
+
+	// Файл собирается целиком, чтобы ошибка не оборвала скачивание на середине
+	var buf bytes.Buffer
+	if _, err := h.exportService.WriteCodeNamesSerial(&buf, taskID, outID); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrNothingToExport):
+			status = http.StatusNotFound
+		case errors.Is(err, export.ErrNoOutID):
+			status = http.StatusBadRequest
+		case errors.Is(err, export.ErrInvalidXMLSymbol):
+			status = http.StatusUnprocessableEntity
+		}
+		http.Error(w, "Ошибка выгрузки агрегации: "+err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"codenames_task_%d.xml\"", taskID))
+	w.Write(buf.Bytes())
+}
+
+// GISMTAggregationHandler отдает документ агрегации ГИС МТ для задания.
+// Документ отдается только после проверки структуры, иначе возвращается список нарушений
+func (h *ExportHandler) GISMTAggregationHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Некорректный ID задания", http.StatusBadRequest)
+		return
+	}
+
+	document, err := h.exportService.GISMTAggregation(taskID, h.gismtINN, h.serialLength)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrNothingToExport):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrInvalidDocument):
+			status = http.StatusUnprocessableEntity
+		}
+		http.Error(w, "Ошибка выгрузки документа агрегации: "+err.Error(), status)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.WriteGISMTAggregation(&buf, document); err != nil {
+		http.Error(w, "Ошибка выгрузки документа агрегации: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"gismt_aggregation_task_%d.json\"", taskID))
+	w.Write(buf.Bytes())
+}
@@ -2,19 +2,35 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/go-chi/chi/v5"
+	"github.com/ze674/EZLine/internal/api"
+	"github.com/ze674/EZLine/internal/processors"
 	"net/http"
 )
 
 // internal/handlers/handlers.go
-func SetupRoutes(r chi.Router, taskHandler *TaskHandler) {
+func SetupRoutes(r chi.Router, taskHandler *TaskHandler, scanEventHandler *ScanEventHandler, manualStationHandler *ManualStationHandler, containerHandler *ContainerHandler, outboxHandler *OutboxHandler, webhookHandler *WebhookHandler, exportHandler *ExportHandler) {
 	r.Get("/", homeHandler)
 
 	// Маршруты для заданий
 	r.Route("/tasks", func(r chi.Router) {
-		r.Get("/", taskHandler.ListTasksHandler)              // список заданий
-		r.Post("/{id}/select", taskHandler.SelectTaskHandler) // выбор задания
-		r.Post("/finish", taskHandler.FinishTaskHandler)      // завершение задания
+		r.Get("/", taskHandler.ListTasksHandler)                           // список заданий
+		r.Post("/{id}/select", taskHandler.SelectTaskHandler)              // выбор задания
+		r.Post("/finish", taskHandler.FinishTaskHandler)                   // завершение задания
+		r.Post("/pause", taskHandler.PauseTaskHandler)                     // приостановка задания
+		r.Get("/offline", taskHandler.OfflineBannerHandler)                // предупреждение о работе без связи
+		r.Get("/live", taskHandler.LiveTasksHandler)                       // обновление списка по уведомлениям EZFactory
+		r.Get("/warnings", taskHandler.TaskWarningsHandler)                // предупреждения по активному заданию
+		r.Get("/codes", taskHandler.CodePoolHandler)                       // загрузка кодов маркировки
+		r.Post("/codes/download", taskHandler.DownloadCodesHandler)        // повтор загрузки кодов маркировки
+		r.Get("/{id}/scan-events", scanEventHandler.ListScanEventsHandler) // журнал сканирования
+		r.Get("/{id}/containers", containerHandler.ListContainersHandler)  // контейнеры задания
+		r.Post("/{id}/upload", taskHandler.UploadAggregationHandler)       // выгрузка агрегации в EZFactory
+
+		// Агрегация задания в файлах для учетных систем
+		r.Get("/{id}/export/codenames.xml", exportHandler.CodeNamesSerialHandler)
+		r.Get("/{id}/export/gismt.json", exportHandler.GISMTAggregationHandler)
 	})
 
 	// Страница активного задания
@@ -23,6 +39,39 @@ func SetupRoutes(r chi.Router, taskHandler *TaskHandler) {
 	// Добавляем маршруты для управления сканированием
 	r.Post("/scanning/start", taskHandler.StartScanningHandler)
 	r.Post("/scanning/stop", taskHandler.StopScanningHandler)
+	r.Get("/scanning/counters", taskHandler.ScanCountersHandler)
+	r.Get("/scanning/cameras", taskHandler.CameraDiagnosticsHandler)
+	r.Get("/scanning/plan", taskHandler.PlanProgressHandler)
+	r.Post("/scanning/close-box", taskHandler.ClosePartialBoxHandler)
+
+	// Просмотр и редактирование контейнеров
+	r.Route("/containers", func(r chi.Router) {
+		r.Get("/find", containerHandler.FindContainerHandler)
+		r.Get("/{id}", containerHandler.ContainerHandler)
+		r.Post("/{id}/items/remove", containerHandler.RemoveItemHandler)
+		r.Post("/{id}/items/replace", containerHandler.ReplaceItemHandler)
+		r.Post("/{id}/items/add", containerHandler.AddItemsHandler)
+		r.Post("/{id}/disband", containerHandler.DisbandHandler)
+	})
+
+	// Ручная станция агрегации
+	r.Route("/manual", func(r chi.Router) {
+		r.Get("/", manualStationHandler.StationHandler)
+		r.Post("/start", manualStationHandler.StartHandler)
+		r.Post("/stop", manualStationHandler.StopHandler)
+		r.Post("/scan", manualStationHandler.ScanHandler)
+		r.Post("/close-box", manualStationHandler.ClosePartialBoxHandler)
+	})
+	// Очередь отправки в EZFactory
+	r.Route("/outbox", func(r chi.Router) {
+		r.Get("/", outboxHandler.ListHandler)
+		r.Get("/badge", outboxHandler.BadgeHandler)
+		r.Post("/retry", outboxHandler.RetryHandler)
+		r.Post("/{id}/requeue", outboxHandler.RequeueHandler)
+	})
+
+	// Уведомления EZFactory
+	r.Post("/webhook/factory", webhookHandler.FactoryEventHandler)
 	//r.Post("/packer/change", taskHandler.ChangePackerHandler)
 }
 
@@ -30,3 +79,39 @@ func SetupRoutes(r chi.Router, taskHandler *TaskHandler) {
 func homeHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/tasks", http.StatusSeeOther)
 }
+
+// httpError отправляет ошибку с HTTP статусом и подсказкой оператору по виду ошибки EZFactory.
+// Для остальных ошибок используется переданный статус
+func httpError(w http.ResponseWriter, message string, err error, status int) {
+	hint := ""
+
+	switch {
+	case errors.Is(err, api.ErrNetwork):
+		status = http.StatusServiceUnavailable
+		hint = "Проверьте сеть или повторите, когда связь с EZFactory восстановится."
+	case errors.Is(err, api.ErrUnauthorized):
+		status = http.StatusBadGateway
+		hint = "Проверьте настройки доступа линии к EZFactory."
+	case errors.Is(err, api.ErrNotFound):
+		status = http.StatusNotFound
+		hint = "Возможно, данные удалены в EZFactory. Обновите список заданий."
+	case errors.Is(err, api.ErrServer):
+		status = http.StatusBadGateway
+		hint = "Повторите позже, при повторении ошибки обратитесь к администратору EZFactory."
+	case errors.Is(err, api.ErrRejected), errors.Is(err, api.ErrInvalidResponse):
+		status = http.StatusBadGateway
+	case errors.Is(err, processors.ErrEquipmentBusy):
+		status = http.StatusConflict
+		hint = "Остановите линию или ручную станцию, прежде чем запускать другую."
+	case errors.Is(err, processors.ErrStationBusy):
+		status = http.StatusConflict
+		hint = "Остановите ручную станцию, прежде чем запускать ее с другим заданием."
+	}
+
+	text := message + ": " + err.Error()
+	if hint != "" {
+		text += "\n" + hint
+	}
+
+	http.Error(w, text, status)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/services"
+	"github.com/ze674/EZLine/templates"
+	"net/http"
+	"strconv"
+)
+
+// ManualStation описывает ручную станцию агрегации
+type ManualStation interface {
+	ScanningService
+	BoxProgressReporter
+	PartialBoxCloser
+	SubmitCode(code string) (models.ManualScanResult, error)
+	PendingCodes() []string
+}
+
+// ManualStationHandler обрабатывает запросы ручной станции агрегации
+type ManualStationHandler struct {
+	taskService *services.TaskService
+	station     ManualStation
+}
+
+// NewManualStationHandler создает обработчик ручной станции агрегации
+func NewManualStationHandler(taskService *services.TaskService, station ManualStation) *ManualStationHandler {
+	return &ManualStationHandler{
+		taskService: taskService,
+		station:     station,
+	}
+}
+
+// StationHandler отображает страницу ручной станции агрегации
+func (h *ManualStationHandler) StationHandler(w http.ResponseWriter, r *http.Request) {
+	activeTaskID := h.taskService.GetActiveTaskID()
+	if activeTaskID == 0 {
+		http.Redirect(w, r, "/tasks", http.StatusSeeOther)
+		return
+	}
+
+	task, err := h.taskService.GetTaskByID(activeTaskID)
+	if err != nil {
+		httpError(w, "Ошибка при получении информации о задании", err, http.StatusInternalServerError)
+		return
+	}
+
+	component := templates.ManualStation(task, h.state(nil))
+
+	if r.Header.Get("HX-Request") == "true" {
+		component.Render(r.Context(), w)
+	} else {
+		templates.Page(component).Render(r.Context(), w)
+	}
+}
+
+// StartHandler запускает станцию для активного задания
+func (h *ManualStationHandler) StartHandler(w http.ResponseWriter, r *http.Request) {
+	activeTaskID := h.taskService.GetActiveTaskID()
+	if activeTaskID == 0 {
+		http.Error(w, "Нет активного задания", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.station.Start(activeTaskID); err != nil {
+		httpError(w, "Ошибка запуска станции", err, http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/manual", http.StatusSeeOther)
+}
+
+// StopHandler останавливает станцию
+func (h *ManualStationHandler) StopHandler(w http.ResponseWriter, r *http.Request) {
+	if err := h.station.Stop(); err != nil {
+		http.Error(w, "Ошибка остановки станции: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/manual", http.StatusSeeOther)
+}
+
+// ScanHandler принимает код со сканера оператора и возвращает обновленную панель станции
+func (h *ManualStationHandler) ScanHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Ошибка обработки формы", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.station.SubmitCode(r.FormValue("code"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	templates.ManualStationPanel(h.state(&result)).Render(r.Context(), w)
+}
+
+// ClosePartialBoxHandler закрывает неполный короб и возвращает обновленную панель станции
+func (h *ManualStationHandler) ClosePartialBoxHandler(w http.ResponseWriter, r *http.Request) {
+	container, err := h.station.ClosePartialBox()
+	if err != nil && container.ID == 0 {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	result := models.ManualScanResult{
+		Accepted:      true,
+		BoxClosed:     true,
+		ContainerCode: container.Code,
+		Message:       "неполный короб, " + strconv.Itoa(container.ItemsCount) + " шт",
+	}
+	if err != nil {
+		result.Message = err.Error()
+		result.LabelFailed = true
+	}
+	result.Progress, _ = h.station.BoxProgress()
+
+	templates.ManualStationPanel(h.state(&result)).Render(r.Context(), w)
+}
+
+func (h *ManualStationHandler) state(result *models.ManualScanResult) templates.ManualStationState {
+	progress, _ := h.station.BoxProgress()
+
+	return templates.ManualStationState{
+		Running:      h.station.IsRunning(),
+		State:        h.station.State(),
+		Progress:     progress,
+		PendingCodes: h.station.PendingCodes(),
+		Counters:     h.station.Counters(),
+		LastResult:   result,
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/ze674/EZLine/internal/services"
+	"github.com/ze674/EZLine/templates"
+	"net/http"
+	"strconv"
+)
+
+// OutboxHandler отображает сообщения, ожидающие отправки в EZFactory
+type OutboxHandler struct {
+	outbox *services.OutboxSender
+}
+
+// NewOutboxHandler создает обработчик очереди отправки
+func NewOutboxHandler(outbox *services.OutboxSender) *OutboxHandler {
+	return &OutboxHandler{
+		outbox: outbox,
+	}
+}
+
+// ListHandler отображает очередь отправки и неотправленные сообщения
+func (h *OutboxHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	messages, err := h.outbox.Pending()
+	if err != nil {
+		http.Error(w, "Ошибка при получении очереди отправки: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	failed, err := h.outbox.Failed()
+	if err != nil {
+		http.Error(w, "Ошибка при получении неотправленных сообщений: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	component := templates.Outbox(messages, failed)
+
+	if r.Header.Get("HX-Request") == "true" {
+		component.Render(r.Context(), w)
+	} else {
+		templates.Page(component).Render(r.Context(), w)
+	}
+}
+
+// BadgeHandler отображает количество неотправленных сообщений в меню
+func (h *OutboxHandler) BadgeHandler(w http.ResponseWriter, r *http.Request) {
+	count, err := h.outbox.CountPending()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	failed, err := h.outbox.CountFailed()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	templates.OutboxBadge(count, failed).Render(r.Context(), w)
+}
+
+// RetryHandler запускает внеочередную отправку
+func (h *OutboxHandler) RetryHandler(w http.ResponseWriter, r *http.Request) {
+	h.outbox.Notify()
+
+	http.Redirect(w, r, "/outbox", http.StatusSeeOther)
+}
+
+// RequeueHandler возвращает неотправленное сообщение в очередь
+func (h *OutboxHandler) RequeueHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Некорректный ID сообщения", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.outbox.Requeue(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Сообщение не найдено среди неотправленных", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Ошибка возврата сообщения в очередь: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/outbox", http.StatusSeeOther)
+}
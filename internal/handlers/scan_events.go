@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/templates"
+	"net/http"
+	"strconv"
+)
+
+// Количество последних записей журнала, отображаемых на странице
+const scanEventsLimit = 200
+
+// ScanEventHandler отображает журнал сканирования
+type ScanEventHandler struct {
+	scanEventRepository *repository.ScanEventRepository
+}
+
+// NewScanEventHandler создает обработчик журнала сканирования
+func NewScanEventHandler(scanEventRepository *repository.ScanEventRepository) *ScanEventHandler {
+	return &ScanEventHandler{
+		scanEventRepository: scanEventRepository,
+	}
+}
+
+// ListScanEventsHandler отображает журнал сканирования для задания
+func (h *ScanEventHandler) ListScanEventsHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Некорректный ID задания", http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.scanEventRepository.GetScanEventsByTaskID(taskID, scanEventsLimit)
+	if err != nil {
+		http.Error(w, "Ошибка при получении журнала сканирования: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	reasons, err := h.scanEventRepository.CountScanEventsByReason(taskID)
+	if err != nil {
+		http.Error(w, "Ошибка при получении статистики отклонений: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	component := templates.ScanEvents(taskID, events, reasons)
+
+	if r.Header.Get("HX-Request") == "true" {
+		component.Render(r.Context(), w)
+	} else {
+		templates.Page(component).Render(r.Context(), w)
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"github.com/go-chi/chi/v5"
+	"github.com/ze674/EZLine/internal/models"
 	"github.com/ze674/EZLine/internal/services"
 	"github.com/ze674/EZLine/templates"
 	"net/http"
@@ -12,19 +13,46 @@ type ScanningService interface {
 	Start(taskID int) error
 	Stop() error
 	IsRunning() bool
+	Counters() models.ScanCounters
+	State() models.StateChange
+}
+
+// BoxProgressReporter реализуется процессорами, которые собирают короба
+type BoxProgressReporter interface {
+	BoxProgress() (models.BoxProgress, bool)
+}
+
+// PartialBoxCloser реализуется процессорами, которые умеют закрывать неполный короб
+type PartialBoxCloser interface {
+	ClosePartialBox() (models.Container, error)
+}
+
+// ProductChangeListener реализуется процессорами, которые нужно предупредить об изменении карточки продукта
+type ProductChangeListener interface {
+	// ProductChanged сообщает, что карточка продукта изменена, и возвращает true, если процессор работает с этим продуктом
+	ProductChanged(productID int) bool
+	// ProductOutdated сообщает, что процессор работает по устаревшей карточке продукта
+	ProductOutdated() bool
+}
+
+// CameraDiagnosticsReporter реализуется процессорами, которые снимают слой группой камер
+type CameraDiagnosticsReporter interface {
+	CameraStatuses() []models.CameraStatus
 }
 
 // Добавляем новое поле в структуру TaskHandler
 type TaskHandler struct {
 	taskService *services.TaskService
-	scanService ScanningService // Добавляем сервис сканирования
+	scanService ScanningService   // Добавляем сервис сканирования
+	stations    []ScanningService // Станции, запускаемые отдельно от линии, например ручная агрегация
 }
 
 // Обновляем конструктор
-func NewTaskHandler(taskService *services.TaskService, scanService ScanningService) *TaskHandler {
+func NewTaskHandler(taskService *services.TaskService, scanService ScanningService, stations ...ScanningService) *TaskHandler {
 	return &TaskHandler{
 		taskService: taskService,
 		scanService: scanService,
+		stations:    stations,
 	}
 }
 
@@ -33,7 +61,7 @@ func (h *TaskHandler) ListTasksHandler(w http.ResponseWriter, r *http.Request) {
 	// Получаем задания из сервиса
 	tasks, err := h.taskService.GetTasks()
 	if err != nil {
-		http.Error(w, "Ошибка при получении списка заданий: "+err.Error(), http.StatusInternalServerError)
+		httpError(w, "Ошибка при получении списка заданий", err, http.StatusInternalServerError)
 		return
 	}
 
@@ -42,7 +70,7 @@ func (h *TaskHandler) ListTasksHandler(w http.ResponseWriter, r *http.Request) {
 	activeTaskID := h.taskService.GetActiveTaskID()
 
 	// Рендерим шаблон
-	component := templates.TasksList(tasks, lineID, activeTaskID)
+	component := templates.TasksList(tasks, lineID, activeTaskID, h.taskService.TasksVersion())
 
 	if r.Header.Get("HX-Request") == "true" {
 		component.Render(r.Context(), w)
@@ -51,6 +79,26 @@ func (h *TaskHandler) ListTasksHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// LiveTasksHandler обновляет список заданий после уведомлений EZFactory.
+// Если с версии, показанной оператору, уведомлений не было, возвращает 204 и страница не меняется
+func (h *TaskHandler) LiveTasksHandler(w http.ResponseWriter, r *http.Request) {
+	version, _ := strconv.ParseUint(r.URL.Query().Get("version"), 10, 64)
+
+	current := h.taskService.TasksVersion()
+	if version == current {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	tasks, err := h.taskService.GetTasks()
+	if err != nil {
+		httpError(w, "Ошибка при получении списка заданий", err, http.StatusInternalServerError)
+		return
+	}
+
+	templates.TasksList(tasks, h.taskService.GetLineID(), h.taskService.GetActiveTaskID(), current).Render(r.Context(), w)
+}
+
 // Обновляем ActiveTaskHandler для передачи статуса сканирования в шаблон
 func (h *TaskHandler) ActiveTaskHandler(w http.ResponseWriter, r *http.Request) {
 
@@ -64,17 +112,16 @@ func (h *TaskHandler) ActiveTaskHandler(w http.ResponseWriter, r *http.Request)
 	// Получаем информацию о задании
 	task, err := h.taskService.GetTaskByID(activeTaskID)
 	if err != nil {
-		http.Error(w, "Ошибка при получении информации о задании: "+err.Error(),
-			http.StatusInternalServerError)
+		httpError(w, "Ошибка при получении информации о задании", err, http.StatusInternalServerError)
 		return
 	}
 
-	// Проверяем, запущено ли сканирование
-	isScanning := h.scanService.IsRunning()
+	// Получаем состояние процессора сканирования
+	state := h.scanService.State()
 	//packer := h.scanService.GetPacker()
 	packer := ""
 	// Отображаем шаблон активного задания
-	component := templates.ActiveTask(task, isScanning, packer)
+	component := templates.ActiveTask(task, state, packer, h.scanService.Counters(), h.boxProgress())
 
 	if r.Header.Get("HX-Request") == "true" {
 		component.Render(r.Context(), w)
@@ -96,7 +143,7 @@ func (h *TaskHandler) SelectTaskHandler(w http.ResponseWriter, r *http.Request)
 	// Выбираем задание
 	err = h.taskService.SelectTask(taskID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpError(w, "Ошибка при выборе задания", err, http.StatusBadRequest)
 		return
 	}
 
@@ -104,8 +151,19 @@ func (h *TaskHandler) SelectTaskHandler(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, "/active-task", http.StatusSeeOther)
 }
 
-// FinishTaskHandler завершает текущее выбранное задание
+// FinishTaskHandler завершает текущее выбранное задание.
+// Сначала останавливаются все процессоры, чтобы отчет и выгрузка не разошлись с последними коробами
 func (h *TaskHandler) FinishTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if h.taskService.GetActiveTaskID() == 0 {
+		http.Error(w, "Нет активного задания", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.stopAll(); err != nil {
+		http.Error(w, "Ошибка остановки сканирования: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Завершаем текущее активное задание
 	err := h.taskService.FinishTask()
 	if err != nil {
@@ -117,6 +175,51 @@ func (h *TaskHandler) FinishTaskHandler(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, "/tasks", http.StatusSeeOther)
 }
 
+// UploadAggregationHandler ставит в очередь выгрузку агрегации задания в EZFactory
+func (h *TaskHandler) UploadAggregationHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Некорректный ID задания", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.taskService.UploadAggregation(taskID); err != nil {
+		http.Error(w, "Ошибка при постановке выгрузки: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Ход выгрузки виден в очереди отправки
+	http.Redirect(w, r, "/outbox", http.StatusSeeOther)
+}
+
+// PauseTaskHandler приостанавливает текущее задание вместе с процессорами
+// и освобождает линию для другого задания
+func (h *TaskHandler) PauseTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if h.taskService.GetActiveTaskID() == 0 {
+		http.Error(w, "Нет активного задания", http.StatusBadRequest)
+		return
+	}
+
+	// Несобранный короб сохраняется в базе и восстанавливается при возобновлении
+	if err := h.taskService.PauseTask(); err != nil {
+		http.Error(w, "Ошибка при приостановке задания: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Перенаправляем на список заданий
+	http.Redirect(w, r, "/tasks", http.StatusSeeOther)
+}
+
+// stopAll останавливает процессор линии и все станции задания
+func (h *TaskHandler) stopAll() error {
+	for _, service := range append([]ScanningService{h.scanService}, h.stations...) {
+		if err := service.Stop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Добавляем обработчик для запуска сканирования
 func (h *TaskHandler) StartScanningHandler(w http.ResponseWriter, r *http.Request) {
 	// Проверяем, есть ли активное задание
@@ -129,7 +232,7 @@ func (h *TaskHandler) StartScanningHandler(w http.ResponseWriter, r *http.Reques
 	// Запускаем сканирование
 	err := h.scanService.Start(activeTaskID)
 	if err != nil {
-		http.Error(w, "Ошибка запуска сканирования: "+err.Error(), http.StatusInternalServerError)
+		httpError(w, "Ошибка запуска сканирования", err, http.StatusInternalServerError)
 		return
 	}
 
@@ -137,6 +240,130 @@ func (h *TaskHandler) StartScanningHandler(w http.ResponseWriter, r *http.Reques
 	http.Redirect(w, r, "/active-task", http.StatusSeeOther)
 }
 
+// ScanCountersHandler отображает счетчики сканирования для автообновления
+func (h *TaskHandler) ScanCountersHandler(w http.ResponseWriter, r *http.Request) {
+	templates.ScanCountersPanel(h.scanService.Counters(), h.boxProgress()).Render(r.Context(), w)
+}
+
+// PlanProgressHandler отображает выполнение плана активного задания для автообновления
+func (h *TaskHandler) PlanProgressHandler(w http.ResponseWriter, r *http.Request) {
+	activeTaskID := h.taskService.GetActiveTaskID()
+	if activeTaskID == 0 {
+		http.Error(w, "Нет активного задания", http.StatusBadRequest)
+		return
+	}
+
+	progress, err := h.taskService.GetPlanProgress(activeTaskID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	templates.PlanProgressPanel(progress).Render(r.Context(), w)
+}
+
+// CodePoolHandler отображает загрузку кодов маркировки активного задания для автообновления
+func (h *TaskHandler) CodePoolHandler(w http.ResponseWriter, r *http.Request) {
+	activeTaskID := h.taskService.GetActiveTaskID()
+	if activeTaskID == 0 {
+		http.Error(w, "Нет активного задания", http.StatusBadRequest)
+		return
+	}
+
+	download, usage, downloading, err := h.taskService.GetCodeDownload(activeTaskID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	templates.CodePoolPanel(download, usage, downloading).Render(r.Context(), w)
+}
+
+// DownloadCodesHandler запускает загрузку кодов маркировки активного задания
+func (h *TaskHandler) DownloadCodesHandler(w http.ResponseWriter, r *http.Request) {
+	activeTaskID := h.taskService.GetActiveTaskID()
+	if activeTaskID == 0 {
+		http.Error(w, "Нет активного задания", http.StatusBadRequest)
+		return
+	}
+
+	h.taskService.DownloadCodes(activeTaskID)
+
+	templates.CodePoolPanel(models.CodeDownload{TaskID: activeTaskID}, models.CodeUsage{}, true).Render(r.Context(), w)
+}
+
+// TaskWarningsHandler отображает предупреждения EZFactory по активному заданию для автообновления
+func (h *TaskHandler) TaskWarningsHandler(w http.ResponseWriter, r *http.Request) {
+	warnings := h.taskService.ActiveTaskWarnings()
+	if h.productOutdated() {
+		warnings = append(warnings, productChangedWarning)
+	}
+
+	templates.TaskWarningsPanel(warnings).Render(r.Context(), w)
+}
+
+// productOutdated сообщает, что линия или станция работает по устаревшей карточке продукта
+func (h *TaskHandler) productOutdated() bool {
+	for _, processor := range append([]ScanningService{h.scanService}, h.stations...) {
+		if listener, ok := processor.(ProductChangeListener); ok && listener.ProductOutdated() {
+			return true
+		}
+	}
+	return false
+}
+
+// OfflineBannerHandler отображает предупреждение о работе по данным из кэша, пока EZFactory недоступен
+func (h *TaskHandler) OfflineBannerHandler(w http.ResponseWriter, r *http.Request) {
+	dataAt, offline := h.taskService.OfflineDataTime()
+	templates.OfflineBanner(offline, dataAt).Render(r.Context(), w)
+}
+
+// CameraDiagnosticsHandler отображает диагностику камер для автообновления
+func (h *TaskHandler) CameraDiagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	var statuses []models.CameraStatus
+	if reporter, ok := h.scanService.(CameraDiagnosticsReporter); ok {
+		statuses = reporter.CameraStatuses()
+	}
+
+	templates.CameraDiagnosticsPanel(statuses).Render(r.Context(), w)
+}
+
+// boxProgress возвращает заполнение текущего короба, если процессор собирает короба
+func (h *TaskHandler) boxProgress() *models.BoxProgress {
+	reporter, ok := h.scanService.(BoxProgressReporter)
+	if !ok {
+		return nil
+	}
+
+	progress, ok := reporter.BoxProgress()
+	if !ok {
+		return nil
+	}
+	return &progress
+}
+
+// ClosePartialBoxHandler закрывает текущий неполный короб в конце партии
+func (h *TaskHandler) ClosePartialBoxHandler(w http.ResponseWriter, r *http.Request) {
+	closer, ok := h.scanService.(PartialBoxCloser)
+	if !ok {
+		http.Error(w, "Текущий процессор не собирает короба", http.StatusBadRequest)
+		return
+	}
+
+	container, err := closer.ClosePartialBox()
+	if err != nil && container.ID == 0 {
+		http.Error(w, "Ошибка закрытия короба: "+err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		// Короб сохранен, но этикетка не напечатана
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/active-task", http.StatusSeeOther)
+}
+
 // Добавляем обработчик для остановки сканирования
 func (h *TaskHandler) StopScanningHandler(w http.ResponseWriter, r *http.Request) {
 	// Останавливаем сканирование
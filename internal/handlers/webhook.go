@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ze674/EZLine/internal/api"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/services"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Максимальный размер уведомления EZFactory
+const maxWebhookBody = 1 << 20
+
+// Предупреждение оператору, если карточка продукта изменилась во время работы линии
+const productChangedWarning = "Карточка продукта изменена в EZFactory во время выполнения задания. " +
+	"Линия работает по прежней карточке, перезапустите ее, чтобы применить изменения."
+
+// WebhookHandler принимает уведомления EZFactory о назначении и отмене заданий и изменении продуктов
+type WebhookHandler struct {
+	taskService *services.TaskService
+	secret      []byte                  // Ключ подписи уведомлений, пустой - уведомления не принимаются
+	replays     *api.WebhookReplayGuard // Принятые уведомления, повторная доставка отклоняется
+	processors  []ScanningService       // Процессоры, которые предупреждаются об изменении карточки продукта
+}
+
+// NewWebhookHandler создает обработчик уведомлений EZFactory
+func NewWebhookHandler(taskService *services.TaskService, secret string, processors ...ScanningService) *WebhookHandler {
+	return &WebhookHandler{
+		taskService: taskService,
+		secret:      []byte(secret),
+		replays:     api.NewWebhookReplayGuard(),
+		processors:  processors,
+	}
+}
+
+// FactoryEventHandler проверяет подпись уведомления и применяет его
+func (h *WebhookHandler) FactoryEventHandler(w http.ResponseWriter, r *http.Request) {
+	if len(h.secret) == 0 {
+		http.Error(w, "Уведомления EZFactory не настроены", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "Ошибка чтения уведомления", http.StatusBadRequest)
+		return
+	}
+
+	err = api.VerifyWebhook(h.secret, r.Header.Get(api.WebhookTimestampHeader), r.Header.Get(api.WebhookSignatureHeader), body, time.Now())
+	if err != nil {
+		log.Printf("Отклонено уведомление EZFactory: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var event models.FactoryEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "Ошибка разбора уведомления: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Перехваченное уведомление с действительной подписью можно отправить повторно, пока оно не устарело
+	if err := h.replays.Accept(event.ID, time.Now()); err != nil {
+		log.Printf("Отклонено уведомление EZFactory %s: %v", event.ID, err)
+		status := http.StatusBadRequest
+		if errors.Is(err, api.ErrReplayedWebhook) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	affectsActive, err := h.taskService.ApplyFactoryEvent(event)
+	if err != nil {
+		log.Printf("Ошибка обработки уведомления EZFactory %s: %v", event.Type, err)
+		h.replays.Forget(event.ID)
+
+		// Уведомление без изменений бессмысленно повторять, недоступность данных - повторяем
+		status := http.StatusBadRequest
+		if api.IsUnavailable(err) || errors.Is(err, api.ErrUnauthorized) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	log.Printf("Уведомление EZFactory: %s (задание %d, продукт %d)", event.Type, event.TaskID, event.ProductID)
+
+	if affectsActive {
+		h.warnProcessors(event)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// warnProcessors предупреждает работающие процессоры об изменении активного задания
+func (h *WebhookHandler) warnProcessors(event models.FactoryEvent) {
+	if event.Type == models.FactoryEventTaskCancelled {
+		log.Printf("Активное задание %d отменено в EZFactory", event.TaskID)
+		return
+	}
+
+	if event.Type != models.FactoryEventProductUpdated {
+		return
+	}
+
+	for _, processor := range h.processors {
+		listener, ok := processor.(ProductChangeListener)
+		if ok && listener.ProductChanged(event.ProductID) {
+			log.Printf("Карточка продукта %d изменена во время работы линии", event.ProductID)
+		}
+	}
+}
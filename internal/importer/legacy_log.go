@@ -0,0 +1,110 @@
+// Package importer разбирает журналы агрегации, которые линии вели до EZLine
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"github.com/ze674/EZLine/internal/markingcode"
+	"github.com/ze674/EZLine/internal/models"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Формат времени в строке короба журнала
+const legacyTimeLayout = "2006-01-02 15:04:05"
+
+// Строка короба: код короба и время закрытия в квадратных скобках
+var legacyBoxLine = regexp.MustCompile(`^(\S+)\s+\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\]$`)
+
+// ParseLegacyLog разбирает журнал агрегации формата input.txt:
+//
+//	460705476616431032025415000001 [2025-03-31 08:36:18]
+//	   0104607054766164215+5DqV93vHpW
+//	   010460705476616421520gr:936IIq
+//
+// Строка без отступа - код короба и время его закрытия, строки с отступом - коды товаров этого короба.
+// Код товара должен начинаться с (01) GTIN (21) серийный номер; если задана serialLength,
+// серийный номер кода без разделителей GS должен быть не короче нее.
+// Нераспознанные строки, некорректные коды и короба без товаров пропускаются и возвращаются как проблемы
+func ParseLegacyLog(r io.Reader, file string, serialLength int) ([]models.LegacyBox, []models.ImportProblem, error) {
+	var boxes []models.LegacyBox
+	var problems []models.ImportProblem
+
+	var current *models.LegacyBox
+	skipping := false // Товары нераспознанного короба пропускаются без отдельных сообщений
+
+	// closeBox добавляет разобранный короб в результат
+	closeBox := func() {
+		if current == nil {
+			return
+		}
+		if len(current.Items) == 0 {
+			problems = append(problems, models.ImportProblem{File: file, Line: current.Line, Code: current.Code, Message: "короб без товаров"})
+		} else {
+			boxes = append(boxes, *current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r\n ")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// Строка с отступом - код товара
+		if line[0] == ' ' || line[0] == '\t' {
+			code := strings.TrimSpace(line)
+			switch {
+			case skipping:
+			case current == nil:
+				problems = append(problems, models.ImportProblem{File: file, Line: lineNumber, Code: code, Message: "код товара вне короба"})
+			case strings.ContainsAny(code, " \t"):
+				problems = append(problems, models.ImportProblem{File: file, Line: lineNumber, Code: code, Message: "некорректный код товара"})
+			case !isMarkingCode(code, serialLength):
+				problems = append(problems, models.ImportProblem{File: file, Line: lineNumber, Code: code, Message: "код товара не является кодом маркировки"})
+			default:
+				current.Items = append(current.Items, models.LegacyItem{Code: code, Line: lineNumber})
+			}
+			continue
+		}
+
+		closeBox()
+
+		match := legacyBoxLine.FindStringSubmatch(line)
+		if match == nil {
+			problems = append(problems, models.ImportProblem{File: file, Line: lineNumber, Code: line, Message: "строка короба не распознана, товары короба пропущены"})
+			skipping = true
+			continue
+		}
+
+		closedAt, err := time.ParseInLocation(legacyTimeLayout, match[2], time.Local)
+		if err != nil {
+			problems = append(problems, models.ImportProblem{File: file, Line: lineNumber, Code: line, Message: "некорректное время короба, товары короба пропущены"})
+			skipping = true
+			continue
+		}
+
+		skipping = false
+		current = &models.LegacyBox{Code: match[1], ClosedAt: closedAt, File: file, Line: lineNumber}
+	}
+	closeBox()
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return boxes, problems, nil
+}
+
+// isMarkingCode проверяет структуру кода маркировки. Без длины серийного номера код без разделителей GS
+// проверяется только до серийного номера: границу серийного номера и криптохвоста определить нельзя
+func isMarkingCode(code string, serialLength int) bool {
+	_, err := markingcode.Parse(code, serialLength)
+	return err == nil || errors.Is(err, markingcode.ErrSerialBoundary)
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+const legacyLog = `460705476616431032025415000001 [2025-03-31 08:36:18]
+   0104607054766164215+5DqV93vHpW
+   010460705476616421520gr:936IIq
+   460705476616431032025415000009
+   01046070547661642
+460705476616431032025415000002 [2025-03-31 08:37:02]
+   0104607054766164215AAAAA93BBBB
+`
+
+func TestParseLegacyLogRejectsInvalidItemCodes(t *testing.T) {
+	boxes, problems, err := ParseLegacyLog(strings.NewReader(legacyLog), "input.txt", 0)
+	if err != nil {
+		t.Fatalf("ParseLegacyLog: %v", err)
+	}
+
+	if len(boxes) != 2 {
+		t.Fatalf("коробов = %d, ожидалось 2", len(boxes))
+	}
+	if len(boxes[0].Items) != 2 || boxes[0].Items[1].Code != "010460705476616421520gr:936IIq" {
+		t.Errorf("товары первого короба = %+v", boxes[0].Items)
+	}
+
+	if len(problems) != 2 {
+		t.Fatalf("проблемы = %+v, ожидалось 2", problems)
+	}
+	for i, line := range []int{4, 5} {
+		if problems[i].Line != line {
+			t.Errorf("проблема %d в строке %d, ожидалась строка %d", i, problems[i].Line, line)
+		}
+	}
+}
+
+func TestParseLegacyLogChecksSerialLength(t *testing.T) {
+	// Серийный номер второго короба короче 13 символов
+	_, problems, err := ParseLegacyLog(strings.NewReader(legacyLog), "input.txt", 13)
+	if err != nil {
+		t.Fatalf("ParseLegacyLog: %v", err)
+	}
+
+	found := false
+	for _, problem := range problems {
+		if problem.Line == 7 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("код короче серийного номера не отклонен: %+v", problems)
+	}
+}
@@ -0,0 +1,82 @@
+// Package markingcode разбирает коды маркировки Честного ЗНАКа в формате GS1 DataMatrix
+package markingcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GroupSeparator - разделитель элементов GS1 (FNC1), после серийного номера начинается криптохвост
+const GroupSeparator = "\x1d"
+
+// Префиксы идентификатора символики, которые добавляют некоторые сканеры
+var symbologyPrefixes = []string{"]d2", "]C1", "]Q3", "]e0"}
+
+// ErrSerialBoundary возвращается, если в коде без разделителей GS нельзя найти конец серийного номера
+var ErrSerialBoundary = errors.New("в коде нет разделителя GS, задайте длину серийного номера")
+
+// Code - код маркировки, разобранный на элементы GS1
+type Code struct {
+	GTIN       string // (01) GTIN товара
+	Serial     string // (21) Серийный номер
+	CryptoTail string // Ключ и код проверки (91, 92, 93), без разделителей
+}
+
+// KI возвращает код идентификации без криптохвоста: 01 + GTIN + 21 + серийный номер
+func (c Code) KI() string {
+	return "01" + c.GTIN + "21" + c.Serial
+}
+
+// Parse разбирает код маркировки в формате сканера: с разделителями GS или без них.
+// Если разделителей нет, конец серийного номера определяется по serialLength. При serialLength 0
+// такой код не разбирается: без разделителя криптохвост нельзя отличить от серийного номера
+func Parse(code string, serialLength int) (Code, error) {
+	raw := strings.TrimPrefix(TrimSymbology(code), GroupSeparator)
+
+	if len(raw) < 19 || raw[:2] != "01" || !isDigits(raw[2:16]) || raw[16:18] != "21" {
+		return Code{}, fmt.Errorf("код %q не начинается с (01) GTIN (21) серийный номер", code)
+	}
+
+	result := Code{GTIN: raw[2:16]}
+	rest := raw[18:]
+
+	switch {
+	case strings.Contains(rest, GroupSeparator):
+		parts := strings.SplitN(rest, GroupSeparator, 2)
+		result.Serial = parts[0]
+		result.CryptoTail = strings.ReplaceAll(parts[1], GroupSeparator, "")
+	case serialLength > 0:
+		if len(rest) < serialLength {
+			return Code{}, fmt.Errorf("код %q короче серийного номера длиной %d", code, serialLength)
+		}
+		result.Serial = rest[:serialLength]
+		result.CryptoTail = rest[serialLength:]
+	default:
+		return Code{}, fmt.Errorf("код %q: %w", code, ErrSerialBoundary)
+	}
+
+	if result.Serial == "" {
+		return Code{}, fmt.Errorf("код %q без серийного номера", code)
+	}
+
+	return result, nil
+}
+
+// TrimSymbology убирает префикс идентификатора символики, который добавляют некоторые сканеры
+func TrimSymbology(code string) string {
+	for _, prefix := range symbologyPrefixes {
+		code = strings.TrimPrefix(code, prefix)
+	}
+	return code
+}
+
+// isDigits сообщает, состоит ли строка только из цифр
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
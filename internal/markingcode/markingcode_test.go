@@ -0,0 +1,108 @@
+package markingcode
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         string
+		serialLength int
+		want         Code
+		wantErr      error
+	}{
+		{"с разделителем GS", "0104607054766164215+5DqV\x1d93vHpW", 0,
+			Code{GTIN: "04607054766164", Serial: "5+5DqV", CryptoTail: "93vHpW"}, nil},
+		{"с FNC1 и двумя элементами криптохвоста", "\x1d0104607054766164215+5DqV\x1d91EE10\x1d92dGVzdA==", 0,
+			Code{GTIN: "04607054766164", Serial: "5+5DqV", CryptoTail: "91EE1092dGVzdA=="}, nil},
+		{"с префиксом символики", "]C10104607054766164215+5DqV\x1d93vHpW", 0,
+			Code{GTIN: "04607054766164", Serial: "5+5DqV", CryptoTail: "93vHpW"}, nil},
+		{"без GS по длине серийного номера", "0104607054766164215+5DqV93vHpW", 6,
+			Code{GTIN: "04607054766164", Serial: "5+5DqV", CryptoTail: "93vHpW"}, nil},
+		{"код идентификации без криптохвоста", "0104607054766164215+5DqV", 6,
+			Code{GTIN: "04607054766164", Serial: "5+5DqV"}, nil},
+		{"без GS и длины серийного номера", "0104607054766164215+5DqV93vHpW", 0, Code{}, ErrSerialBoundary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.code, tt.serialLength)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ошибка = %v, ожидалась %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidCode(t *testing.T) {
+	for _, code := range []string{
+		"",
+		"460705476616431032025415000001",  // код короба
+		"01046070547661642",               // обрыв перед серийным номером
+		"010460705476616A215+5DqV\x1d93x", // буква в GTIN
+		"010460705476616421\x1d93vHpW",    // пустой серийный номер
+		"0104607054766164215+5D",          // короче серийного номера
+	} {
+		if _, err := Parse(code, 6); err == nil {
+			t.Errorf("код %q разобран без ошибки", code)
+		}
+	}
+}
+
+func TestCodeKI(t *testing.T) {
+	code := Code{GTIN: "04607054766164", Serial: "5+5DqV", CryptoTail: "93vHpW"}
+	if got := code.KI(); got != "0104607054766164215+5DqV" {
+		t.Errorf("KI = %q", got)
+	}
+}
+
+func TestSSCCPrefixSSCC(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix SSCCPrefix
+		serial int64
+		want   string
+		valid  bool
+	}{
+		{"префикс из 7 цифр", SSCCPrefix{Extension: 1, CompanyPrefix: "4607054"}, 1, "146070540000000012", true},
+		{"наибольший номер", SSCCPrefix{Extension: 0, CompanyPrefix: "460705476616"}, 9999, "046070547661699992", true},
+		{"номер не помещается", SSCCPrefix{Extension: 0, CompanyPrefix: "460705476616"}, 10000, "", false},
+		{"цифра расширения вне диапазона", SSCCPrefix{Extension: 10, CompanyPrefix: "4607054"}, 1, "", false},
+		{"префикс с буквой", SSCCPrefix{Extension: 1, CompanyPrefix: "46070A4"}, 1, "", false},
+		{"короткий префикс", SSCCPrefix{Extension: 1, CompanyPrefix: "46070"}, 1, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.prefix.SSCC(tt.serial)
+			if tt.valid != (err == nil) {
+				t.Fatalf("ошибка = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SSCC = %q, ожидалось %q", got, tt.want)
+			}
+			if tt.valid && !HasValidCheckDigit(got) {
+				t.Errorf("SSCC %s с неверной контрольной цифрой", got)
+			}
+		})
+	}
+}
+
+func TestHasValidCheckDigit(t *testing.T) {
+	for number, valid := range map[string]bool{
+		"04607054766164":     true,
+		"04607054766165":     false,
+		"046070547612345671": true,
+		"04607054761234567A": false,
+		"4":                  false,
+	} {
+		if got := HasValidCheckDigit(number); got != valid {
+			t.Errorf("HasValidCheckDigit(%q) = %v", number, got)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package markingcode
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Длина SSCC без идентификатора применения (00)
+const ssccLength = 18
+
+// SSCCPrefix - начало SSCC транспортных упаковок линии: цифра расширения и префикс компании GS1.
+// Линии с общим префиксом компании должны использовать разные цифры расширения
+type SSCCPrefix struct {
+	Extension     int    // Цифра расширения 0-9
+	CompanyPrefix string // Префикс компании GS1, 6-12 цифр
+}
+
+// IsZero сообщает, что префикс не задан и SSCC не формируются
+func (p SSCCPrefix) IsZero() bool {
+	return p.CompanyPrefix == ""
+}
+
+// Validate проверяет цифру расширения и префикс компании
+func (p SSCCPrefix) Validate() error {
+	if p.Extension < 0 || p.Extension > 9 {
+		return fmt.Errorf("цифра расширения SSCC %d вне диапазона 0-9", p.Extension)
+	}
+	if len(p.CompanyPrefix) < 6 || len(p.CompanyPrefix) > 12 || !isDigits(p.CompanyPrefix) {
+		return fmt.Errorf("префикс компании GS1 %q должен состоять из 6-12 цифр", p.CompanyPrefix)
+	}
+	return nil
+}
+
+// SSCC возвращает 18-значный SSCC упаковки с номером serial: цифра расширения, префикс компании,
+// номер, дополненный нулями до 16 цифр вместе с префиксом, и контрольная цифра
+func (p SSCCPrefix) SSCC(serial int64) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	width := ssccLength - 2 - len(p.CompanyPrefix)
+	reference := strconv.FormatInt(serial, 10)
+	if serial < 0 || len(reference) > width {
+		return "", fmt.Errorf("номер упаковки %d не помещается в SSCC с префиксом %s", serial, p.CompanyPrefix)
+	}
+
+	number := fmt.Sprintf("%d%s%0*d", p.Extension, p.CompanyPrefix, width, serial)
+	return number + strconv.Itoa(checkDigit(number)), nil
+}
+
+// HasValidCheckDigit проверяет последнюю цифру номера GS1 (GTIN, SSCC)
+func HasValidCheckDigit(number string) bool {
+	if len(number) < 2 || !isDigits(number) {
+		return false
+	}
+	return checkDigit(number[:len(number)-1]) == int(number[len(number)-1]-'0')
+}
+
+// checkDigit вычисляет контрольную цифру GS1 по алгоритму mod 10:
+// цифры справа налево, начиная с последней, умножаются поочередно на 3 и 1
+func checkDigit(digits string) int {
+	sum := 0
+	weight := 3
+	for i := len(digits) - 1; i >= 0; i-- {
+		sum += int(digits[i]-'0') * weight
+		weight = 4 - weight
+	}
+	return (10 - sum%10) % 10
+}
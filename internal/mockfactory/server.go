@@ -0,0 +1,612 @@
+// Package mockfactory реализует имитацию API EZFactory для локальной разработки и интеграционной проверки.
+// Сервер отвечает в формате api.Response, берет задания, продукты и коды маркировки из JSON-файла,
+// запоминает изменения статусов, выгрузки агрегации, отчеты по кодам и состояние линий
+// и отправляет линии уведомления
+package mockfactory
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/ze674/EZLine/internal/api"
+	"github.com/ze674/EZLine/internal/models"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fixture содержит начальные данные сервера
+type Fixture struct {
+	Tasks    []models.Task    `json:"tasks"`
+	Products []models.Product `json:"products"`
+	Codes    map[int][]string `json:"codes"` // Коды маркировки, выданные для заданий
+
+	// Ключи линий для получения токенов, если заданы - запросы без действующего токена отклоняются
+	LineKeys map[int]string `json:"line_keys"`
+}
+
+// StatusChange - изменение статуса задания, полученное от линии
+type StatusChange struct {
+	TaskID         int       `json:"task_id"`
+	Status         string    `json:"status"`
+	IdempotencyKey string    `json:"idempotency_key"`
+	At             time.Time `json:"at"`
+}
+
+// AggregationUpload - часть агрегации, полученная от линии
+type AggregationUpload struct {
+	Chunk          models.AggregationChunk `json:"chunk"`
+	IdempotencyKey string                  `json:"idempotency_key"`
+	At             time.Time               `json:"at"`
+}
+
+// CodeReport - отчет об использовании кодов, полученный от линии
+type CodeReport struct {
+	Report         models.CodeUsageReport `json:"report"`
+	IdempotencyKey string                 `json:"idempotency_key"`
+	At             time.Time              `json:"at"`
+}
+
+// Server хранит задания и продукты в памяти и отвечает на запросы EZLine
+type Server struct {
+	mu            sync.Mutex
+	tasks         map[int]models.Task
+	products      map[int]models.Product
+	codes         map[int][]string
+	statusChanges []StatusChange
+	uploads       []AggregationUpload
+	codeReports   []CodeReport
+	heartbeats    []models.LineHeartbeat
+	seenKeys      map[string]bool // Обработанные ключи идемпотентности
+	lineKeys      map[int]string
+	tokens        map[string]issuedToken // Выданные токены доступа
+	tokenTTL      time.Duration
+	webhookURL    string // Адрес уведомлений линии, пустой - уведомления не отправляются
+	webhookSecret string
+	httpClient    *http.Client
+}
+
+// EventRequest - служебный запрос на отправку уведомления линии.
+// Если задано задание или продукт, сервер сначала заменяет его и затем уведомляет линию
+type EventRequest struct {
+	Event   models.FactoryEvent `json:"event"`
+	Task    *models.Task        `json:"task,omitempty"`
+	Product *models.Product     `json:"product,omitempty"`
+}
+
+// issuedToken - выданный линии токен доступа
+type issuedToken struct {
+	lineID    int
+	expiresAt time.Time
+}
+
+// LoadFixture читает начальные данные из JSON-файла
+func LoadFixture(path string) (Fixture, error) {
+	var fixture Fixture
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fixture, fmt.Errorf("ошибка чтения файла данных: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return fixture, fmt.Errorf("ошибка разбора файла данных: %w", err)
+	}
+
+	return fixture, nil
+}
+
+// NewServer создает сервер с начальными данными
+func NewServer(fixture Fixture) *Server {
+	s := &Server{
+		tasks:    make(map[int]models.Task),
+		products: make(map[int]models.Product),
+		codes:    make(map[int][]string),
+		seenKeys: make(map[string]bool),
+		lineKeys: make(map[int]string),
+		tokens:   make(map[string]issuedToken),
+		tokenTTL: time.Hour,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+
+	for _, task := range fixture.Tasks {
+		if task.Status == "" {
+			task.Status = models.TaskStatusNew
+		}
+		s.tasks[task.ID] = task
+	}
+	for _, product := range fixture.Products {
+		s.products[product.ID] = product
+	}
+	for taskID, codes := range fixture.Codes {
+		s.codes[taskID] = codes
+	}
+	for lineID, key := range fixture.LineKeys {
+		s.lineKeys[lineID] = key
+	}
+
+	return s
+}
+
+// Handler возвращает маршруты API
+func (s *Server) Handler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Post("/api/auth/token", s.tokenHandler)
+
+	r.Route("/api", func(r chi.Router) {
+		r.Use(s.authMiddleware)
+
+		r.Get("/tasks", s.listTasksHandler)
+		r.Get("/tasks/{id}", s.taskHandler)
+		r.Post("/tasks/{id}/status", s.updateStatusHandler)
+		r.Post("/tasks/{id}/aggregation", s.aggregationHandler)
+		r.Get("/tasks/{id}/codes", s.codesHandler)
+		r.Post("/tasks/{id}/codes/report", s.codeReportHandler)
+		r.Post("/lines/{id}/heartbeats", s.heartbeatsHandler)
+		r.Get("/product/{id}", s.productHandler)
+	})
+
+	// Служебные маршруты для проверки того, что отправила линия
+	r.Route("/mock", func(r chi.Router) {
+		r.Get("/status-changes", s.statusChangesHandler)
+		r.Get("/uploads", s.uploadsHandler)
+		r.Get("/code-reports", s.codeReportsHandler)
+		r.Get("/heartbeats", s.heartbeatListHandler)
+		r.Post("/events", s.eventHandler)
+	})
+
+	return r
+}
+
+// SetTokenTTL задает срок действия выдаваемых токенов
+func (s *Server) SetTokenTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokenTTL = ttl
+}
+
+// SetWebhook задает адрес и ключ подписи уведомлений линии
+func (s *Server) SetWebhook(url string, secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhookURL = url
+	s.webhookSecret = secret
+}
+
+// Notify отправляет линии подписанное уведомление и возвращает его ID
+func (s *Server) Notify(event models.FactoryEvent) (string, error) {
+	s.mu.Lock()
+	url, secret := s.webhookURL, s.webhookSecret
+	s.mu.Unlock()
+
+	if url == "" {
+		return "", fmt.Errorf("адрес уведомлений не задан")
+	}
+
+	if event.ID == "" {
+		id := make([]byte, 8)
+		_, _ = rand.Read(id)
+		event.ID = hex.EncodeToString(id)
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("ошибка кодирования уведомления: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(api.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(api.WebhookSignatureHeader, api.SignWebhook([]byte(secret), timestamp, body))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка отправки уведомления: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("линия отклонила уведомление: HTTP %d", resp.StatusCode)
+	}
+
+	return event.ID, nil
+}
+
+// StatusChanges возвращает полученные изменения статусов в порядке поступления
+func (s *Server) StatusChanges() []StatusChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]StatusChange, len(s.statusChanges))
+	copy(result, s.statusChanges)
+	return result
+}
+
+// Uploads возвращает полученные части агрегации в порядке поступления
+func (s *Server) Uploads() []AggregationUpload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]AggregationUpload, len(s.uploads))
+	copy(result, s.uploads)
+	return result
+}
+
+// CodeReports возвращает полученные отчеты об использовании кодов в порядке поступления
+func (s *Server) CodeReports() []CodeReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]CodeReport, len(s.codeReports))
+	copy(result, s.codeReports)
+	return result
+}
+
+// Heartbeats возвращает полученные снимки состояния линий в порядке поступления
+func (s *Server) Heartbeats() []models.LineHeartbeat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]models.LineHeartbeat, len(s.heartbeats))
+	copy(result, s.heartbeats)
+	return result
+}
+
+// tokenHandler выдает временный токен линии по ее ключу
+func (s *Server) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	lineID, _ := strconv.Atoi(r.FormValue("line_id"))
+	apiKey := r.FormValue("api_key")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.lineKeys[lineID]
+	if !ok || key != apiKey {
+		writeError(w, http.StatusUnauthorized, "неверный ключ линии")
+		return
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	token := hex.EncodeToString(buf)
+	s.tokens[token] = issuedToken{lineID: lineID, expiresAt: time.Now().Add(s.tokenTTL)}
+
+	writeData(w, api.AccessToken{Token: token, ExpiresIn: int(s.tokenTTL.Seconds())})
+}
+
+// authMiddleware пропускает запросы с действующим токеном, если заданы ключи линий
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		required := len(s.lineKeys) > 0
+		issued, ok := s.tokens[token]
+		s.mu.Unlock()
+
+		if required && (!ok || time.Now().After(issued.expiresAt)) {
+			writeError(w, http.StatusUnauthorized, "требуется действующий токен линии")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) listTasksHandler(w http.ResponseWriter, r *http.Request) {
+	lineID, _ := strconv.Atoi(r.URL.Query().Get("line_id"))
+
+	s.mu.Lock()
+	tasks := make([]models.Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		if lineID == 0 || task.LineID == lineID {
+			tasks = append(tasks, task)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+
+	writeData(w, tasks)
+}
+
+func (s *Server) taskHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный ID задания")
+		return
+	}
+
+	s.mu.Lock()
+	task, ok := s.tasks[id]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("задание %d не найдено", id))
+		return
+	}
+
+	writeData(w, task)
+}
+
+func (s *Server) updateStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный ID задания")
+		return
+	}
+
+	status := r.FormValue("status")
+	if status == "" {
+		writeError(w, http.StatusBadRequest, "не указан статус")
+		return
+	}
+
+	key := r.Header.Get(api.IdempotencyKeyHeader)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("задание %d не найдено", id))
+		return
+	}
+
+	// Повтор уже обработанного запроса не применяется повторно
+	if key != "" && s.seenKeys[key] {
+		writeData(w, task)
+		return
+	}
+
+	task.Status = status
+	s.tasks[id] = task
+	s.statusChanges = append(s.statusChanges, StatusChange{TaskID: id, Status: status, IdempotencyKey: key, At: time.Now()})
+	if key != "" {
+		s.seenKeys[key] = true
+	}
+
+	writeData(w, task)
+}
+
+func (s *Server) aggregationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный ID задания")
+		return
+	}
+
+	var chunk models.AggregationChunk
+	if err := json.NewDecoder(r.Body).Decode(&chunk); err != nil {
+		writeError(w, http.StatusBadRequest, "ошибка разбора агрегации: "+err.Error())
+		return
+	}
+	if chunk.TaskID != id {
+		writeError(w, http.StatusBadRequest, "ID задания в запросе не совпадает с адресом")
+		return
+	}
+
+	key := r.Header.Get(api.IdempotencyKeyHeader)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tasks[id]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("задание %d не найдено", id))
+		return
+	}
+
+	if key == "" || !s.seenKeys[key] {
+		s.uploads = append(s.uploads, AggregationUpload{Chunk: chunk, IdempotencyKey: key, At: time.Now()})
+		if key != "" {
+			s.seenKeys[key] = true
+		}
+	}
+
+	writeData(w, nil)
+}
+
+// codesHandler отдает коды задания страницами, курсором служит смещение следующей страницы
+func (s *Server) codesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный ID задания")
+		return
+	}
+
+	offset := 0
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "некорректный курсор")
+			return
+		}
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 1000
+	}
+
+	s.mu.Lock()
+	_, ok := s.tasks[id]
+	codes := s.codes[id]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("задание %d не найдено", id))
+		return
+	}
+
+	page := models.CodePage{Codes: []string{}}
+	if offset < len(codes) {
+		end := min(offset+limit, len(codes))
+		page.Codes = codes[offset:end]
+		if end < len(codes) {
+			page.NextCursor = strconv.Itoa(end)
+		}
+	}
+
+	writeData(w, page)
+}
+
+func (s *Server) codeReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный ID задания")
+		return
+	}
+
+	var report models.CodeUsageReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		writeError(w, http.StatusBadRequest, "ошибка разбора отчета: "+err.Error())
+		return
+	}
+	if report.TaskID != id {
+		writeError(w, http.StatusBadRequest, "ID задания в запросе не совпадает с адресом")
+		return
+	}
+
+	key := r.Header.Get(api.IdempotencyKeyHeader)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tasks[id]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("задание %d не найдено", id))
+		return
+	}
+
+	if key == "" || !s.seenKeys[key] {
+		s.codeReports = append(s.codeReports, CodeReport{Report: report, IdempotencyKey: key, At: time.Now()})
+		if key != "" {
+			s.seenKeys[key] = true
+		}
+	}
+
+	writeData(w, nil)
+}
+
+func (s *Server) heartbeatsHandler(w http.ResponseWriter, r *http.Request) {
+	var heartbeats []models.LineHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&heartbeats); err != nil {
+		writeError(w, http.StatusBadRequest, "некорректное состояние линии")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Снимки уникальны, повторно отправленные пропускаются
+	for _, heartbeat := range heartbeats {
+		key := "heartbeat:" + heartbeat.ID
+		if s.seenKeys[key] {
+			continue
+		}
+		s.seenKeys[key] = true
+		s.heartbeats = append(s.heartbeats, heartbeat)
+	}
+
+	writeData(w, nil)
+}
+
+func (s *Server) productHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный ID продукта")
+		return
+	}
+
+	s.mu.Lock()
+	product, ok := s.products[id]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("продукт %d не найден", id))
+		return
+	}
+
+	writeData(w, product)
+}
+
+func (s *Server) statusChangesHandler(w http.ResponseWriter, r *http.Request) {
+	writeData(w, s.StatusChanges())
+}
+
+func (s *Server) uploadsHandler(w http.ResponseWriter, r *http.Request) {
+	writeData(w, s.Uploads())
+}
+
+func (s *Server) codeReportsHandler(w http.ResponseWriter, r *http.Request) {
+	writeData(w, s.CodeReports())
+}
+
+func (s *Server) heartbeatListHandler(w http.ResponseWriter, r *http.Request) {
+	writeData(w, s.Heartbeats())
+}
+
+func (s *Server) eventHandler(w http.ResponseWriter, r *http.Request) {
+	var req EventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный запрос")
+		return
+	}
+
+	s.mu.Lock()
+	if req.Task != nil {
+		s.tasks[req.Task.ID] = *req.Task
+	}
+	if req.Product != nil {
+		s.products[req.Product.ID] = *req.Product
+	}
+	s.mu.Unlock()
+
+	id, err := s.Notify(req.Event)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	req.Event.ID = id
+
+	writeData(w, req.Event)
+}
+
+// writeData отправляет успешный ответ в формате api.Response
+func writeData(w http.ResponseWriter, data any) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeResponse(w, http.StatusOK, api.Response{Success: true, Data: raw})
+}
+
+// writeError отправляет ответ с ошибкой в формате api.Response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeResponse(w, status, api.Response{Success: false, Error: message})
+}
+
+func writeResponse(w http.ResponseWriter, status int, response api.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,24 @@
+package models
+
+// AggregationContainer описывает короб и вложенные в него коды для выгрузки в EZFactory
+type AggregationContainer struct {
+	ID           int64    `json:"-"`
+	Code         string   `json:"code"`
+	SSCC         string   `json:"sscc,omitempty"` // SSCC короба без (00), пусто - префикс компании не задан
+	SerialNumber int      `json:"serial_number"`
+	Status       string   `json:"status"`  // Расформированный короб выгружается без кодов
+	Partial      bool     `json:"partial"` // Короб закрыт оператором до заполнения
+	Items        []string `json:"items"`
+}
+
+// AggregationChunk - часть иерархии короб -> товары задания, отправляемая одним запросом
+type AggregationChunk struct {
+	TaskID     int                    `json:"task_id"`
+	Containers []AggregationContainer `json:"containers"`
+}
+
+// AggregationUploadPayload содержит данные сообщения о выгрузке агрегации задания
+type AggregationUploadPayload struct {
+	TaskID       int  `json:"task_id"`
+	MarkTaskSent bool `json:"mark_task_sent"` // После выгрузки перевести задание в статус "отправлено"
+}
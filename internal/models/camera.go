@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// CameraStatus содержит диагностику камеры из группы, снимающей один слой
+type CameraStatus struct {
+	Name       string    `json:"name"`
+	Reads      int       `json:"reads"`       // Количество срабатываний
+	Misses     int       `json:"misses"`      // Срабатывания без кодов: таймаут, ошибка или NoRead
+	LastCodes  int       `json:"last_codes"`  // Кодов в последнем ответе
+	LastMissed bool      `json:"last_missed"` // Последнее срабатывание без кодов
+	LastError  string    `json:"last_error"`  // Причина последнего промаха
+	LastAt     time.Time `json:"last_at"`
+}
@@ -5,10 +5,15 @@ import "time"
 
 // internal/models/container.go
 type Container struct {
-	ID           int64     `json:"id"`
-	Code         string    `json:"code"`
-	SerialNumber int       `json:"serial_number"` // Числовое поле
-	TaskID       int       `json:"task_id"`
-	Status       string    `json:"status"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID           int64      `json:"id"`
+	Code         string     `json:"code"`
+	SSCC         string     `json:"sscc"`          // SSCC короба без (00), пусто - префикс компании не задан
+	SerialNumber int        `json:"serial_number"` // Числовое поле
+	TaskID       int        `json:"task_id"`
+	Status       string     `json:"status"`
+	ItemsCount   int        `json:"items_count"`  // Количество товаров в контейнере
+	Partial      bool       `json:"partial"`      // Короб закрыт оператором до заполнения
+	LabelStatus  string     `json:"label_status"` // Результат проверки этикетки, пусто - не проверялась
+	CreatedAt    time.Time  `json:"created_at"`
+	SentAt       *time.Time `json:"sent_at"` // Время выгрузки в EZFactory, nil - не выгружен
 }
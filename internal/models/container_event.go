@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Операции с контейнером
+const (
+	ContainerActionRemoveItem      = "remove_item"
+	ContainerActionReplaceItem     = "replace_item"
+	ContainerActionAddItem         = "add_item"
+	ContainerActionDisband         = "disband"
+	ContainerActionClosePartial    = "close_partial"
+	ContainerActionLabelUnverified = "label_unverified"
+	ContainerActionImport          = "import"
+)
+
+// ContainerActionTitles содержит человекочитаемые названия операций
+var ContainerActionTitles = map[string]string{
+	ContainerActionRemoveItem:      "Удаление товара",
+	ContainerActionReplaceItem:     "Замена товара",
+	ContainerActionAddItem:         "Добавление товара",
+	ContainerActionDisband:         "Расформирование",
+	ContainerActionClosePartial:    "Закрытие неполного короба",
+	ContainerActionLabelUnverified: "Этикетка не подтверждена",
+	ContainerActionImport:          "Импорт из журнала",
+}
+
+// ContainerEvent представляет запись журнала изменений контейнера
+type ContainerEvent struct {
+	ID          int64     `json:"id"`
+	ContainerID int64     `json:"container_id"`
+	TaskID      int       `json:"task_id"`
+	Action      string    `json:"action"`
+	ItemCode    string    `json:"item_code"`
+	NewItemCode string    `json:"new_item_code"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ActionTitle возвращает название операции
+func (e ContainerEvent) ActionTitle() string {
+	if title, ok := ContainerActionTitles[e.Action]; ok {
+		return title
+	}
+	return e.Action
+}
@@ -0,0 +1,17 @@
+package models
+
+// Типы уведомлений EZFactory
+const (
+	FactoryEventTaskAssigned   = "task_assigned"   // Линии назначено задание или задание изменено
+	FactoryEventTaskCancelled  = "task_cancelled"  // Задание отменено
+	FactoryEventProductUpdated = "product_updated" // Изменена карточка продукта
+)
+
+// FactoryEvent - уведомление EZFactory. Актуальные данные задания или продукта
+// линия запрашивает сама, поэтому повтор уведомления безопасен
+type FactoryEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	TaskID    int    `json:"task_id,omitempty"`
+	ProductID int    `json:"product_id,omitempty"`
+}
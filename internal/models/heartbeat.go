@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// LineHeartbeat - снимок состояния линии, периодически отправляемый в EZFactory.
+// Снимки, сделанные без связи, отправляются позже, поэтому время снимка передается явно
+type LineHeartbeat struct {
+	ID           string               `json:"id"` // Уникален для снимка, EZFactory отбрасывает повторы
+	LineID       int                  `json:"line_id"`
+	At           time.Time            `json:"at"`
+	Version      string               `json:"version"` // Версия EZLine
+	ActiveTaskID int                  `json:"active_task_id"`
+	Processors   []ProcessorHeartbeat `json:"processors"`
+	Devices      []DeviceHealth       `json:"devices"`
+	Counters     HeartbeatCounters    `json:"counters"`
+}
+
+// ProcessorHeartbeat содержит состояние процессора линии или станции
+type ProcessorHeartbeat struct {
+	Name   string         `json:"name"`
+	State  ProcessorState `json:"state"`
+	Reason string         `json:"reason,omitempty"` // Причина последнего перехода, для аварии - текст ошибки
+	Since  time.Time      `json:"since"`
+}
+
+// DeviceHealth содержит состояние оборудования, по которому линия ведет диагностику: камеры, ПЛК, принтер.
+// Состояние - результат последнего обмена с устройством, LastAt показывает его давность.
+// Ошибки подключения остального оборудования переводят процессор в аварию и передаются в его причине
+type DeviceHealth struct {
+	Name   string    `json:"name"`
+	OK     bool      `json:"ok"`
+	Error  string    `json:"error,omitempty"`
+	LastAt time.Time `json:"last_at"`
+}
+
+// HeartbeatCounters содержит счетчики активного задания
+type HeartbeatCounters struct {
+	Boxes   int `json:"boxes"`   // Закрыто коробов
+	Items   int `json:"items"`   // Произведено единиц продукции
+	Rejects int `json:"rejects"` // Отклонено за все задание по журналу сканирования
+}
@@ -3,6 +3,9 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,6 +21,13 @@ type LabelData struct {
 	QuantityBox string // Количество в коробке (шт)
 	WeightBox   string // Вес коробки (кг)
 
+	// Параметры укладки
+	QuantityLayer string // Количество в слое (шт)
+	LayersBox     string // Количество слоев в коробке
+
+	// Профиль продукта
+	Mode string // Режим работы линии для продукта, если отличается от настроек линии
+
 	// Информация о задании
 	Date        string // Дата производства в формате ДД.ММ.ГГГГ
 	BatchNumber string // Номер партии
@@ -25,6 +35,7 @@ type LabelData struct {
 	// Дополнительная информация
 	Packer       string // Упаковщик
 	SerialNumber string // Серийный номер
+	SSCC         string // SSCC короба для штрих-кода GS1-128 (00), пусто - префикс компании не задан
 
 	// Предварительно обработанные данные для шаблона
 	BarcodeDate          string // Дата для штрих-кода (ГГММДД)
@@ -61,6 +72,8 @@ func (b *LabelBuilder) WithProduct(product Product) *LabelBuilder {
 		b.label.Weight = labelData.Weight
 		b.label.QuantityBox = labelData.QuantityBox
 		b.label.WeightBox = labelData.WeightBox
+		b.label.QuantityLayer = labelData.QuantityLayer
+		b.label.LayersBox = labelData.LayersBox
 	}
 
 	return b
@@ -97,6 +110,27 @@ func (b *LabelBuilder) WithSerialNumber(sn string) *LabelBuilder {
 	return b
 }
 
+// WithQuantity задает фактическое количество в коробе, например для неполного короба.
+// Вес коробки пересчитывается из веса единицы (г); если вес единицы не задан, поле веса очищается
+func (b *LabelBuilder) WithQuantity(quantity int) *LabelBuilder {
+	b.label.QuantityBox = strconv.Itoa(quantity)
+	b.label.WeightBox = ""
+
+	weight := strings.TrimSpace(b.label.Weight)
+	unitWeight, err := strconv.ParseFloat(strings.ReplaceAll(weight, ",", "."), 64)
+	if err != nil || unitWeight <= 0 {
+		return b
+	}
+
+	boxWeight := strconv.FormatFloat(math.Round(unitWeight*float64(quantity))/1000, 'f', -1, 64)
+	if strings.Contains(weight, ",") {
+		boxWeight = strings.ReplaceAll(boxWeight, ".", ",")
+	}
+	b.label.WeightBox = boxWeight
+
+	return b
+}
+
 // Build создает окончательную структуру этикетки
 func (b *LabelBuilder) Build() LabelData {
 	return b.label
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestLabelBuilderWithQuantity(t *testing.T) {
+	tests := []struct {
+		name         string
+		labelData    string
+		quantity     int
+		wantQuantity string
+		wantWeight   string
+	}{
+		{"вес по весу единицы", `{"Weight": "250", "QuantityBox": "12", "WeightBox": "3"}`, 5, "5", "1.25"},
+		{"вес с запятой", `{"Weight": "250,5", "QuantityBox": "12", "WeightBox": "3,006"}`, 3, "3", "0,752"},
+		{"вес единицы не задан", `{"QuantityBox": "12", "WeightBox": "3"}`, 5, "5", ""},
+		{"вес единицы не число", `{"Weight": "250 г", "QuantityBox": "12", "WeightBox": "3"}`, 5, "5", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			label := NewLabelBuilder().WithProduct(Product{LabelData: tt.labelData}).WithQuantity(tt.quantity).Build()
+
+			if label.QuantityBox != tt.wantQuantity || label.WeightBox != tt.wantWeight {
+				t.Errorf("количество %q, вес %q, ожидалось %q и %q", label.QuantityBox, label.WeightBox, tt.wantQuantity, tt.wantWeight)
+			}
+		})
+	}
+}
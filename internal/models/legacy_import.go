@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// LegacyBox - короб из журнала агрегации старого формата: строка кода короба со временем
+// и строки кодов товаров с отступом
+type LegacyBox struct {
+	Code     string
+	ClosedAt time.Time
+	File     string
+	Line     int
+	Items    []LegacyItem
+}
+
+// LegacyItem - код товара из журнала агрегации старого формата
+type LegacyItem struct {
+	Code string
+	Line int
+}
+
+// ImportProblem - строка журнала, пропущенная при импорте
+type ImportProblem struct {
+	File    string
+	Line    int
+	Code    string
+	Message string
+}
+
+// String возвращает описание проблемы с указанием файла и строки
+func (p ImportProblem) String() string {
+	if p.Code == "" {
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, p.Message, p.Code)
+}
+
+// ImportResult содержит итог импорта журналов агрегации
+type ImportResult struct {
+	Boxes    int // Импортировано коробов
+	Items    int // Импортировано кодов товаров
+	Problems []ImportProblem
+	DryRun   bool // Проверка без записи в базу
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Статусы кодов маркировки, выданных EZFactory для задания
+const (
+	MarkingCodeStatusIssued   = "issued"   // Выдан и еще не использован
+	MarkingCodeStatusConsumed = "consumed" // Нанесен на продукцию, которая осталась в агрегации
+	MarkingCodeStatusSpoiled  = "spoiled"  // Продукция с кодом удалена из короба как испорченная
+	MarkingCodeStatusReturned = "returned" // Не использован к завершению задания
+)
+
+// CodePage - страница кодов задания, полученная из EZFactory
+type CodePage struct {
+	Codes      []string `json:"codes"`
+	NextCursor string   `json:"next_cursor"` // Пустой курсор означает последнюю страницу
+}
+
+// CodeDownload описывает ход загрузки кодов задания: по сохраненному курсору загрузка продолжается после сбоя
+type CodeDownload struct {
+	TaskID     int       `json:"task_id"`
+	Cursor     string    `json:"cursor"`     // Курсор следующей страницы
+	Downloaded int       `json:"downloaded"` // Загружено кодов
+	Completed  bool      `json:"completed"`
+	Error      string    `json:"error"` // Ошибка последней попытки загрузки
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CodeUsage содержит количество кодов задания по статусам
+type CodeUsage struct {
+	Issued   int
+	Consumed int
+	Spoiled  int
+	Returned int
+}
+
+// Total возвращает общее количество кодов задания
+func (u CodeUsage) Total() int {
+	return u.Issued + u.Consumed + u.Spoiled + u.Returned
+}
+
+// CodeUsageReport - отчет об использовании кодов задания для EZFactory
+type CodeUsageReport struct {
+	TaskID   int      `json:"task_id"`
+	Consumed []string `json:"consumed"`
+	Spoiled  []string `json:"spoiled"`
+	Returned []string `json:"returned"`
+}
+
+// CodeReportPayload содержит данные сообщения об отправке отчета по кодам задания
+type CodeReportPayload struct {
+	TaskID int `json:"task_id"`
+}
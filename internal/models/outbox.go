@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Типы исходящих сообщений EZFactory
+const (
+	OutboxKindTaskStatus        = "task_status"        // Изменение статуса задания
+	OutboxKindAggregationUpload = "aggregation_upload" // Выгрузка агрегации задания
+	OutboxKindCodeReport        = "code_report"        // Отчет об использовании кодов задания
+)
+
+// OutboxMessage представляет исходящий вызов EZFactory, ожидающий отправки
+type OutboxMessage struct {
+	ID             int64      `json:"id"`
+	Kind           string     `json:"kind"`
+	TaskID         int        `json:"task_id"`
+	Payload        string     `json:"payload"`         // Данные вызова в JSON
+	IdempotencyKey string     `json:"idempotency_key"` // Одинаков для всех повторов сообщения
+	Attempts       int        `json:"attempts"`        // Неудачных попыток отправки
+	LastError      string     `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	SentAt         *time.Time `json:"sent_at"`
+	FailedAt       *time.Time `json:"failed_at"` // Перенесено в неотправленные, NULL - в очереди
+}
+
+// KindTitle возвращает описание типа сообщения
+func (m OutboxMessage) KindTitle() string {
+	switch m.Kind {
+	case OutboxKindTaskStatus:
+		var payload TaskStatusPayload
+		if err := json.Unmarshal([]byte(m.Payload), &payload); err == nil {
+			return "Статус задания: " + payload.Status
+		}
+		return "Статус задания"
+	case OutboxKindAggregationUpload:
+		return "Выгрузка агрегации"
+	case OutboxKindCodeReport:
+		return "Отчет по кодам маркировки"
+	default:
+		return m.Kind
+	}
+}
+
+// TaskStatusPayload содержит данные сообщения об изменении статуса задания
+type TaskStatusPayload struct {
+	TaskID int    `json:"task_id"`
+	Status string `json:"status"`
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Packing описывает геометрию укладки продукции в короб
+type Packing struct {
+	ContainerCapacity int // Емкость короба (шт)
+	LayerCapacity     int // Емкость слоя (шт)
+	TotalLayers       int // Количество слоев в коробе
+}
+
+// BoxProgress описывает заполнение текущего короба
+type BoxProgress struct {
+	Count       int // Накоплено кодов
+	Capacity    int // Емкость короба
+	Layer       int // Заполнено слоев
+	TotalLayers int // Всего слоев
+}
+
+// ParseLabelData разбирает данные этикетки из карточки продукта
+func ParseLabelData(product Product) (LabelData, error) {
+	var labelData LabelData
+
+	if product.LabelData == "" {
+		return labelData, fmt.Errorf("у продукта %d нет данных этикетки", product.ID)
+	}
+
+	if err := json.Unmarshal([]byte(product.LabelData), &labelData); err != nil {
+		return labelData, fmt.Errorf("ошибка при разборе данных этикетки: %w", err)
+	}
+
+	return labelData, nil
+}
+
+// PackingFromLabelData вычисляет геометрию укладки из данных этикетки.
+// Если параметры слоя не заданы, короб считается однослойным
+func PackingFromLabelData(labelData LabelData) (Packing, error) {
+	capacity, err := parsePackingNumber(labelData.QuantityBox, "количество в коробке")
+	if err != nil {
+		return Packing{}, err
+	}
+	if capacity == 0 {
+		return Packing{}, fmt.Errorf("не задано количество в коробке")
+	}
+
+	layerCapacity, err := parsePackingNumber(labelData.QuantityLayer, "количество в слое")
+	if err != nil {
+		return Packing{}, err
+	}
+
+	totalLayers, err := parsePackingNumber(labelData.LayersBox, "количество слоев")
+	if err != nil {
+		return Packing{}, err
+	}
+
+	switch {
+	case layerCapacity == 0 && totalLayers == 0:
+		layerCapacity = capacity
+		totalLayers = 1
+	case totalLayers == 0:
+		totalLayers = (capacity + layerCapacity - 1) / layerCapacity
+	case layerCapacity == 0:
+		if capacity%totalLayers != 0 {
+			return Packing{}, fmt.Errorf("количество в коробке %d не делится на %d слоев", capacity, totalLayers)
+		}
+		layerCapacity = capacity / totalLayers
+	}
+
+	if layerCapacity > capacity || layerCapacity*totalLayers < capacity || layerCapacity*(totalLayers-1) >= capacity {
+		return Packing{}, fmt.Errorf("некорректная укладка: %d шт в коробке, %d шт в слое, %d слоев",
+			capacity, layerCapacity, totalLayers)
+	}
+
+	return Packing{
+		ContainerCapacity: capacity,
+		LayerCapacity:     layerCapacity,
+		TotalLayers:       totalLayers,
+	}, nil
+}
+
+func parsePackingNumber(value, name string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("некорректное значение поля \"%s\": %s", name, value)
+	}
+
+	return number, nil
+}
@@ -0,0 +1,64 @@
+package models
+
+import "testing"
+
+func TestPackingFromLabelData(t *testing.T) {
+	tests := []struct {
+		name      string
+		labelData LabelData
+		want      Packing
+		wantErr   bool
+	}{
+		{"только количество в коробке", LabelData{QuantityBox: "12"}, Packing{12, 12, 1}, false},
+		{"количество с пробелами", LabelData{QuantityBox: " 12 "}, Packing{12, 12, 1}, false},
+		{"слои по емкости слоя", LabelData{QuantityBox: "12", QuantityLayer: "4"}, Packing{12, 4, 3}, false},
+		{"неполный верхний слой", LabelData{QuantityBox: "10", QuantityLayer: "4"}, Packing{10, 4, 3}, false},
+		{"емкость слоя по числу слоев", LabelData{QuantityBox: "12", LayersBox: "3"}, Packing{12, 4, 3}, false},
+		{"все параметры", LabelData{QuantityBox: "12", QuantityLayer: "4", LayersBox: "3"}, Packing{12, 4, 3}, false},
+		{"не делится на слои", LabelData{QuantityBox: "12", LayersBox: "5"}, Packing{}, true},
+		{"слоев не хватает", LabelData{QuantityBox: "12", QuantityLayer: "4", LayersBox: "2"}, Packing{}, true},
+		{"лишний слой", LabelData{QuantityBox: "12", QuantityLayer: "4", LayersBox: "4"}, Packing{}, true},
+		{"слой больше короба", LabelData{QuantityBox: "4", QuantityLayer: "6"}, Packing{}, true},
+		{"нет количества в коробке", LabelData{QuantityLayer: "4"}, Packing{}, true},
+		{"нулевое количество в коробке", LabelData{QuantityBox: "0"}, Packing{}, true},
+		{"не число", LabelData{QuantityBox: "12 шт"}, Packing{}, true},
+		{"отрицательное значение", LabelData{QuantityBox: "12", QuantityLayer: "-4"}, Packing{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PackingFromLabelData(tt.labelData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("укладка = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLabelData(t *testing.T) {
+	tests := []struct {
+		name    string
+		product Product
+		want    string
+		wantErr bool
+	}{
+		{"данные этикетки", Product{ID: 1, LabelData: `{"QuantityBox": "12"}`}, "12", false},
+		{"нет данных", Product{ID: 1}, "", true},
+		{"некорректный JSON", Product{ID: 1, LabelData: `{"QuantityBox": 12`}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabelData(tt.product)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка = %v", err)
+			}
+			if got.QuantityBox != tt.want {
+				t.Errorf("QuantityBox = %q, ожидалось %q", got.QuantityBox, tt.want)
+			}
+		})
+	}
+}
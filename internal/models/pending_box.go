@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// PendingBoxCode представляет код, уложенный в еще не закрытый короб.
+// Сохраняется в базе, чтобы после перезапуска продолжить сборку короба
+type PendingBoxCode struct {
+	ID        int64     `json:"id"`
+	TaskID    int       `json:"task_id"`
+	Station   string    `json:"station"` // Кто собирает короб: линия или ручная станция
+	Layer     int       `json:"layer"`   // Номер слоя в коробе
+	Code      string    `json:"code"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Места сборки несобранного короба. Линия и ручная станция собирают свои короба независимо
+const (
+	PendingBoxStationLine   = "line"
+	PendingBoxStationManual = "manual_station"
+)
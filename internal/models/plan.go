@@ -0,0 +1,46 @@
+package models
+
+// PlanWarningPercent - доля выполнения плана (%), после которой оператор получает предупреждение
+const PlanWarningPercent = 90
+
+// TaskPlan содержит плановые количества задания, полученные из EZFactory
+type TaskPlan struct {
+	Quantity int `json:"quantity"` // Единиц продукции, 0 - без плана
+	Boxes    int `json:"boxes"`    // Коробов, 0 - без плана
+}
+
+// IsSet сообщает, задан ли для задания план
+func (p TaskPlan) IsSet() bool {
+	return p.Quantity > 0 || p.Boxes > 0
+}
+
+// PlanProgress описывает выполнение плана задания
+type PlanProgress struct {
+	Plan     TaskPlan
+	Quantity int // Произведено единиц продукции
+	Boxes    int // Закрыто коробов
+}
+
+// Percent возвращает процент выполнения плана по наиболее выполненному показателю
+func (p PlanProgress) Percent() int {
+	percent := 0
+	if p.Plan.Quantity > 0 {
+		percent = p.Quantity * 100 / p.Plan.Quantity
+	}
+	if p.Plan.Boxes > 0 {
+		if boxes := p.Boxes * 100 / p.Plan.Boxes; boxes > percent {
+			percent = boxes
+		}
+	}
+	return percent
+}
+
+// IsReached сообщает, выполнен ли план
+func (p PlanProgress) IsReached() bool {
+	return p.Plan.IsSet() && p.Percent() >= 100
+}
+
+// IsApproaching сообщает, что план близок к выполнению, но еще не выполнен
+func (p PlanProgress) IsApproaching() bool {
+	return p.Plan.IsSet() && p.Percent() >= PlanWarningPercent && !p.IsReached()
+}
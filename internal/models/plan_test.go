@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestPlanProgress(t *testing.T) {
+	tests := []struct {
+		name            string
+		progress        PlanProgress
+		wantPercent     int
+		wantApproaching bool
+		wantReached     bool
+	}{
+		{"без плана", PlanProgress{Quantity: 100, Boxes: 10}, 0, false, false},
+		{"начало", PlanProgress{Plan: TaskPlan{Quantity: 100}, Quantity: 50}, 50, false, false},
+		{"порог предупреждения", PlanProgress{Plan: TaskPlan{Quantity: 100}, Quantity: 90}, 90, true, false},
+		{"план выполнен", PlanProgress{Plan: TaskPlan{Quantity: 100}, Quantity: 100}, 100, false, true},
+		{"сверх плана", PlanProgress{Plan: TaskPlan{Quantity: 100}, Quantity: 120}, 120, false, true},
+		{"по коробам", PlanProgress{Plan: TaskPlan{Boxes: 10}, Quantity: 5, Boxes: 9}, 90, true, false},
+		{"по наиболее выполненному", PlanProgress{Plan: TaskPlan{Quantity: 100, Boxes: 10}, Quantity: 60, Boxes: 10}, 100, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.progress.Percent(); got != tt.wantPercent {
+				t.Errorf("Percent = %d, ожидалось %d", got, tt.wantPercent)
+			}
+			if got := tt.progress.IsApproaching(); got != tt.wantApproaching {
+				t.Errorf("IsApproaching = %v, ожидалось %v", got, tt.wantApproaching)
+			}
+			if got := tt.progress.IsReached(); got != tt.wantReached {
+				t.Errorf("IsReached = %v, ожидалось %v", got, tt.wantReached)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package models
+
+import "fmt"
+
+// ProcessorMode описывает режим работы линии
+type ProcessorMode string
+
+// Режимы работы линии
+const (
+	ProcessorModeItemSerialization ProcessorMode = "item_serialization"
+	ProcessorModeLayerAggregation  ProcessorMode = "layer_aggregation"
+	ProcessorModeManualAggregation ProcessorMode = "manual_aggregation"
+)
+
+// ProcessorModeTitles содержит названия режимов для оператора
+var ProcessorModeTitles = map[ProcessorMode]string{
+	ProcessorModeItemSerialization: "Поштучная сериализация",
+	ProcessorModeLayerAggregation:  "Послойная агрегация",
+	ProcessorModeManualAggregation: "Ручная агрегация",
+}
+
+// Title возвращает название режима
+func (m ProcessorMode) Title() string {
+	if title, ok := ProcessorModeTitles[m]; ok {
+		return title
+	}
+	return string(m)
+}
+
+// ParseProcessorMode проверяет название режима. Пустая строка означает, что режим не задан
+func ParseProcessorMode(value string) (ProcessorMode, error) {
+	mode := ProcessorMode(value)
+	if value == "" {
+		return mode, nil
+	}
+	if _, ok := ProcessorModeTitles[mode]; !ok {
+		return "", fmt.Errorf("неизвестный режим работы линии: %s", value)
+	}
+	return mode, nil
+}
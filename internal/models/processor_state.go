@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// ProcessorState описывает состояние процессора линии
+type ProcessorState string
+
+// Состояния процессора
+const (
+	ProcessorStateIdle     ProcessorState = "idle"
+	ProcessorStateStarting ProcessorState = "starting"
+	ProcessorStateRunning  ProcessorState = "running"
+	ProcessorStatePaused   ProcessorState = "paused"
+	ProcessorStateFaulted  ProcessorState = "faulted"
+	ProcessorStateStopping ProcessorState = "stopping"
+)
+
+// ProcessorStateTitles содержит названия состояний для оператора
+var ProcessorStateTitles = map[ProcessorState]string{
+	ProcessorStateIdle:     "Остановлено",
+	ProcessorStateStarting: "Запуск",
+	ProcessorStateRunning:  "Активно",
+	ProcessorStatePaused:   "Приостановлено",
+	ProcessorStateFaulted:  "Авария",
+	ProcessorStateStopping: "Остановка",
+}
+
+// StateChange описывает переход процессора в новое состояние
+type StateChange struct {
+	State    ProcessorState `json:"state"`
+	Previous ProcessorState `json:"previous"`
+	Reason   string         `json:"reason"` // Причина перехода, для аварии - текст ошибки
+	At       time.Time      `json:"at"`
+}
+
+// Title возвращает название состояния
+func (c StateChange) Title() string {
+	if title, ok := ProcessorStateTitles[c.State]; ok {
+		return title
+	}
+	return string(c.State)
+}
+
+// IsActive сообщает, работает ли процессор с оборудованием.
+// Приостановленный процессор оборудование освобождает
+func (c StateChange) IsActive() bool {
+	return c.State == ProcessorStateRunning
+}
+
+// IsFaulted сообщает, находится ли процессор в аварии
+func (c StateChange) IsFaulted() bool {
+	return c.State == ProcessorStateFaulted
+}
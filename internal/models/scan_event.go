@@ -0,0 +1,112 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Итог обработки срабатывания сканера
+const (
+	ScanOutcomeAccepted = "принят"
+	ScanOutcomeRejected = "отклонен"
+)
+
+// Коды причин отклонения
+const (
+	ScanReasonNoRead           = "noread"
+	ScanReasonScanError        = "scan_error"
+	ScanReasonWrongCount       = "wrong_count"
+	ScanReasonDuplicateInLayer = "duplicate_in_layer"
+	ScanReasonDuplicateInBox   = "duplicate_in_box"
+	ScanReasonInvalidCode      = "invalid_code"
+	ScanReasonNotUnique        = "not_unique"
+	ScanReasonSerialError      = "serial_error"
+	ScanReasonSaveError        = "save_error"
+)
+
+// ScanReasonTitles содержит человекочитаемые описания причин отклонения
+var ScanReasonTitles = map[string]string{
+	ScanReasonNoRead:           "Код не прочитан",
+	ScanReasonScanError:        "Ошибка сканера",
+	ScanReasonWrongCount:       "Неверное количество кодов",
+	ScanReasonDuplicateInLayer: "Дубликаты в слое",
+	ScanReasonDuplicateInBox:   "Повтор кода в коробе",
+	ScanReasonInvalidCode:      "Невалидный код",
+	ScanReasonNotUnique:        "Код уже использован",
+	ScanReasonSerialError:      "Ошибка генерации серийного номера",
+	ScanReasonSaveError:        "Ошибка сохранения",
+}
+
+// ScanEvent представляет запись журнала сканирования
+type ScanEvent struct {
+	ID          int64     `json:"id"`
+	TaskID      int       `json:"task_id"`
+	RawResponse string    `json:"raw_response"` // Сырой ответ сканера
+	Codes       []string  `json:"codes"`        // Разобранные коды
+	Outcome     string    `json:"outcome"`      // Итог обработки
+	Reason      string    `json:"reason"`       // Код причины отклонения
+	Message     string    `json:"message"`      // Подробности
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ReasonTitle возвращает описание причины отклонения
+func (e ScanEvent) ReasonTitle() string {
+	if title, ok := ScanReasonTitles[e.Reason]; ok {
+		return title
+	}
+	return e.Reason
+}
+
+// ScanCounters содержит счетчики срабатываний по заданию
+type ScanCounters struct {
+	Total    int            `json:"total"`     // Всего срабатываний
+	Accepted int            `json:"accepted"`  // Принято
+	Rejected int            `json:"rejected"`  // Отклонено
+	ByReason map[string]int `json:"by_reason"` // Отклонено по причинам
+}
+
+// ReasonCount содержит количество отклонений по одной причине
+type ReasonCount struct {
+	Reason string
+	Title  string
+	Count  int
+}
+
+// SortReasonCounts возвращает отклонения по причинам в порядке убывания количества
+func SortReasonCounts(byReason map[string]int) []ReasonCount {
+	result := make([]ReasonCount, 0, len(byReason))
+	for reason, count := range byReason {
+		result = append(result, ReasonCount{
+			Reason: reason,
+			Title:  ScanEvent{Reason: reason}.ReasonTitle(),
+			Count:  count,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Reason < result[j].Reason
+	})
+
+	return result
+}
+
+// ManualScanResult описывает результат обработки одного кода на ручной станции агрегации
+type ManualScanResult struct {
+	Code          string      `json:"code"`
+	Accepted      bool        `json:"accepted"`
+	Reason        string      `json:"reason"`
+	Message       string      `json:"message"`
+	BoxClosed     bool        `json:"box_closed"`     // Короб заполнен и закрыт этим кодом
+	ContainerCode string      `json:"container_code"` // Код закрытого короба
+	LabelFailed   bool        `json:"label_failed"`   // Этикетка закрытого короба не напечатана или не подтверждена
+	PlanReached   bool        `json:"plan_reached"`   // Закрытым коробом выполнен план задания, станция остановлена
+	Progress      BoxProgress `json:"progress"`
+}
+
+// ReasonTitle возвращает описание причины отклонения
+func (r ManualScanResult) ReasonTitle() string {
+	return ScanEvent{Reason: r.Reason}.ReasonTitle()
+}
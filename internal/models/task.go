@@ -9,6 +9,7 @@ const (
 	TaskStatusPaused     = "приостановлено"
 	TaskStatusCompleted  = "завершено"
 	TaskStatusSent       = "отправлено"
+	TaskStatusCancelled  = "отменено"
 )
 
 // Task представляет производственное задание
@@ -22,4 +23,12 @@ type Task struct {
 	BatchNumber string    `json:"BatchNumber"` // Номер партии
 	Status      string    `json:"Status"`      // Статус задания
 	CreatedAt   time.Time `json:"CreatedAt"`   // Дата создания
+
+	PlannedQuantity int `json:"PlannedQuantity"` // Плановое количество единиц продукции, 0 - без плана
+	PlannedBoxes    int `json:"PlannedBoxes"`    // Плановое количество коробов, 0 - без плана
+}
+
+// Plan возвращает план задания
+func (t Task) Plan() TaskPlan {
+	return TaskPlan{Quantity: t.PlannedQuantity, Boxes: t.PlannedBoxes}
 }
@@ -2,12 +2,23 @@ package processors
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/internal/services"
+	"github.com/ze674/EZLine/internal/validator"
+	"strings"
 	"sync"
+	"time"
 )
 
-var sensorChan <-chan struct{}
+const (
+	// Длительность импульса отбраковщика
+	rejectPulseDuration = 300 * time.Millisecond
+	// Количество ошибок сканера подряд, после которого процессор переходит в аварию
+	maxConsecutiveScanErrors = 5
+)
 
 type Scanner interface {
 	Close() error
@@ -24,95 +35,254 @@ type PLC interface {
 	RejectorOff() error
 }
 
+// AutomaticSerializationProcessor сериализует продукцию поштучно:
+// по каждому сигналу датчика сканирует один код, проверяет и сохраняет его,
+// а непрошедшую проверку продукцию отбраковывает через ПЛК
 type AutomaticSerializationProcessor struct {
-	mu          sync.Mutex
-	wg          sync.WaitGroup
-	cancelFunc  context.CancelFunc
-	running     bool
-	dataService DataService
-	task        *models.Task
-	product     *models.Product
-	scanner     Scanner
-	plc         PLC
-}
-
-func NewAutomaticSerializationProcessor(dataService DataService, scanner Scanner, plc PLC) *AutomaticSerializationProcessor {
+	mu              sync.Mutex
+	wg              sync.WaitGroup
+	cancelFunc      context.CancelFunc
+	state           *stateMachine
+	dataService     DataService
+	task            *models.Task
+	product         *models.Product
+	scanner         Scanner
+	plc             PLC
+	sensorChan      <-chan struct{}
+	codeLength      int    // Ожидаемая длина кода
+	noReadAnswer    string // Ответ сканера при нечитаемом коде
+	codeValidator   *validator.CodeValidator
+	uniqueValidator *services.CodeUniquenessValidator
+	itemRepository  *repository.ItemRepository
+	journal         *scanJournal
+	productWatch    *productWatch // Изменение загруженной карточки продукта в EZFactory
+	plan            *planTracker
+	equipment       *EquipmentLock // Оборудование, общее с ручной станцией, nil - не делится
+
+	// Импульс отбраковщика снимается по таймеру, не задерживая цикл датчика
+	rejectMu    sync.Mutex
+	rejectTimer *time.Timer
+}
+
+func NewAutomaticSerializationProcessor(dataService DataService, scanner Scanner, plc PLC, uniqueValidator *services.CodeUniquenessValidator, codeLength int, noReadAnswer string) *AutomaticSerializationProcessor {
 	return &AutomaticSerializationProcessor{
-		plc:         plc,
-		scanner:     scanner,
-		dataService: dataService,
+		plc:             plc,
+		scanner:         scanner,
+		dataService:     dataService,
+		codeLength:      codeLength,
+		noReadAnswer:    noReadAnswer,
+		uniqueValidator: uniqueValidator,
+		itemRepository:  repository.NewItemRepository(),
+		journal:         newScanJournal(),
+		productWatch:    newProductWatch(),
+		plan:            newPlanTracker(),
+		state:           newStateMachine(),
 	}
 }
 
+// UseEquipmentLock задает блокировку оборудования, общего с ручной станцией
+func (p *AutomaticSerializationProcessor) UseEquipmentLock(lock *EquipmentLock) {
+	p.equipment = lock
+}
+
 func (p *AutomaticSerializationProcessor) Start(TaskID int) error {
 	op := "processors.AutomaticSerializationProcessor.Start"
-	var err error
 
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if p.running {
+	if p.state.Is(models.ProcessorStateRunning) {
 		return nil
 	}
+
+	// Приостановленный процессор возобновляет работу без перехода через запуск
+	resuming := p.state.Is(models.ProcessorStatePaused)
+	if !resuming {
+		if err := p.state.Transition(models.ProcessorStateStarting, fmt.Sprintf("запуск задания %d", TaskID)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// После аварии освобождаем оборудование, оставшееся от прошлого запуска
+	_ = p.release()
+
+	if err := p.start(TaskID); err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+		_ = p.release()
+		p.state.Fault(err)
+		return err
+	}
+
+	if resuming {
+		return p.state.Transition(models.ProcessorStateRunning, fmt.Sprintf("возобновление задания %d", TaskID))
+	}
+	return p.state.Transition(models.ProcessorStateRunning, "")
+}
+
+func (p *AutomaticSerializationProcessor) start(TaskID int) error {
+	var err error
+
+	if err := p.equipment.Acquire(p, "линия поштучной сериализации"); err != nil {
+		return err
+	}
+
 	err = p.getData(TaskID)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
+	}
+
+	p.codeValidator = validator.NewCodeValidator(p.product.GTIN, p.codeLength)
+
+	pool, err := p.dataService.GetCodePool(p.task.ID)
+	if err != nil {
+		return err
+	}
+	p.codeValidator.UsePool(pool)
+
+	if err := p.uniqueValidator.Initialize(p.task.ID); err != nil {
+		return err
 	}
 
-	fmt.Println(p.task)
-	fmt.Println(p.product)
+	if err := p.journal.Initialize(p.task.ID); err != nil {
+		return err
+	}
+
+	if err := p.plan.Initialize(p.task); err != nil {
+		return err
+	}
 
 	// Создаем контекст, который можно будет отменить при остановке
 	ctx, cancel := context.WithCancel(context.Background())
 	p.cancelFunc = cancel
 
 	if err := p.connect(); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
+	}
+
+	if p.plc == nil {
+		return errors.New("ПЛК не настроен: нет сигнала датчика продукции")
+	}
+
+	p.sensorChan, err = p.plc.HandleProductSignal(ctx)
+	if err != nil {
+		return err
 	}
 
-	sensorChan, err = p.plc.HandleProductSignal(ctx)
 	p.wg.Add(1)
 	go p.run(ctx)
-	p.running = true
 
 	return nil
 }
 
 func (p *AutomaticSerializationProcessor) Stop() error {
+	return p.stop("остановлено оператором")
+}
+
+// stop останавливает процессор и освобождает оборудование с указанием причины
+func (p *AutomaticSerializationProcessor) stop(reason string) error {
 	op := "processors.AutomaticSerializationProcessor.Stop"
 
-	//var err error
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	if !p.running {
+	if p.state.Is(models.ProcessorStateIdle) {
 		return nil
 	}
 
+	if err := p.state.Transition(models.ProcessorStateStopping, reason); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.release(); err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+		p.state.Fault(err)
+		return err
+	}
+
+	return p.state.Transition(models.ProcessorStateIdle, reason)
+}
+
+// Pause приостанавливает линию вместе с заданием: цикл обработки останавливается, оборудование освобождается.
+// Работа продолжается вызовом Start
+func (p *AutomaticSerializationProcessor) Pause(reason string) error {
+	op := "processors.AutomaticSerializationProcessor.Pause"
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Отменяем контекст, что приведет к завершению цикла сканирования
-	if p.cancelFunc != nil {
-		p.cancelFunc()
-		p.cancelFunc = nil
+	if !p.state.Is(models.ProcessorStateRunning) {
+		return nil
 	}
 
-	p.wg.Wait() // Ожидаем завершения работы горутины
+	if err := p.release(); err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+		p.state.Fault(err)
+		return err
+	}
+
+	return p.state.Transition(models.ProcessorStatePaused, reason)
+}
 
-	if err := p.disconnect(); err != nil {
-		fmt.Errorf("%s: %w", op, err)
+// release останавливает цикл обработки, отключает и освобождает оборудование
+func (p *AutomaticSerializationProcessor) release() error {
+	defer p.equipment.Release(p)
+
+	// Отменяем контекст, что приведет к завершению цикла сканирования
+	if p.cancelFunc == nil {
+		return nil
 	}
 
-	p.running = false
-	return nil
+	p.cancelFunc()
+	p.cancelFunc = nil
 
+	p.wg.Wait() // Ожидаем завершения работы горутины
+
+	p.cancelRejectPulse()
+
+	return p.disconnect()
 }
 
 func (p *AutomaticSerializationProcessor) IsRunning() bool {
-	return p.running
+	return p.state.Current().IsActive()
+}
+
+// State возвращает текущее состояние процессора
+func (p *AutomaticSerializationProcessor) State() models.StateChange {
+	return p.state.Current()
+}
+
+// Subscribe возвращает канал с изменениями состояния процессора и функцию отписки
+func (p *AutomaticSerializationProcessor) Subscribe() (<-chan models.StateChange, func()) {
+	return p.state.Subscribe()
+}
+
+// Counters возвращает счетчики срабатываний по текущему заданию
+func (p *AutomaticSerializationProcessor) Counters() models.ScanCounters {
+	return p.journal.Counters()
+}
+
+// DeviceHealth возвращает состояние ПЛК, если адаптер ведет его диагностику
+func (p *AutomaticSerializationProcessor) DeviceHealth() []models.DeviceHealth {
+	reporter, ok := p.plc.(HealthReporter)
+	if !ok {
+		return nil
+	}
+	return []models.DeviceHealth{reporter.Health()}
+}
+
+// ProductChanged предупреждает линию об изменении карточки продукта в EZFactory и сообщает, работает ли она с этим продуктом.
+// Линия продолжает работать по загруженной карточке, новая применяется при следующем запуске
+func (p *AutomaticSerializationProcessor) ProductChanged(productID int) bool {
+	return p.IsRunning() && p.productWatch.Changed(productID)
+}
+
+// ProductOutdated сообщает, что линия работает по устаревшей карточке продукта
+func (p *AutomaticSerializationProcessor) ProductOutdated() bool {
+	return p.IsRunning() && p.productWatch.Outdated()
 }
 
 func (p *AutomaticSerializationProcessor) getData(TaskID int) error {
-	op := "processors.LayerAggregationProcessor.init"
+	op := "processors.AutomaticSerializationProcessor.getData"
 
 	var err error
 
@@ -131,6 +301,7 @@ func (p *AutomaticSerializationProcessor) getData(TaskID int) error {
 	}
 
 	p.product = &product
+	p.productWatch.Reset(product.ID)
 
 	return nil
 }
@@ -152,42 +323,186 @@ func (p *AutomaticSerializationProcessor) connect() error {
 		}
 	}
 
+	// Конвейер мог остаться остановленным после выполнения плана
+	if conveyor, ok := p.plc.(ConveyorController); ok {
+		if err = conveyor.ResumeConveyor(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
 	return nil
 }
 
 func (p *AutomaticSerializationProcessor) disconnect() error {
 	op := "processors.AutomaticSerializationProcessor.disconnect"
 
-	var err error
+	var errs []error
 
 	if p.scanner != nil {
-		if err = p.scanner.Close(); err != nil {
-			return fmt.Errorf("%s: %w", op, err)
+		if err := p.scanner.Close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
 	if p.plc != nil {
-		if err = p.plc.Close(); err != nil {
-			return fmt.Errorf("%s: %w", op, err)
+		if err := p.plc.Close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
 func (p *AutomaticSerializationProcessor) run(ctx context.Context) {
 	defer p.wg.Done()
+
+	scanErrors := 0
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		default:
-			<-sensorChan
-			res, err := p.scanner.Scan()
-			if err != nil {
-				fmt.Println(err)
+		case _, ok := <-p.sensorChan:
+			if !ok {
+				p.state.Fault(errors.New("потерян сигнал датчика продукции"))
+				return
 			}
-			fmt.Println(res)
+
+			if err := p.processItem(); err != nil {
+				scanErrors++
+				if scanErrors >= maxConsecutiveScanErrors {
+					p.state.Fault(fmt.Errorf("сканер не отвечает: %w", err))
+					return
+				}
+				continue
+			}
+			scanErrors = 0
+		}
+	}
+}
+
+// processItem сканирует код единицы продукции, проверяет и сохраняет его.
+// Непрошедшая проверку продукция отбраковывается
+// Возвращает ошибку только при сбое сканера
+func (p *AutomaticSerializationProcessor) processItem() error {
+	raw, err := p.scanner.Scan()
+	if err != nil {
+		p.rejectItem(raw, nil, models.ScanReasonScanError, err.Error())
+		return err
+	}
+
+	code := strings.TrimSpace(raw)
+	if code == "" || code == p.noReadAnswer {
+		p.rejectItem(raw, nil, models.ScanReasonNoRead, "")
+		return nil
+	}
+	codes := []string{code}
+
+	result := p.codeValidator.ValidateCode(code)
+	if !result.Valid {
+		p.rejectItem(raw, codes, models.ScanReasonInvalidCode, result.Message)
+		return nil
+	}
+
+	if !p.uniqueValidator.IsCodeUnique(code) {
+		p.rejectItem(raw, codes, models.ScanReasonNotUnique, code)
+		return nil
+	}
+
+	if _, err := p.itemRepository.CreateItem(code, p.task.ID, repository.StatusScanned); err != nil {
+		p.rejectItem(raw, codes, models.ScanReasonSaveError, err.Error())
+		return nil
+	}
+
+	p.uniqueValidator.MarkCodeAsUsed(code)
+	p.journal.Accept(raw, codes, "")
+
+	if p.plan.Add(1, 0) {
+		p.onPlanReached()
+	}
+	return nil
+}
+
+// onPlanReached останавливает конвейер и процессор после выполнения плана
+func (p *AutomaticSerializationProcessor) onPlanReached() {
+	fmt.Printf("План задания %d выполнен, линия останавливается\n", p.task.ID)
+
+	if conveyor, ok := p.plc.(ConveyorController); ok {
+		if err := conveyor.StopConveyor(); err != nil {
+			fmt.Printf("Ошибка остановки конвейера: %v\n", err)
 		}
 	}
+
+	// Останавливаем асинхронно: stop ожидает завершения цикла, из которого вызван этот метод
+	go p.stop(planReachedReason)
+}
+
+// rejectItem записывает отклонение в журнал и отбраковывает продукцию
+func (p *AutomaticSerializationProcessor) rejectItem(raw string, codes []string, reason, message string) {
+	p.journal.Reject(raw, codes, reason, message)
+
+	if err := p.reject(); err != nil {
+		fmt.Printf("Ошибка отбраковки: %v\n", err)
+	}
+}
+
+// reject подает импульс на отбраковщик.
+// Отбраковщик выключается по таймеру, повторный импульс продлевает его
+func (p *AutomaticSerializationProcessor) reject() error {
+	op := "processors.AutomaticSerializationProcessor.reject"
+
+	if p.plc == nil {
+		return nil
+	}
+
+	p.rejectMu.Lock()
+	defer p.rejectMu.Unlock()
+
+	if err := p.plc.RejectorOn(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if p.rejectTimer != nil && p.rejectTimer.Stop() {
+		p.rejectTimer.Reset(rejectPulseDuration)
+		return nil
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(rejectPulseDuration, func() { p.rejectorOff(timer) })
+	p.rejectTimer = timer
+	return nil
+}
+
+// rejectorOff завершает импульс отбраковщика, если его не продлили и не сняли досрочно
+func (p *AutomaticSerializationProcessor) rejectorOff(timer *time.Timer) {
+	p.rejectMu.Lock()
+	defer p.rejectMu.Unlock()
+
+	if p.rejectTimer != timer {
+		return
+	}
+
+	p.rejectTimer = nil
+	if err := p.plc.RejectorOff(); err != nil {
+		fmt.Printf("Ошибка отбраковки: %v\n", err)
+	}
+}
+
+// cancelRejectPulse досрочно снимает незавершенный импульс перед отключением ПЛК
+func (p *AutomaticSerializationProcessor) cancelRejectPulse() {
+	p.rejectMu.Lock()
+	defer p.rejectMu.Unlock()
+
+	if p.rejectTimer == nil {
+		return
+	}
+
+	p.rejectTimer.Stop()
+	p.rejectTimer = nil
+	if err := p.plc.RejectorOff(); err != nil {
+		fmt.Printf("Ошибка отбраковки: %v\n", err)
+	}
 }
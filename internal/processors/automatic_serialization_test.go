@@ -0,0 +1,149 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/internal/services"
+	"github.com/ze674/EZLine/internal/validator"
+)
+
+const (
+	testGTIN = "04607054766164"
+	testCode = "0104607054766164215+5DqV"
+)
+
+// scriptedScanner возвращает один и тот же ответ или ошибку
+type scriptedScanner struct {
+	response string
+	err      error
+}
+
+func (s scriptedScanner) Scan() (string, error) { return s.response, s.err }
+func (s scriptedScanner) Connect() error        { return nil }
+func (s scriptedScanner) Close() error          { return nil }
+
+// countingPLC считает включения отбраковщика
+type countingPLC struct {
+	rejects atomic.Int32
+}
+
+func (p *countingPLC) Connect() error { return nil }
+func (p *countingPLC) Close() error   { return nil }
+func (p *countingPLC) HandleProductSignal(ctx context.Context) (<-chan struct{}, error) {
+	return make(chan struct{}), nil
+}
+func (p *countingPLC) RejectorOn() error  { p.rejects.Add(1); return nil }
+func (p *countingPLC) RejectorOff() error { return nil }
+
+// newTestSerialization создает процессор, подготовленный к обработке задания 1, как после запуска
+func newTestSerialization(t *testing.T, scanner Scanner, plc PLC) *AutomaticSerializationProcessor {
+	t.Helper()
+
+	unique := services.NewCodeUniquenessValidator()
+	if err := unique.Initialize(1); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	p := NewAutomaticSerializationProcessor(nil, scanner, plc, unique, len(testCode), "NOREAD")
+	p.task = &models.Task{ID: 1}
+	p.product = &models.Product{GTIN: testGTIN}
+	p.codeValidator = validator.NewCodeValidator(testGTIN, len(testCode))
+	if err := p.journal.Initialize(1); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return p
+}
+
+func TestAutomaticSerializationProcessItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		scanner   scriptedScanner
+		used      bool   // Код уже сохранен ранее
+		reason    string // Причина отклонения, пусто - код принят
+		wantErr   bool
+		wantSaved bool
+	}{
+		{"код принят", scriptedScanner{response: testCode + "\r\n"}, false, "", false, true},
+		{"NoRead сканера", scriptedScanner{response: "NOREAD"}, false, models.ScanReasonNoRead, false, false},
+		{"пустой ответ", scriptedScanner{response: " "}, false, models.ScanReasonNoRead, false, false},
+		{"другая длина", scriptedScanner{response: testCode + "X"}, false, models.ScanReasonInvalidCode, false, false},
+		{"чужой GTIN", scriptedScanner{response: "0104607054766165215+5DqV"}, false, models.ScanReasonInvalidCode, false, false},
+		{"повтор кода", scriptedScanner{response: testCode}, true, models.ScanReasonNotUnique, false, false},
+		{"ошибка сканера", scriptedScanner{err: errors.New("timeout")}, false, models.ScanReasonScanError, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+
+			plc := &countingPLC{}
+			p := newTestSerialization(t, tt.scanner, plc)
+			if tt.used {
+				p.uniqueValidator.MarkCodeAsUsed(testCode)
+			}
+			t.Cleanup(p.cancelRejectPulse)
+
+			err := p.processItem()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processItem = %v", err)
+			}
+
+			counters := p.Counters()
+			if tt.reason == "" {
+				if counters.Accepted != 1 || counters.Rejected != 0 {
+					t.Errorf("счетчики = %+v, ожидался принятый код", counters)
+				}
+			} else if counters.Rejected != 1 || counters.ByReason[tt.reason] != 1 {
+				t.Errorf("счетчики = %+v, ожидалось отклонение %s", counters, tt.reason)
+			}
+
+			wantRejects := int32(0)
+			if tt.reason != "" {
+				wantRejects = 1
+			}
+			if got := plc.rejects.Load(); got != wantRejects {
+				t.Errorf("включений отбраковщика %d, ожидалось %d", got, wantRejects)
+			}
+
+			item, err := repository.NewItemRepository().GetItemByCode(testCode)
+			if saved := err == nil && item != nil; saved != tt.wantSaved {
+				t.Errorf("код сохранен: %v, ожидалось %v", saved, tt.wantSaved)
+			}
+			if tt.wantSaved && !p.uniqueValidator.IsCodeUsed(testCode) {
+				t.Error("сохраненный код не отмечен использованным")
+			}
+		})
+	}
+}
+
+func TestAutomaticSerializationRejectsRepeatedScan(t *testing.T) {
+	setupDB(t)
+
+	plc := &countingPLC{}
+	p := newTestSerialization(t, scriptedScanner{response: testCode}, plc)
+	t.Cleanup(p.cancelRejectPulse)
+
+	for range 2 {
+		if err := p.processItem(); err != nil {
+			t.Fatalf("processItem: %v", err)
+		}
+	}
+
+	counters := p.Counters()
+	if counters.Accepted != 1 || counters.ByReason[models.ScanReasonNotUnique] != 1 || plc.rejects.Load() != 1 {
+		t.Errorf("счетчики = %+v, отбраковок %d: повтор должен быть отбракован", counters, plc.rejects.Load())
+	}
+
+	count, err := repository.NewItemRepository().CountByTaskID(1)
+	if err != nil {
+		t.Fatalf("CountByTaskID: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("сохранено кодов %d, ожидался 1", count)
+	}
+}
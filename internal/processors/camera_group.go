@@ -0,0 +1,198 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/models"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Ответ камеры, не прочитавшей ни одного кода
+const noReadResponse = "NoRead"
+
+// groupCamera хранит камеру группы и ее диагностику
+type groupCamera struct {
+	reader CodeReader
+	busy   atomic.Bool // Предыдущее сканирование еще не завершилось после таймаута
+	status models.CameraStatus
+}
+
+// cameraReading - результат одного срабатывания камеры
+type cameraReading struct {
+	codes []string
+	err   error
+}
+
+// CameraGroup объединяет камеры, каждая из которых видит часть широкого слоя.
+// По одному сигналу все камеры сканируют параллельно, ответы объединяются без повторов.
+// Группа сама реализует CodeReader, поэтому процессор работает с ней как с одной камерой
+type CameraGroup struct {
+	mu      sync.Mutex
+	cameras []*groupCamera
+	timeout time.Duration // Время ожидания ответа каждой камеры
+}
+
+func NewCameraGroup(timeout time.Duration, readers ...CodeReader) *CameraGroup {
+	cameras := make([]*groupCamera, len(readers))
+	for i, reader := range readers {
+		cameras[i] = &groupCamera{
+			reader: reader,
+			status: models.CameraStatus{Name: fmt.Sprintf("Камера %d", i+1)},
+		}
+	}
+
+	return &CameraGroup{
+		cameras: cameras,
+		timeout: timeout,
+	}
+}
+
+// Connect подключает все камеры группы
+func (g *CameraGroup) Connect() error {
+	op := "processors.CameraGroup.Connect"
+
+	for _, camera := range g.cameras {
+		if err := camera.reader.Connect(); err != nil {
+			return fmt.Errorf("%s: %s: %w", op, camera.status.Name, err)
+		}
+	}
+	return nil
+}
+
+// Close отключает все камеры группы
+func (g *CameraGroup) Close() error {
+	var errs []error
+	for _, camera := range g.cameras {
+		if err := camera.reader.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", camera.status.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Scan запускает все камеры параллельно и возвращает объединенные коды через пробел.
+// Если ни одна камера не прочитала код, возвращается NoRead, если все камеры вернули ошибку - ошибка
+func (g *CameraGroup) Scan() (string, error) {
+	readings := make([]cameraReading, len(g.cameras))
+
+	var wg sync.WaitGroup
+	for i, camera := range g.cameras {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readings[i] = g.scanCamera(camera)
+		}()
+	}
+	wg.Wait()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var codes []string
+	var errs []error
+	seen := make(map[string]bool)
+
+	for i, reading := range readings {
+		g.record(g.cameras[i], reading)
+
+		if reading.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", g.cameras[i].status.Name, reading.err))
+			continue
+		}
+
+		// Соседние камеры видят стык слоя, поэтому коды на стыке приходят дважды
+		for _, code := range reading.codes {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+
+	if len(errs) == len(g.cameras) {
+		return "", errors.Join(errs...)
+	}
+	if len(codes) == 0 {
+		return noReadResponse, nil
+	}
+
+	return strings.Join(codes, " "), nil
+}
+
+// scanCamera сканирует одной камерой с ограничением по времени
+func (g *CameraGroup) scanCamera(camera *groupCamera) cameraReading {
+	if !camera.busy.CompareAndSwap(false, true) {
+		return cameraReading{err: errors.New("камера не ответила на предыдущий сигнал")}
+	}
+
+	done := make(chan cameraReading, 1)
+	go func() {
+		defer camera.busy.Store(false)
+
+		raw, err := camera.reader.Scan()
+		if err != nil {
+			done <- cameraReading{err: err}
+			return
+		}
+		if raw == noReadResponse {
+			done <- cameraReading{}
+			return
+		}
+		done <- cameraReading{codes: strings.Fields(raw)}
+	}()
+
+	select {
+	case reading := <-done:
+		return reading
+	case <-time.After(g.timeout):
+		return cameraReading{err: fmt.Errorf("нет ответа за %v", g.timeout)}
+	}
+}
+
+// record обновляет диагностику камеры по результату срабатывания
+func (g *CameraGroup) record(camera *groupCamera, reading cameraReading) {
+	status := &camera.status
+
+	status.Reads++
+	status.LastAt = time.Now()
+	status.LastCodes = len(reading.codes)
+	status.LastMissed = reading.err != nil || len(reading.codes) == 0
+	status.LastError = ""
+
+	if status.LastMissed {
+		status.Misses++
+		status.LastError = "код не прочитан"
+		if reading.err != nil {
+			status.LastError = reading.err.Error()
+		}
+	}
+}
+
+// CameraStatuses возвращает диагностику всех камер группы
+func (g *CameraGroup) CameraStatuses() []models.CameraStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	statuses := make([]models.CameraStatus, len(g.cameras))
+	for i, camera := range g.cameras {
+		statuses[i] = camera.status
+	}
+	return statuses
+}
+
+// LastMisses описывает камеры, не прочитавшие коды при последнем срабатывании
+func (g *CameraGroup) LastMisses() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var misses []string
+	for _, camera := range g.cameras {
+		if camera.status.LastMissed {
+			misses = append(misses, fmt.Sprintf("%s: %s", camera.status.Name, camera.status.LastError))
+		}
+	}
+	return strings.Join(misses, "; ")
+}
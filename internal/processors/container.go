@@ -0,0 +1,127 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/internal/services"
+	"strconv"
+)
+
+// containerCode формирует код короба из GTIN продукта, номера партии и серийного номера
+func containerCode(task *models.Task, product *models.Product, serialNumber int) string {
+	return product.GTIN + task.BatchNumber + strconv.Itoa(serialNumber)
+}
+
+// ErrNoPendingBox возвращается при попытке закрыть короб, в который еще не уложено ни одного кода
+var ErrNoPendingBox = errors.New("нет несобранного короба")
+
+// closeContainer генерирует серийный номер, сохраняет короб с товарами и отмечает коды использованными.
+// Непустая причина partialReason означает, что короб закрыт оператором неполным.
+// Несобранный короб места сборки station очищается вместе с сохранением короба.
+// При ошибке возвращает код причины для журнала сканирования
+func closeContainer(
+	task *models.Task,
+	product *models.Product,
+	codes []string,
+	partialReason string,
+	station string,
+	serialGenerator *services.SerialGenerator,
+	containerRepository *repository.ContainerRepository,
+	uniqueValidator *services.CodeUniquenessValidator,
+) (models.Container, string, error) {
+	serialNumber, err := serialGenerator.GenerateSerial()
+	if err != nil {
+		return models.Container{}, models.ScanReasonSerialError, err
+	}
+
+	code := containerCode(task, product, serialNumber)
+
+	var containerID int64
+	if partialReason != "" {
+		containerID, err = containerRepository.CreatePartialContainerWithItems(code, serialNumber, task.ID, station, codes, partialReason)
+	} else {
+		containerID, err = containerRepository.CreateContainerWithItems(code, serialNumber, task.ID, station, codes)
+	}
+	if err != nil {
+		return models.Container{}, models.ScanReasonSaveError, fmt.Errorf("ошибка сохранения короба %s: %w", code, err)
+	}
+
+	uniqueValidator.MarkCodesAsUsed(codes)
+
+	fmt.Printf("Короб %s закрыт: %v, task_id: %d\n", code, codes, task.ID)
+
+	return models.Container{
+		ID:           containerID,
+		Code:         code,
+		SSCC:         containerRepository.ContainerSSCC(containerID),
+		SerialNumber: serialNumber,
+		TaskID:       task.ID,
+		Status:       repository.StatusCreated,
+		ItemsCount:   len(codes),
+		Partial:      partialReason != "",
+	}, "", nil
+}
+
+// closePartialBox закрывает несобранный короб с фактическим количеством кодов и печатает этикетку
+// с этим количеством. Если короб сохранен, а этикетка не напечатана или не подтверждена,
+// возвращается и короб, и ошибка печати
+func closePartialBox(
+	ctx context.Context,
+	task *models.Task,
+	product *models.Product,
+	collector *services.ContainerCollector,
+	station string,
+	serialGenerator *services.SerialGenerator,
+	containerRepository *repository.ContainerRepository,
+	uniqueValidator *services.CodeUniquenessValidator,
+	labelService *services.LabelService,
+	verifier *LabelVerifier,
+) (models.Container, error) {
+	if task == nil || collector == nil {
+		return models.Container{}, ErrNoPendingBox
+	}
+
+	codes := collector.GetPendingCodes()
+	if len(codes) == 0 {
+		return models.Container{}, ErrNoPendingBox
+	}
+
+	reason := fmt.Sprintf("закрыт неполным: %d из %d шт", len(codes), collector.GetContainerCapacity())
+
+	container, _, err := closeContainer(task, product, codes, reason, station, serialGenerator, containerRepository, uniqueValidator)
+	if err != nil {
+		return models.Container{}, err
+	}
+
+	collector.Reset()
+
+	if err := printBoxLabel(ctx, labelService, verifier, containerRepository, task, product, &container, len(codes)); err != nil {
+		return container, fmt.Errorf("короб %s закрыт, но этикетка не напечатана: %w", container.Code, err)
+	}
+
+	return container, nil
+}
+
+// restorePendingBox загружает в накопитель коды несобранного короба, сохраненные до перезапуска
+func restorePendingBox(pendingBoxRepository *repository.PendingBoxRepository, taskID int, collector *services.ContainerCollector) error {
+	pending, err := pendingBoxRepository.GetCodes(taskID)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки несобранного короба: %w", err)
+	}
+
+	codes := make([]string, 0, len(pending))
+	for _, code := range pending {
+		codes = append(codes, code.Code)
+	}
+	collector.Restore(codes)
+
+	if len(codes) > 0 {
+		fmt.Printf("Восстановлен несобранный короб задания %d: %d шт, начат %s\n",
+			taskID, len(codes), pending[0].CreatedAt.Format("02.01.2006 15:04:05"))
+	}
+
+	return nil
+}
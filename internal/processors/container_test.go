@@ -0,0 +1,146 @@
+package processors
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/internal/services"
+)
+
+// pendingLayer - слой кодов, сохраненный в несобранный короб места сборки
+type pendingLayer struct {
+	station string
+	taskID  int
+	layer   int
+	codes   []string
+}
+
+func TestRestorePendingBox(t *testing.T) {
+	tests := []struct {
+		name  string
+		saved []pendingLayer
+		want  []string
+	}{
+		{"нет несобранного короба", nil, nil},
+		{"слои по порядку укладки", []pendingLayer{
+			{models.PendingBoxStationManual, 1, 1, []string{"A1", "A2"}},
+			{models.PendingBoxStationManual, 1, 2, []string{"A3"}},
+		}, []string{"A1", "A2", "A3"}},
+		{"короб линии", []pendingLayer{
+			{models.PendingBoxStationLine, 1, 1, []string{"L1", "L2"}},
+			{models.PendingBoxStationManual, 1, 1, []string{"A1"}},
+		}, []string{"A1"}},
+		{"короб другого задания", []pendingLayer{
+			{models.PendingBoxStationManual, 2, 1, []string{"B1"}},
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+
+			for _, layer := range tt.saved {
+				if err := repository.NewPendingBoxRepository(layer.station).AddCodes(layer.taskID, layer.layer, layer.codes); err != nil {
+					t.Fatalf("AddCodes: %v", err)
+				}
+			}
+
+			collector := services.NewContainerCollector(4, 2, 2)
+			collector.Restore([]string{"старый код"})
+			if err := restorePendingBox(repository.NewPendingBoxRepository(models.PendingBoxStationManual), 1, collector); err != nil {
+				t.Fatalf("restorePendingBox: %v", err)
+			}
+
+			if got := collector.GetPendingCodes(); !slices.Equal(got, tt.want) {
+				t.Errorf("восстановлены коды %v, ожидались %v", got, tt.want)
+			}
+			if progress := collector.Progress(); progress.Count != len(tt.want) {
+				t.Errorf("заполнение %+v, ожидалось %d шт", progress, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestClosingBoxKeepsOtherStationPendingBox(t *testing.T) {
+	setupDB(t)
+
+	line := repository.NewPendingBoxRepository(models.PendingBoxStationLine)
+	manual := repository.NewPendingBoxRepository(models.PendingBoxStationManual)
+	if err := line.AddCodes(1, 1, []string{itemCode("AAAAA1")}); err != nil {
+		t.Fatalf("AddCodes: %v", err)
+	}
+	if err := manual.AddCodes(1, 1, []string{itemCode("AAAAA2")}); err != nil {
+		t.Fatalf("AddCodes: %v", err)
+	}
+
+	if _, err := repository.NewContainerRepository().CreateContainerWithItems("046070547612440000001", 1, 1,
+		models.PendingBoxStationLine, []string{itemCode("AAAAA1")}); err != nil {
+		t.Fatalf("CreateContainerWithItems: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		repo *repository.PendingBoxRepository
+		want int
+	}{
+		{"короб линии закрыт", line, 0},
+		{"короб станции сохранен", manual, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes, err := tt.repo.GetCodes(1)
+			if err != nil {
+				t.Fatalf("GetCodes: %v", err)
+			}
+			if len(codes) != tt.want {
+				t.Errorf("кодов несобранного короба %d, ожидалось %d", len(codes), tt.want)
+			}
+		})
+	}
+}
+
+func TestManualAggregationResumesPendingBoxAfterRestart(t *testing.T) {
+	setupDB(t)
+
+	first := newTestStation(t, "3", 0)
+	if err := first.Start(1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for _, code := range []string{itemCode("AAAAA1"), itemCode("AAAAA2")} {
+		if _, err := first.SubmitCode(code); err != nil {
+			t.Fatalf("SubmitCode: %v", err)
+		}
+	}
+	if err := first.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// Новый процессор, как после перезапуска программы
+	restarted := newTestStation(t, "3", 0)
+	if err := restarted.Start(1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if got, want := restarted.PendingCodes(), []string{itemCode("AAAAA1"), itemCode("AAAAA2")}; !slices.Equal(got, want) {
+		t.Fatalf("восстановлены коды %v, ожидались %v", got, want)
+	}
+
+	result, err := restarted.SubmitCode(itemCode("AAAAA3"))
+	if err != nil {
+		t.Fatalf("SubmitCode: %v", err)
+	}
+	if !result.BoxClosed {
+		t.Fatalf("результат = %+v, короб должен закрыться третьим кодом", result)
+	}
+
+	container, err := repository.NewContainerRepository().GetContainerByCode(result.ContainerCode)
+	if err != nil {
+		t.Fatalf("GetContainerByCode: %v", err)
+	}
+	if container, err := repository.NewContainerRepository().GetContainerByID(container.ID); err != nil || container.ItemsCount != 3 {
+		t.Errorf("короб = %+v (%v), ожидалось 3 шт", container, err)
+	}
+}
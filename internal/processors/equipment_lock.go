@@ -0,0 +1,69 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrEquipmentBusy возвращается при запуске процессора, пока оборудование линии занято другим процессором
+var ErrEquipmentBusy = errors.New("оборудование линии занято")
+
+// EquipmentLock закрепляет общее оборудование линии (принтер этикеток, считыватель этикеток,
+// кэш использованных кодов) за одним процессором. Линия и ручная станция не работают одновременно,
+// а процессор освобождает и отключает только оборудование, которое захватил сам.
+// Нулевой указатель означает, что оборудование ни с кем не делится
+type EquipmentLock struct {
+	mu    sync.Mutex
+	owner any
+	name  string
+}
+
+func NewEquipmentLock() *EquipmentLock {
+	return &EquipmentLock{}
+}
+
+// Acquire закрепляет оборудование за процессором. Повторный захват тем же процессором допустим
+func (l *EquipmentLock) Acquire(owner any, name string) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.owner != nil && l.owner != owner {
+		return fmt.Errorf("%w: работает %s", ErrEquipmentBusy, l.name)
+	}
+
+	l.owner = owner
+	l.name = name
+	return nil
+}
+
+// Release освобождает оборудование, если оно закреплено за процессором
+func (l *EquipmentLock) Release(owner any) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.owner == owner {
+		l.owner = nil
+		l.name = ""
+	}
+}
+
+// Owns сообщает, закреплено ли оборудование за процессором
+func (l *EquipmentLock) Owns(owner any) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.owner == owner
+}
@@ -0,0 +1,153 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/internal/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLabelNotVerified возвращается, если этикетка короба не подтверждена после всех попыток печати
+var ErrLabelNotVerified = errors.New("этикетка короба не подтверждена")
+
+// Префикс идентификатора символики GS1 DataMatrix, который добавляют некоторые сканеры
+const dataMatrixSymbologyPrefix = "]d2"
+
+// LabelVerifier проверяет напечатанную этикетку короба вторым считывателем:
+// прочитанный DataMatrix сравнивается с данными этикетки, при несовпадении этикетка перепечатывается
+type LabelVerifier struct {
+	reader   CodeReader
+	attempts int           // Количество попыток печати, включая первую
+	delay    time.Duration // Время от печати до появления этикетки под считывателем
+}
+
+func NewLabelVerifier(reader CodeReader, attempts int, delay time.Duration) *LabelVerifier {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return &LabelVerifier{
+		reader:   reader,
+		attempts: attempts,
+		delay:    delay,
+	}
+}
+
+// Enabled сообщает, настроена ли проверка этикеток
+func (v *LabelVerifier) Enabled() bool {
+	return v != nil && v.reader != nil
+}
+
+// Connect подключает считыватель этикеток
+func (v *LabelVerifier) Connect() error {
+	if !v.Enabled() {
+		return nil
+	}
+	return v.reader.Connect()
+}
+
+// Close отключает считыватель этикеток
+func (v *LabelVerifier) Close() error {
+	if !v.Enabled() {
+		return nil
+	}
+	return v.reader.Close()
+}
+
+// verify читает этикетку и сравнивает ее с ожидаемыми данными DataMatrix.
+// Ожидание этикетки под считывателем прерывается отменой ctx
+func (v *LabelVerifier) verify(ctx context.Context, expected string) error {
+	if v.delay > 0 {
+		timer := time.NewTimer(v.delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return fmt.Errorf("проверка прервана: %w", ctx.Err())
+		}
+	}
+
+	raw, err := v.reader.Scan()
+	if err != nil {
+		return fmt.Errorf("ошибка чтения этикетки: %w", err)
+	}
+
+	decoded := normalizeDataMatrix(raw)
+	if decoded == "" {
+		return errors.New("этикетка не прочитана")
+	}
+	if decoded != normalizeDataMatrix(expected) {
+		return fmt.Errorf("прочитано %q, ожидалось %q", decoded, expected)
+	}
+
+	return nil
+}
+
+// normalizeDataMatrix убирает из ответа считывателя пробелы, префикс символики и разделители групп
+func normalizeDataMatrix(raw string) string {
+	result := strings.TrimSpace(raw)
+	result = strings.TrimPrefix(result, dataMatrixSymbologyPrefix)
+	return strings.ReplaceAll(result, "\x1d", "")
+}
+
+// printBoxLabel печатает этикетку короба и, если настроена проверка, подтверждает ее считывателем.
+// При несовпадении этикетка перепечатывается; если все попытки неудачны, короб помечается
+// неподтвержденным и возвращается ErrLabelNotVerified. quantity больше нуля задает фактическое количество.
+// Отмена ctx при остановке процессора прерывает проверку, этикетка не перепечатывается
+func printBoxLabel(
+	ctx context.Context,
+	labelService *services.LabelService,
+	verifier *LabelVerifier,
+	containerRepository *repository.ContainerRepository,
+	task *models.Task,
+	product *models.Product,
+	container *models.Container,
+	quantity int,
+) error {
+	labelData := labelService.BuildLabelData(task, product, strconv.Itoa(container.SerialNumber), quantity)
+	labelData.SSCC = container.SSCC
+
+	var verifyErr error
+	attempts := 1
+	if verifier.Enabled() {
+		attempts = verifier.attempts
+	}
+
+	printed := 0
+	for printed < attempts {
+		printed++
+		if err := labelService.PrintLabelData(labelData); err != nil {
+			return err
+		}
+
+		if !verifier.Enabled() {
+			return nil
+		}
+
+		verifyErr = verifier.verify(ctx, labelData.DmData)
+		if verifyErr == nil {
+			container.LabelStatus = repository.LabelStatusVerified
+			return containerRepository.SetLabelStatus(container, repository.LabelStatusVerified, "")
+		}
+
+		fmt.Printf("Этикетка короба %s не подтверждена (попытка %d из %d): %v\n", container.Code, printed, attempts, verifyErr)
+
+		// Остановленный процессор этикетку не перепечатывает
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	reason := fmt.Sprintf("попыток печати: %d, %v", printed, verifyErr)
+	container.LabelStatus = repository.LabelStatusUnverified
+	if err := containerRepository.SetLabelStatus(container, repository.LabelStatusUnverified, reason); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %s", ErrLabelNotVerified, reason)
+}
@@ -0,0 +1,81 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// staticReader - считыватель, который всегда возвращает одно и то же значение
+type staticReader struct {
+	value string
+}
+
+func (r staticReader) Scan() (string, error) { return r.value, nil }
+func (r staticReader) Connect() error        { return nil }
+func (r staticReader) Close() error          { return nil }
+
+func TestLabelVerifierVerify(t *testing.T) {
+	const expected = "046070547612442510212345\x1d1"
+
+	tests := []struct {
+		name    string
+		read    string
+		wantErr bool
+	}{
+		{"совпадение", expected, false},
+		{"совпадение с префиксом символики", "]d2046070547612442510212345\x1d1\r\n", false},
+		{"другая этикетка", "046070547612442510212345\x1d2", true},
+		{"не прочитана", "  ", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewLabelVerifier(staticReader{tt.read}, 1, 0)
+			err := verifier.verify(context.Background(), expected)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verify = %v", err)
+			}
+		})
+	}
+}
+
+func TestLabelVerifierVerifyStopsOnCancel(t *testing.T) {
+	verifier := NewLabelVerifier(staticReader{"code"}, 2, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	done := make(chan error, 1)
+	go func() { done <- verifier.verify(ctx, "code") }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("verify = %v, ожидалась отмена", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ожидание этикетки не прервано отменой контекста")
+	}
+}
+
+func TestRunContextStopCancelsCurrentRun(t *testing.T) {
+	var run runContext
+
+	first := run.start()
+	run.stop()
+	if first.Err() == nil {
+		t.Fatal("контекст запуска не отменен остановкой")
+	}
+	run.stop()
+
+	second := run.start()
+	if second.Err() != nil {
+		t.Fatal("новый запуск получил отмененный контекст")
+	}
+	run.stop()
+	if second.Err() == nil {
+		t.Fatal("контекст второго запуска не отменен")
+	}
+}
@@ -2,162 +2,332 @@ package processors
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/ze674/EZLine/internal/markingcode"
 	"github.com/ze674/EZLine/internal/models"
 	"github.com/ze674/EZLine/internal/repository"
 	"github.com/ze674/EZLine/internal/services"
 	"github.com/ze674/EZLine/internal/validator"
-	"strconv"
 	"strings"
 	"sync"
 )
 
+// ErrLineNotRunning возвращается при закрытии короба на остановленной линии
+var ErrLineNotRunning = errors.New("линия не запущена")
+
 type LayerAggregationProcessor struct {
-	mu                  sync.Mutex
-	running             bool
-	cancelFunc          context.CancelFunc
-	product             *models.Product
-	task                *models.Task
-	dataService         DataService
-	triggerSource       TriggerSource
-	camera              CodeReader
-	printer             Printer
-	codeValidator       *validator.CodeValidator
-	itemRepository      *repository.ItemRepository
-	containerRepository *repository.ContainerRepository
-	serialGenerator     *services.SerialGenerator
-	uniqueValidator     *services.CodeUniquenessValidator
-	pendingCodes        []string // Накопленные промежуточные коды
-	boxCapacity         int      // Емкость короба (сколько всего кодов нужно)
-	layerCapacity       int      // Емкость одного слоя
-	totalLayers         int      // Общее количество слоев
-	labelService        *services.LabelService
+	mu                   sync.Mutex
+	boxMu                sync.Mutex // Защищает короб между обработкой слоя и ручным закрытием
+	wg                   sync.WaitGroup
+	ctx                  context.Context // Контекст работы линии, отменяется при остановке
+	cancelFunc           context.CancelFunc
+	run                  runContext // Отмена работы без блокировки линии
+	state                *stateMachine
+	product              *models.Product
+	task                 *models.Task
+	dataService          DataService
+	triggerSource        TriggerSource
+	camera               CodeReader
+	printer              Printer
+	codeValidator        *validator.CodeValidator
+	containerRepository  *repository.ContainerRepository
+	pendingBoxRepository *repository.PendingBoxRepository // Сохранение несобранного короба на случай перезапуска
+	journal              *scanJournal
+	productWatch         *productWatch // Изменение загруженной карточки продукта в EZFactory
+	plan                 *planTracker
+	serialGenerator      *services.SerialGenerator
+	uniqueValidator      *services.CodeUniquenessValidator
+	collector            *services.ContainerCollector // Накопление кодов по слоям до заполнения короба
+	labelService         *services.LabelService
+	verifier             *LabelVerifier // Проверка напечатанной этикетки, nil - без проверки
+	codeLength           int            // Ожидаемая длина кода
+	equipment            *EquipmentLock // Оборудование, общее с ручной станцией, nil - не делится
 
 	labelData *models.LabelData
 }
 
-func NewLayerAggregationProcessor(dataService DataService, scanner CodeReader, source TriggerSource, labelService *services.LabelService) *LayerAggregationProcessor {
+func NewLayerAggregationProcessor(dataService DataService, scanner CodeReader, source TriggerSource, labelService *services.LabelService, verifier *LabelVerifier, uniqueValidator *services.CodeUniquenessValidator, codeLength int) *LayerAggregationProcessor {
 	return &LayerAggregationProcessor{
-		dataService:         dataService,
-		camera:              scanner,
-		triggerSource:       source,
-		labelService:        labelService,
-		itemRepository:      repository.NewItemRepository(),
-		containerRepository: repository.NewContainerRepository(),
-		serialGenerator:     services.NewSerialGenerator(),
-		uniqueValidator:     services.NewCodeUniquenessValidator(),
+		dataService:          dataService,
+		camera:               scanner,
+		triggerSource:        source,
+		labelService:         labelService,
+		verifier:             verifier,
+		containerRepository:  repository.NewContainerRepository(),
+		pendingBoxRepository: repository.NewPendingBoxRepository(models.PendingBoxStationLine),
+		journal:              newScanJournal(),
+		productWatch:         newProductWatch(),
+		plan:                 newPlanTracker(),
+		serialGenerator:      services.NewSerialGenerator(),
+		uniqueValidator:      uniqueValidator,
+		codeLength:           codeLength,
+		state:                newStateMachine(),
 	}
 }
 
+// UseEquipmentLock задает блокировку оборудования, общего с ручной станцией
+func (p *LayerAggregationProcessor) UseEquipmentLock(lock *EquipmentLock) {
+	p.equipment = lock
+}
+
+// UseSSCC задает префикс SSCC, присваиваемого закрытым коробам
+func (p *LayerAggregationProcessor) UseSSCC(prefix markingcode.SSCCPrefix) {
+	p.containerRepository.UseSSCC(prefix)
+}
+
 func (p *LayerAggregationProcessor) Start(TaskID int) error {
 	op := "processors.LayerAggregationProcessor.Start"
 
-	var err error
-
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if p.running {
+	if p.state.Is(models.ProcessorStateRunning) {
 		return nil
 	}
 
+	// Приостановленный процессор возобновляет работу без перехода через запуск
+	resuming := p.state.Is(models.ProcessorStatePaused)
+	if !resuming {
+		if err := p.state.Transition(models.ProcessorStateStarting, fmt.Sprintf("запуск задания %d", TaskID)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// После аварии освобождаем оборудование, оставшееся от прошлого запуска
+	_ = p.release()
+
+	if err := p.start(TaskID); err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+		_ = p.release()
+		p.state.Fault(err)
+		return err
+	}
+
+	if resuming {
+		return p.state.Transition(models.ProcessorStateRunning, fmt.Sprintf("возобновление задания %d", TaskID))
+	}
+	return p.state.Transition(models.ProcessorStateRunning, "")
+}
+
+func (p *LayerAggregationProcessor) start(TaskID int) error {
+	var err error
+
+	if err := p.equipment.Acquire(p, "линия послойной агрегации"); err != nil {
+		return err
+	}
+
 	err = p.getData(TaskID)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
-	fmt.Println(p.task)
+	// Геометрия короба берется из данных этикетки продукта
+	labelData, err := models.ParseLabelData(*p.product)
+	if err != nil {
+		return err
+	}
+	p.labelData = &labelData
 
-	err = p.connect()
+	packing, err := models.PackingFromLabelData(labelData)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
+	p.collector = services.NewContainerCollector(packing.ContainerCapacity, packing.LayerCapacity, packing.TotalLayers)
+
+	p.codeValidator = validator.NewCodeValidator(p.product.GTIN, p.codeLength)
+
+	pool, err := p.dataService.GetCodePool(p.task.ID)
+	if err != nil {
+		return err
+	}
+	p.codeValidator.UsePool(pool)
 
-	p.codeValidator = validator.NewCodeValidator(p.product.GTIN, 31)
 	err = p.serialGenerator.Initialize(p.task.ID)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
 	if err := p.uniqueValidator.Initialize(p.task.ID); err != nil {
 		return fmt.Errorf("ошибка инициализации валидатора кодов: %w", err)
 	}
-	// Инициализация новых полей с захардкоженными значениями
-	p.boxCapacity = 6           // Фиксированная емкость короба
-	p.layerCapacity = 3         // Фиксированное количество продуктов в слое
-	p.totalLayers = 2           // Фиксированное количество слоев
-	p.pendingCodes = []string{} // Инициализация пустого списка кодов
+
+	if err := p.journal.Initialize(p.task.ID); err != nil {
+		return err
+	}
+
+	if err := p.plan.Initialize(p.task); err != nil {
+		return err
+	}
+
+	if err := restorePendingBox(p.pendingBoxRepository, p.task.ID, p.collector); err != nil {
+		return err
+	}
 
 	// Создаем контекст, который можно будет отменить при остановке
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(p.run.start())
+	p.ctx = ctx
 	p.cancelFunc = cancel
 
+	err = p.connect()
+	if err != nil {
+		return err
+	}
+
 	// Запускаем источник
 	err = p.triggerSource.WaitSignal(ctx)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-	// Парсим LabelData
-	if p.product.LabelData != "" {
-		var labelData models.LabelData
-		if err := json.Unmarshal([]byte(p.product.LabelData), &labelData); err != nil {
-			// Только логируем ошибку, но продолжаем работу
-			fmt.Printf("Ошибка при парсинге LabelData: %v\n", err)
-		} else {
-			p.labelData = &labelData
-		}
+		return err
 	}
 
+	p.wg.Add(1)
 	go p.runScanningLoop(ctx)
 
-	p.running = true
-
 	return nil
 }
 
 // Stop останавливает процессор
 func (p *LayerAggregationProcessor) Stop() error {
+	return p.stop("остановлено оператором")
+}
+
+// stop останавливает процессор и освобождает оборудование с указанием причины
+func (p *LayerAggregationProcessor) stop(reason string) error {
 	op := "processors.LayerAggregationProcessor.Stop"
 
-	var err error
+	// Не ждем под блокировкой линии окончания проверки этикетки закрываемого короба
+	p.run.stop()
 
-	if !p.running {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state.Is(models.ProcessorStateIdle) {
 		return nil
 	}
 
+	if err := p.state.Transition(models.ProcessorStateStopping, reason); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.release(); err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+		p.state.Fault(err)
+		return err
+	}
+
+	return p.state.Transition(models.ProcessorStateIdle, reason)
+}
+
+// Pause приостанавливает линию вместе с заданием: цикл сканирования останавливается, оборудование освобождается.
+// Несобранный короб остается в базе, работа продолжается вызовом Start
+func (p *LayerAggregationProcessor) Pause(reason string) error {
+	op := "processors.LayerAggregationProcessor.Pause"
+
+	p.run.stop()
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if !p.state.Is(models.ProcessorStateRunning) {
+		return nil
+	}
+
+	if err := p.release(); err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+		p.state.Fault(err)
+		return err
+	}
+
+	return p.state.Transition(models.ProcessorStatePaused, reason)
+}
+
+// release останавливает цикл сканирования, источник сигналов, отключает и освобождает оборудование
+func (p *LayerAggregationProcessor) release() error {
+	defer p.equipment.Release(p)
+
+	if p.cancelFunc == nil {
+		return nil
+	}
+
 	// Отменяем контекст, что приведет к завершению цикла сканирования
-	if p.cancelFunc != nil {
-		p.cancelFunc()
-		p.cancelFunc = nil
+	p.cancelFunc()
+	p.cancelFunc = nil
+	p.run.stop()
+
+	p.wg.Wait() // Ожидаем завершения цикла сканирования
+
+	var errs []error
+
+	if err := p.triggerSource.Stop(); err != nil {
+		errs = append(errs, err)
 	}
-	if p.printer != nil {
 
+	if p.printer != nil {
 		// Закрываем соединение с принтером
-		if err = p.printer.Close(); err != nil {
-			return fmt.Errorf("%s: %w", op, err)
+		if err := p.printer.Close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
+
 	if p.camera != nil {
 		// Закрываем соединение с камерой
-		if err = p.camera.Close(); err != nil {
-			return fmt.Errorf("%s: %w", op, err)
+		if err := p.camera.Close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	// Закрываем соединение с принтером
+
+	// Закрываем соединение с принтером этикеток
 	if err := p.labelService.Close(); err != nil {
-		fmt.Printf("Ошибка при закрытии соединения с принтером: %v\n", err)
+		errs = append(errs, err)
 	}
 
-	p.running = false
-	return nil
+	if err := p.verifier.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
 }
 
 func (p *LayerAggregationProcessor) IsRunning() bool {
-	return p.running
+	return p.state.Current().IsActive()
+}
+
+// State возвращает текущее состояние процессора
+func (p *LayerAggregationProcessor) State() models.StateChange {
+	return p.state.Current()
+}
+
+// Subscribe возвращает канал с изменениями состояния процессора и функцию отписки
+func (p *LayerAggregationProcessor) Subscribe() (<-chan models.StateChange, func()) {
+	return p.state.Subscribe()
+}
+
+// Counters возвращает счетчики срабатываний по текущему заданию
+func (p *LayerAggregationProcessor) Counters() models.ScanCounters {
+	return p.journal.Counters()
+}
+
+// ProductChanged предупреждает линию об изменении карточки продукта в EZFactory и сообщает, работает ли она с этим продуктом.
+// Линия продолжает работать по загруженной карточке, новая применяется при следующем запуске
+func (p *LayerAggregationProcessor) ProductChanged(productID int) bool {
+	return p.IsRunning() && p.productWatch.Changed(productID)
+}
+
+// ProductOutdated сообщает, что линия работает по устаревшей карточке продукта
+func (p *LayerAggregationProcessor) ProductOutdated() bool {
+	return p.IsRunning() && p.productWatch.Outdated()
+}
+
+// BoxProgress возвращает заполнение текущего короба
+func (p *LayerAggregationProcessor) BoxProgress() (models.BoxProgress, bool) {
+	p.mu.Lock()
+	collector := p.collector
+	p.mu.Unlock()
+
+	if collector == nil {
+		return models.BoxProgress{}, false
+	}
+	return collector.Progress(), true
 }
 
 func (p *LayerAggregationProcessor) getData(TaskID int) error {
@@ -180,13 +350,14 @@ func (p *LayerAggregationProcessor) getData(TaskID int) error {
 	}
 
 	p.product = &product
+	p.productWatch.Reset(product.ID)
 
 	return nil
 }
 
 func (p *LayerAggregationProcessor) connect() error {
 	op := "processors.LayerAggregationProcessor.connect"
-	fmt.Println("Connecting to printer and camera")
+
 	var err error
 
 	//Подключиться к камере
@@ -196,7 +367,7 @@ func (p *LayerAggregationProcessor) connect() error {
 			return fmt.Errorf("%s: %w", op, err)
 		}
 	}
-	fmt.Println("Connected to camera")
+
 	//Подключиться к принтеру
 	if p.printer != nil {
 		err = p.printer.Connect()
@@ -205,96 +376,231 @@ func (p *LayerAggregationProcessor) connect() error {
 		}
 	}
 
-	// Подключаемся к принтеру
+	// Подключаемся к принтеру этикеток коробов
 	if err := p.labelService.Connect(); err != nil {
-		return err
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Подключаемся к считывателю этикеток
+	if err := p.verifier.Connect(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	fmt.Println("Connected to printer")
 	return nil
 }
 
 func (p *LayerAggregationProcessor) runScanningLoop(ctx context.Context) {
+	defer p.wg.Done()
 
+	scanErrors := 0
 	for {
 		select {
-		case <-p.triggerSource.SignalChan():
-			fmt.Println("Scanning started")
-
-			// Сканируем слой
-			codes, err := p.scanLayer()
-			if err != nil || codes == nil {
-				continue
-			}
-
-			// Проверяем количество кодов
-			//TODO: Сравнить с кол-вом продуктов в коробе
-			if len(codes) != 4 {
-				continue
+		case _, ok := <-p.triggerSource.SignalChan():
+			if !ok {
+				// Источник закрывает канал и при штатной остановке
+				if ctx.Err() == nil {
+					p.state.Fault(errors.New("источник сигналов сканирования остановлен"))
+				}
+				return
 			}
 
-			// Проверяем наличие дубликатов в слое
-			if p.checkDuplicatesInLayer(codes) {
+			if err := p.processLayer(ctx); err != nil {
+				scanErrors++
+				if scanErrors >= maxConsecutiveScanErrors {
+					p.state.Fault(fmt.Errorf("камера не отвечает: %w", err))
+					return
+				}
 				continue
 			}
+			scanErrors = 0
+		case <-ctx.Done():
+			return
 
-			//Валидируем коды
-			validationResult := p.codeValidator.ValidateCodes(codes)
+		}
+	}
+}
 
-			if !validationResult.Valid {
-				continue
-			}
+// processLayer сканирует и обрабатывает один слой.
+// Возвращает ошибку только при сбое камеры
+func (p *LayerAggregationProcessor) processLayer(ctx context.Context) error {
+	p.boxMu.Lock()
+	defer p.boxMu.Unlock()
 
-			// Проверяем, что все коды уникальны в рамках задания
-			isUnique, _ := p.uniqueValidator.IsCodesUnique(codes)
-			if !isUnique {
-				continue
-			}
+	// Сканируем слой
+	raw, codes, err := p.scanLayer()
+	if err != nil {
+		p.journal.Reject(raw, codes, models.ScanReasonScanError, err.Error())
+		return err
+	}
+	if codes == nil {
+		p.journal.Reject(raw, codes, models.ScanReasonNoRead, p.cameraMisses())
+		return nil
+	}
 
-			// Генерируем серийный номер
-			s, err := p.serialGenerator.GenerateSerial()
-			if err != nil {
-				continue
-			}
+	// Проверяем количество кодов в слое
+	expected := p.collector.NextLayerSize()
+	if len(codes) != expected {
+		message := fmt.Sprintf("получено %d кодов, ожидалось %d", len(codes), expected)
+		if misses := p.cameraMisses(); misses != "" {
+			message += "; " + misses
+		}
+		p.journal.Reject(raw, codes, models.ScanReasonWrongCount, message)
+		return nil
+	}
 
-			serialNumber := strconv.Itoa(s)
-			boxCode := p.product.GTIN + p.task.BatchNumber + serialNumber
+	// Проверяем наличие дубликатов в слое
+	if p.checkDuplicatesInLayer(codes) {
+		p.journal.Reject(raw, codes, models.ScanReasonDuplicateInLayer, "")
+		return nil
+	}
 
-			err = p.SaveContainerWithItems(boxCode, s, codes)
-			if err != nil {
-				continue
-			}
+	//Валидируем коды
+	validationResult := p.codeValidator.ValidateCodes(codes)
 
-			p.uniqueValidator.MarkCodesAsUsed(codes)
+	if !validationResult.Valid {
+		p.journal.Reject(raw, codes, models.ScanReasonInvalidCode, invalidCodesMessage(validationResult))
+		return nil
+	}
 
-			fmt.Printf("Scanned codes: %v\n, serial number: %s, task_id: %d", codes)
+	// Проверяем, что все коды уникальны в рамках задания
+	isUnique, duplicates := p.uniqueValidator.IsCodesUnique(codes)
+	if !isUnique {
+		p.journal.Reject(raw, codes, models.ScanReasonNotUnique, strings.Join(duplicates, " "))
+		return nil
+	}
 
-			err = p.labelService.PrintLabel(p.task, p.product, serialNumber)
-			if err != nil {
-				fmt.Printf("Ошибка печати этикетки: %v\n", err)
-			}
-		case <-ctx.Done():
-			return
+	// Добавляем слой в короб и проверяем повторы с предыдущими слоями
+	hasDuplicates, _, isFull := p.collector.AddCodes(codes)
+	if hasDuplicates {
+		p.journal.Reject(raw, codes, models.ScanReasonDuplicateInBox, "")
+		return nil
+	}
 
+	progress := p.collector.Progress()
+	if !isFull {
+		// Сохраняем слой, чтобы не потерять короб при перезапуске
+		if err := p.pendingBoxRepository.AddCodes(p.task.ID, progress.Layer, codes); err != nil {
+			p.collector.RemoveLast(len(codes))
+			p.journal.Reject(raw, codes, models.ScanReasonSaveError, err.Error())
+			return nil
 		}
+
+		p.journal.Accept(raw, codes, fmt.Sprintf("слой %d из %d, %d/%d шт",
+			progress.Layer, progress.TotalLayers, progress.Count, progress.Capacity))
+		return nil
 	}
+
+	p.closeBox(ctx, raw, codes)
+	return nil
+}
+
+// ClosePartialBox закрывает текущий короб с фактическим количеством кодов.
+// Используется в конце партии, когда последний короб остается неполным
+func (p *LayerAggregationProcessor) ClosePartialBox() (models.Container, error) {
+	op := "processors.LayerAggregationProcessor.ClosePartialBox"
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Без запущенной линии нет ни оборудования для этикетки, ни загруженного короба
+	if !p.state.Is(models.ProcessorStateRunning) {
+		return models.Container{}, fmt.Errorf("%s: %w", op, ErrLineNotRunning)
+	}
+
+	// Дожидаемся окончания обработки текущего слоя
+	p.boxMu.Lock()
+	defer p.boxMu.Unlock()
+
+	container, err := closePartialBox(p.ctx, p.task, p.product, p.collector, p.pendingBoxRepository.Station(), p.serialGenerator, p.containerRepository, p.uniqueValidator, p.labelService, p.verifier)
+	if container.ID != 0 && p.plan.Add(container.ItemsCount, 1) {
+		p.onPlanReached()
+	}
+	if err != nil {
+		return container, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return container, nil
+}
+
+// closeBox сохраняет заполненный короб и печатает этикетку.
+// При ошибке последний слой откатывается, чтобы его можно было пересканировать
+func (p *LayerAggregationProcessor) closeBox(ctx context.Context, raw string, layerCodes []string) {
+	boxCodes := p.collector.GetPendingCodes()
+
+	container, reason, err := closeContainer(p.task, p.product, boxCodes, "", p.pendingBoxRepository.Station(), p.serialGenerator, p.containerRepository, p.uniqueValidator)
+	if err != nil {
+		p.collector.RemoveLast(len(layerCodes))
+		p.journal.Reject(raw, layerCodes, reason, err.Error())
+		return
+	}
+
+	p.collector.Reset()
+	p.journal.Accept(raw, layerCodes, fmt.Sprintf("короб %s закрыт, %d шт", container.Code, len(boxCodes)))
+
+	err = printBoxLabel(ctx, p.labelService, p.verifier, p.containerRepository, p.task, p.product, &container, 0)
+	if err != nil {
+		fmt.Printf("Ошибка печати этикетки короба %s: %v\n", container.Code, err)
+	}
+
+	if p.plan.Add(len(boxCodes), 1) {
+		p.onPlanReached()
+	}
+}
+
+// onPlanReached останавливает процессор после выполнения плана
+func (p *LayerAggregationProcessor) onPlanReached() {
+	fmt.Printf("План задания %d выполнен, линия останавливается\n", p.task.ID)
+
+	// Останавливаем асинхронно: stop ожидает завершения цикла, из которого вызван этот метод
+	go p.stop(planReachedReason)
 }
 
-func (p *LayerAggregationProcessor) scanLayer() ([]string, error) {
+// CameraStatuses возвращает диагностику камер, если слой снимает группа камер
+func (p *LayerAggregationProcessor) CameraStatuses() []models.CameraStatus {
+	group, ok := p.camera.(*CameraGroup)
+	if !ok {
+		return nil
+	}
+	return group.CameraStatuses()
+}
+
+// cameraMisses описывает камеры группы, не прочитавшие коды при последнем срабатывании
+func (p *LayerAggregationProcessor) cameraMisses() string {
+	group, ok := p.camera.(*CameraGroup)
+	if !ok {
+		return ""
+	}
+	return group.LastMisses()
+}
+
+// scanLayer сканирует слой и возвращает сырой ответ камеры и разобранные коды.
+// Если код не прочитан, вместо списка кодов возвращается nil
+func (p *LayerAggregationProcessor) scanLayer() (string, []string, error) {
 	op := "processors.LayerAggregationProcessor.scanLayer"
 
 	resp, err := p.camera.Scan()
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return resp, nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if resp == "NoRead" {
-		return nil, nil
+	if resp == noReadResponse {
+		return resp, nil, nil
 	}
 
 	codes := strings.Fields(resp)
 
-	return codes, nil
+	return resp, codes, nil
+}
+
+// invalidCodesMessage собирает описание невалидных кодов из результата валидации
+func invalidCodesMessage(results validator.ValidationResults) string {
+	var parts []string
+	for _, result := range results.Results {
+		if !result.Valid {
+			parts = append(parts, fmt.Sprintf("%s (%s)", result.Code, result.Message))
+		}
+	}
+	return strings.Join(parts, ", ")
 }
 
 // Проверяем наличие дубликатов в слое
@@ -312,38 +618,3 @@ func (p *LayerAggregationProcessor) checkDuplicatesInLayer(codes []string) bool
 	// Дубликатов не найдено
 	return false
 }
-
-// SaveContainerWithItems сохраняет контейнер и связанные с ним товары в базу данных
-func (p *LayerAggregationProcessor) SaveContainerWithItems(containerCode string, serialNumber int, itemCodes []string) error {
-	// 1. Создаем контейнер
-	containerID, err := p.containerRepository.CreateContainer(
-		containerCode,
-		serialNumber,
-		p.task.ID,
-		repository.StatusCreated,
-	)
-	if err != nil {
-		return fmt.Errorf("ошибка создания контейнера: %w", err)
-	}
-
-	// 2. Создаем товары и привязываем их к контейнеру
-	for _, code := range itemCodes {
-		// Создаем запись о товаре
-		itemID, err := p.itemRepository.CreateItem(
-			code,
-			p.task.ID,
-			repository.StatusScanned,
-		)
-		if err != nil {
-			return fmt.Errorf("ошибка создания товара: %w", err)
-		}
-
-		// Привязываем товар к контейнеру
-		err = p.itemRepository.AssignItemToContainer(itemID, containerID)
-		if err != nil {
-			return fmt.Errorf("ошибка привязки товара к контейнеру: %w", err)
-		}
-	}
-
-	return nil
-}
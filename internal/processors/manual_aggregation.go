@@ -0,0 +1,420 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/markingcode"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/internal/services"
+	"github.com/ze674/EZLine/internal/validator"
+	"strings"
+	"sync"
+)
+
+// ErrStationNotRunning возвращается при сканировании на остановленной станции
+var ErrStationNotRunning = errors.New("станция агрегации не запущена")
+
+// ErrStationBusy возвращается при запуске станции, которая уже работает с другим заданием
+var ErrStationBusy = errors.New("станция агрегации работает с другим заданием")
+
+// ManualAggregationProcessor реализует ручную станцию агрегации:
+// оператор сканирует коды по одному, короб закрывается автоматически при заполнении
+type ManualAggregationProcessor struct {
+	mu                   sync.Mutex
+	state                *stateMachine
+	dataService          DataService
+	task                 *models.Task
+	product              *models.Product
+	codeLength           int
+	codeValidator        *validator.CodeValidator
+	uniqueValidator      *services.CodeUniquenessValidator
+	serialGenerator      *services.SerialGenerator
+	containerRepository  *repository.ContainerRepository
+	pendingBoxRepository *repository.PendingBoxRepository // Сохранение несобранного короба на случай перезапуска
+	collector            *services.ContainerCollector
+	labelService         *services.LabelService
+	verifier             *LabelVerifier // Проверка напечатанной этикетки, nil - без проверки
+	journal              *scanJournal
+	productWatch         *productWatch // Изменение загруженной карточки продукта в EZFactory
+	plan                 *planTracker
+	equipment            *EquipmentLock  // Оборудование, общее с линией, nil - не делится
+	ctx                  context.Context // Контекст работы станции, отменяется при остановке
+	run                  runContext
+}
+
+func NewManualAggregationProcessor(dataService DataService, labelService *services.LabelService, verifier *LabelVerifier, uniqueValidator *services.CodeUniquenessValidator, codeLength int) *ManualAggregationProcessor {
+	return &ManualAggregationProcessor{
+		dataService:          dataService,
+		labelService:         labelService,
+		verifier:             verifier,
+		codeLength:           codeLength,
+		uniqueValidator:      uniqueValidator,
+		serialGenerator:      services.NewSerialGenerator(),
+		containerRepository:  repository.NewContainerRepository(),
+		pendingBoxRepository: repository.NewPendingBoxRepository(models.PendingBoxStationManual),
+		journal:              newScanJournal(),
+		productWatch:         newProductWatch(),
+		plan:                 newPlanTracker(),
+		state:                newStateMachine(),
+	}
+}
+
+// UseEquipmentLock задает блокировку оборудования, общего с линией
+func (p *ManualAggregationProcessor) UseEquipmentLock(lock *EquipmentLock) {
+	p.equipment = lock
+}
+
+// UseSSCC задает префикс SSCC, присваиваемого закрытым коробам
+func (p *ManualAggregationProcessor) UseSSCC(prefix markingcode.SSCCPrefix) {
+	p.containerRepository.UseSSCC(prefix)
+}
+
+func (p *ManualAggregationProcessor) Start(TaskID int) error {
+	op := "processors.ManualAggregationProcessor.Start"
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Повторный запуск того же задания ничего не меняет, другое задание требует остановки станции
+	if p.state.Is(models.ProcessorStateRunning) {
+		if p.task.ID != TaskID {
+			return fmt.Errorf("%s: %w: задание %d", op, ErrStationBusy, p.task.ID)
+		}
+		return nil
+	}
+
+	// Приостановленный процессор возобновляет работу без перехода через запуск
+	resuming := p.state.Is(models.ProcessorStatePaused)
+	if !resuming {
+		if err := p.state.Transition(models.ProcessorStateStarting, fmt.Sprintf("запуск задания %d", TaskID)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := p.start(TaskID); err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+		_ = p.release()
+		p.state.Fault(err)
+		return err
+	}
+
+	if resuming {
+		return p.state.Transition(models.ProcessorStateRunning, fmt.Sprintf("возобновление задания %d", TaskID))
+	}
+	return p.state.Transition(models.ProcessorStateRunning, "")
+}
+
+func (p *ManualAggregationProcessor) start(TaskID int) error {
+	if err := p.equipment.Acquire(p, "ручная станция агрегации"); err != nil {
+		return err
+	}
+
+	task, err := p.dataService.GetTaskByID(TaskID)
+	if err != nil {
+		return err
+	}
+
+	product, err := p.dataService.GetProductByID(task.ProductID)
+	if err != nil {
+		return err
+	}
+
+	labelData, err := models.ParseLabelData(product)
+	if err != nil {
+		return err
+	}
+
+	packing, err := models.PackingFromLabelData(labelData)
+	if err != nil {
+		return err
+	}
+
+	pool, err := p.dataService.GetCodePool(task.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := p.serialGenerator.Initialize(task.ID); err != nil {
+		return err
+	}
+
+	if err := p.uniqueValidator.Initialize(task.ID); err != nil {
+		return err
+	}
+
+	if err := p.journal.Initialize(task.ID); err != nil {
+		return err
+	}
+
+	if err := p.plan.Initialize(&task); err != nil {
+		return err
+	}
+
+	if err := p.labelService.Connect(); err != nil {
+		return err
+	}
+
+	if err := p.verifier.Connect(); err != nil {
+		return err
+	}
+
+	collector := services.NewContainerCollector(packing.ContainerCapacity, packing.LayerCapacity, packing.TotalLayers)
+	if err := restorePendingBox(p.pendingBoxRepository, task.ID, collector); err != nil {
+		return err
+	}
+
+	p.task = &task
+	p.product = &product
+	p.productWatch.Reset(product.ID)
+	p.codeValidator = validator.NewCodeValidator(product.GTIN, p.codeLength)
+	p.codeValidator.UsePool(pool)
+	p.collector = collector
+	p.ctx = p.run.start()
+
+	return nil
+}
+
+// Stop останавливает станцию. Несобранный короб сохраняется и восстанавливается при следующем запуске
+func (p *ManualAggregationProcessor) Stop() error {
+	return p.stop("остановлено оператором")
+}
+
+// stop останавливает станцию с указанием причины
+func (p *ManualAggregationProcessor) stop(reason string) error {
+	// Не ждем под блокировкой станции окончания проверки этикетки закрываемого короба
+	p.run.stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.halt(reason)
+}
+
+// halt останавливает станцию, вызывается под блокировкой станции
+func (p *ManualAggregationProcessor) halt(reason string) error {
+	op := "processors.ManualAggregationProcessor.Stop"
+
+	if p.state.Is(models.ProcessorStateIdle) {
+		return nil
+	}
+
+	if err := p.state.Transition(models.ProcessorStateStopping, reason); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.release(); err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+		p.state.Fault(err)
+		return err
+	}
+
+	return p.state.Transition(models.ProcessorStateIdle, reason)
+}
+
+// Pause приостанавливает станцию вместе с заданием и освобождает оборудование.
+// Несобранный короб остается в базе, работа продолжается вызовом Start
+func (p *ManualAggregationProcessor) Pause(reason string) error {
+	op := "processors.ManualAggregationProcessor.Pause"
+
+	p.run.stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.state.Is(models.ProcessorStateRunning) {
+		return nil
+	}
+
+	if err := p.release(); err != nil {
+		err = fmt.Errorf("%s: %w", op, err)
+		p.state.Fault(err)
+		return err
+	}
+
+	return p.state.Transition(models.ProcessorStatePaused, reason)
+}
+
+// release отключает и освобождает оборудование станции.
+// Оборудование, занятое линией, станция не трогает
+func (p *ManualAggregationProcessor) release() error {
+	// Прерываем ожидание проверки этикетки
+	p.run.stop()
+
+	if !p.equipment.Owns(p) {
+		return nil
+	}
+	defer p.equipment.Release(p)
+
+	return errors.Join(p.labelService.Close(), p.verifier.Close())
+}
+
+func (p *ManualAggregationProcessor) IsRunning() bool {
+	return p.state.Current().IsActive()
+}
+
+// State возвращает текущее состояние станции
+func (p *ManualAggregationProcessor) State() models.StateChange {
+	return p.state.Current()
+}
+
+// Subscribe возвращает канал с изменениями состояния станции и функцию отписки
+func (p *ManualAggregationProcessor) Subscribe() (<-chan models.StateChange, func()) {
+	return p.state.Subscribe()
+}
+
+// Counters возвращает счетчики срабатываний по текущему заданию
+func (p *ManualAggregationProcessor) Counters() models.ScanCounters {
+	return p.journal.Counters()
+}
+
+// ProductChanged предупреждает станцию об изменении карточки продукта в EZFactory и сообщает, работает ли она с этим продуктом.
+// Станция продолжает работать по загруженной карточке, новая применяется при следующем запуске
+func (p *ManualAggregationProcessor) ProductChanged(productID int) bool {
+	return p.IsRunning() && p.productWatch.Changed(productID)
+}
+
+// ProductOutdated сообщает, что станция работает по устаревшей карточке продукта
+func (p *ManualAggregationProcessor) ProductOutdated() bool {
+	return p.IsRunning() && p.productWatch.Outdated()
+}
+
+// BoxProgress возвращает заполнение текущего короба
+func (p *ManualAggregationProcessor) BoxProgress() (models.BoxProgress, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.collector == nil {
+		return models.BoxProgress{}, false
+	}
+	return p.collector.Progress(), true
+}
+
+// PendingCodes возвращает коды, уже уложенные в текущий короб
+func (p *ManualAggregationProcessor) PendingCodes() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.collector == nil {
+		return nil
+	}
+	return p.collector.GetPendingCodes()
+}
+
+// SubmitCode обрабатывает код, отсканированный оператором.
+// Код сразу проверяется, при заполнении короба он закрывается и печатается этикетка
+func (p *ManualAggregationProcessor) SubmitCode(code string) (models.ManualScanResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.state.Is(models.ProcessorStateRunning) {
+		return models.ManualScanResult{}, ErrStationNotRunning
+	}
+
+	code = strings.TrimSpace(code)
+	result := models.ManualScanResult{Code: code}
+
+	if code == "" {
+		return p.rejectCode(result, models.ScanReasonNoRead, ""), nil
+	}
+
+	validationResult := p.codeValidator.ValidateCode(code)
+	if !validationResult.Valid {
+		return p.rejectCode(result, models.ScanReasonInvalidCode, validationResult.Message), nil
+	}
+
+	if !p.uniqueValidator.IsCodeUnique(code) {
+		return p.rejectCode(result, models.ScanReasonNotUnique, "код уже агрегирован в задании"), nil
+	}
+
+	hasDuplicates, _, isFull := p.collector.AddCodes([]string{code})
+	if hasDuplicates {
+		return p.rejectCode(result, models.ScanReasonDuplicateInBox, "код уже уложен в текущий короб"), nil
+	}
+
+	if !isFull {
+		progress := p.collector.Progress()
+
+		// Сохраняем код, чтобы не потерять короб при перезапуске
+		if err := p.pendingBoxRepository.AddCodes(p.task.ID, progress.Layer, []string{code}); err != nil {
+			p.collector.RemoveLast(1)
+			return p.rejectCode(result, models.ScanReasonSaveError, err.Error()), nil
+		}
+
+		result.Accepted = true
+		result.Progress = progress
+		p.journal.Accept(code, []string{code}, "")
+		return result, nil
+	}
+
+	boxCodes := p.collector.GetPendingCodes()
+
+	container, reason, err := closeContainer(p.task, p.product, boxCodes, "", p.pendingBoxRepository.Station(), p.serialGenerator, p.containerRepository, p.uniqueValidator)
+	if err != nil {
+		p.collector.RemoveLast(1)
+		return p.rejectCode(result, reason, err.Error()), nil
+	}
+
+	p.collector.Reset()
+	p.journal.Accept(code, []string{code}, fmt.Sprintf("короб %s закрыт, %d шт", container.Code, len(boxCodes)))
+
+	result.Accepted = true
+	result.BoxClosed = true
+	result.ContainerCode = container.Code
+	result.Progress = p.collector.Progress()
+
+	if err := printBoxLabel(p.ctx, p.labelService, p.verifier, p.containerRepository, p.task, p.product, &container, 0); err != nil {
+		result.Message = "короб закрыт, но этикетка не напечатана: " + err.Error()
+		result.LabelFailed = true
+	}
+
+	if p.plan.Add(len(boxCodes), 1) {
+		result.PlanReached = true
+		p.onPlanReached()
+	}
+
+	return result, nil
+}
+
+// ClosePartialBox закрывает текущий короб с фактическим количеством кодов.
+// Используется в конце партии, когда последний короб остается неполным
+func (p *ManualAggregationProcessor) ClosePartialBox() (models.Container, error) {
+	op := "processors.ManualAggregationProcessor.ClosePartialBox"
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.state.Is(models.ProcessorStateRunning) {
+		return models.Container{}, fmt.Errorf("%s: %w", op, ErrStationNotRunning)
+	}
+
+	container, err := closePartialBox(p.ctx, p.task, p.product, p.collector, p.pendingBoxRepository.Station(), p.serialGenerator, p.containerRepository, p.uniqueValidator, p.labelService, p.verifier)
+	if container.ID != 0 && p.plan.Add(container.ItemsCount, 1) {
+		p.onPlanReached()
+	}
+	if err != nil {
+		return container, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return container, nil
+}
+
+// onPlanReached останавливает станцию после выполнения плана, вызывается под блокировкой станции
+func (p *ManualAggregationProcessor) onPlanReached() {
+	fmt.Printf("План задания %d выполнен, станция останавливается\n", p.task.ID)
+
+	if err := p.halt(planReachedReason); err != nil {
+		fmt.Printf("Ошибка остановки станции: %v\n", err)
+	}
+}
+
+func (p *ManualAggregationProcessor) rejectCode(result models.ManualScanResult, reason, message string) models.ManualScanResult {
+	p.journal.Reject(result.Code, []string{result.Code}, reason, message)
+
+	result.Accepted = false
+	result.Reason = reason
+	result.Message = message
+	result.Progress = p.collector.Progress()
+	return result
+}
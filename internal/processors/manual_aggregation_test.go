@@ -0,0 +1,301 @@
+package processors
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/ze674/EZLine/internal/adapters"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/internal/services"
+	"github.com/ze674/EZLine/internal/validator"
+)
+
+// stationData - данные заданий и продукта для запуска станции без EZFactory
+type stationData struct {
+	tasks   map[int]models.Task
+	product models.Product
+}
+
+func (d stationData) GetTaskByID(taskID int) (models.Task, error) {
+	task, ok := d.tasks[taskID]
+	if !ok {
+		return models.Task{}, errors.New("задание не найдено")
+	}
+	return task, nil
+}
+
+func (d stationData) GetProductByID(productID int) (models.Product, error) {
+	return d.product, nil
+}
+
+func (d stationData) GetCodePool(taskID int) (*validator.CodePool, error) {
+	return nil, nil
+}
+
+// startPrinter запускает TCP-сервер, который принимает и отбрасывает этикетки, и возвращает его адрес
+func startPrinter(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// itemCode возвращает код товара тестового продукта с серийным номером serial из 6 символов
+func itemCode(serial string) string {
+	return "01" + testGTIN + "21" + serial
+}
+
+// newTestStation создает ручную станцию для заданий 1 и 2 продукта с коробом на capacity кодов.
+// plannedQuantity задает план задания 1, 0 - без плана
+func newTestStation(t *testing.T, capacity string, plannedQuantity int) *ManualAggregationProcessor {
+	t.Helper()
+
+	data := stationData{
+		tasks: map[int]models.Task{
+			1: {ID: 1, ProductID: 1, BatchNumber: "1", PlannedQuantity: plannedQuantity},
+			2: {ID: 2, ProductID: 1, BatchNumber: "2"},
+		},
+		product: models.Product{ID: 1, GTIN: testGTIN, LabelData: `{"QuantityBox": "` + capacity + `"}`},
+	}
+
+	labelService := services.NewLabelService(adapters.NewPrinter(startPrinter(t)), "label/templates", "")
+	p := NewManualAggregationProcessor(data, labelService, nil, services.NewCodeUniquenessValidator(), len(testCode))
+	t.Cleanup(func() { _ = p.Stop() })
+	return p
+}
+
+func TestManualAggregationSubmitCode(t *testing.T) {
+	type step struct {
+		code      string
+		reason    string // Причина отклонения, пусто - код принят
+		boxClosed bool
+	}
+
+	tests := []struct {
+		name        string
+		steps       []step
+		wantBoxes   int
+		wantPending int // Кодов в несобранном коробе после всех сканирований
+		wantClosed  []int
+	}{
+		{"короб закрывается при заполнении", []step{
+			{code: itemCode("AAAAA1")},
+			{code: itemCode("AAAAA2")},
+			{code: itemCode("AAAAA3"), boxClosed: true},
+		}, 1, 0, []int{3}},
+		{"новый короб после закрытия", []step{
+			{code: itemCode("AAAAA1")},
+			{code: itemCode("AAAAA2")},
+			{code: itemCode("AAAAA3"), boxClosed: true},
+			{code: itemCode("AAAAA4")},
+		}, 1, 1, []int{3}},
+		{"пустой ввод", []step{
+			{code: "  ", reason: models.ScanReasonNoRead},
+		}, 0, 0, nil},
+		{"чужой код", []step{
+			{code: itemCode("AAAAA1") + "X", reason: models.ScanReasonInvalidCode},
+			{code: "01" + "04607054766165" + "21AAAAA1", reason: models.ScanReasonInvalidCode},
+		}, 0, 0, nil},
+		{"повтор в коробе", []step{
+			{code: itemCode("AAAAA1")},
+			{code: itemCode("AAAAA1"), reason: models.ScanReasonDuplicateInBox},
+		}, 0, 1, nil},
+		{"код из закрытого короба", []step{
+			{code: itemCode("AAAAA1")},
+			{code: itemCode("AAAAA2")},
+			{code: itemCode("AAAAA3"), boxClosed: true},
+			{code: itemCode("AAAAA2"), reason: models.ScanReasonNotUnique},
+		}, 1, 0, []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+
+			p := newTestStation(t, "3", 0)
+			if err := p.Start(1); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			for i, s := range tt.steps {
+				result, err := p.SubmitCode(s.code)
+				if err != nil {
+					t.Fatalf("шаг %d: SubmitCode: %v", i+1, err)
+				}
+				if result.Accepted != (s.reason == "") || result.Reason != s.reason {
+					t.Errorf("шаг %d: принят %v, причина %q, ожидалась причина %q", i+1, result.Accepted, result.Reason, s.reason)
+				}
+				if result.BoxClosed != s.boxClosed {
+					t.Errorf("шаг %d: короб закрыт %v, ожидалось %v", i+1, result.BoxClosed, s.boxClosed)
+				}
+				if result.LabelFailed {
+					t.Errorf("шаг %d: этикетка не напечатана: %s", i+1, result.Message)
+				}
+			}
+
+			containers, err := repository.NewContainerRepository().GetContainersByTaskID(1)
+			if err != nil {
+				t.Fatalf("GetContainersByTaskID: %v", err)
+			}
+			if len(containers) != tt.wantBoxes {
+				t.Fatalf("коробов %d, ожидалось %d", len(containers), tt.wantBoxes)
+			}
+			for i, container := range containers {
+				items, err := repository.NewItemRepository().GetItemsByContainerID(container.ID)
+				if err != nil {
+					t.Fatalf("GetItemsByContainerID: %v", err)
+				}
+				if len(items) != tt.wantClosed[i] {
+					t.Errorf("короб %s: товаров %d, ожидалось %d", container.Code, len(items), tt.wantClosed[i])
+				}
+			}
+
+			if got := len(p.PendingCodes()); got != tt.wantPending {
+				t.Errorf("кодов в несобранном коробе %d, ожидалось %d", got, tt.wantPending)
+			}
+			pending, err := repository.NewPendingBoxRepository(models.PendingBoxStationManual).GetCodes(1)
+			if err != nil {
+				t.Fatalf("GetCodes: %v", err)
+			}
+			if len(pending) != tt.wantPending {
+				t.Errorf("сохранено кодов несобранного короба %d, ожидалось %d", len(pending), tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestManualAggregationStopsWhenPlanReached(t *testing.T) {
+	setupDB(t)
+
+	p := newTestStation(t, "2", 2)
+	if err := p.Start(1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if _, err := p.SubmitCode(itemCode("AAAAA1")); err != nil {
+		t.Fatalf("SubmitCode: %v", err)
+	}
+	result, err := p.SubmitCode(itemCode("AAAAA2"))
+	if err != nil {
+		t.Fatalf("SubmitCode: %v", err)
+	}
+	if !result.BoxClosed || !result.PlanReached {
+		t.Errorf("результат = %+v, ожидалось закрытие короба и выполнение плана", result)
+	}
+	if state := p.State(); state.State != models.ProcessorStateIdle {
+		t.Errorf("состояние %s, станция должна остановиться", state.State)
+	}
+
+	if _, err := p.SubmitCode(itemCode("AAAAA3")); !errors.Is(err, ErrStationNotRunning) {
+		t.Errorf("SubmitCode после остановки = %v, ожидалась %v", err, ErrStationNotRunning)
+	}
+}
+
+func TestManualAggregationStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		taskID  int
+		wantErr error
+	}{
+		{"то же задание", 1, nil},
+		{"другое задание", 2, ErrStationBusy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+
+			p := newTestStation(t, "3", 0)
+			if err := p.Start(1); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			if err := p.Start(tt.taskID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Start(%d) = %v, ожидалась %v", tt.taskID, err, tt.wantErr)
+			}
+			if p.task.ID != 1 || !p.IsRunning() {
+				t.Errorf("станция работает с заданием %d (запущена: %v), ожидалось задание 1", p.task.ID, p.IsRunning())
+			}
+		})
+	}
+}
+
+func TestManualAggregationClosePartialBox(t *testing.T) {
+	tests := []struct {
+		name      string
+		scanned   []string
+		wantErr   error
+		wantItems int
+	}{
+		{"короб пуст", nil, ErrNoPendingBox, 0},
+		{"неполный короб", []string{itemCode("AAAAA1"), itemCode("AAAAA2")}, nil, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+
+			p := newTestStation(t, "3", 0)
+			if err := p.Start(1); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			for _, code := range tt.scanned {
+				if result, err := p.SubmitCode(code); err != nil || !result.Accepted {
+					t.Fatalf("SubmitCode(%s) = %+v, %v", code, result, err)
+				}
+			}
+
+			container, err := p.ClosePartialBox()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ClosePartialBox = %v, ожидалась %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !container.Partial || container.ItemsCount != tt.wantItems {
+				t.Errorf("короб = %+v, ожидался неполный короб на %d шт", container, tt.wantItems)
+			}
+
+			events, err := repository.NewContainerEventRepository().GetEventsByContainerID(container.ID)
+			if err != nil {
+				t.Fatalf("GetEventsByContainerID: %v", err)
+			}
+			if len(events) != 1 || events[0].Action != models.ContainerActionClosePartial || events[0].Reason != "закрыт неполным: 2 из 3 шт" {
+				t.Errorf("журнал короба = %+v, ожидалось закрытие неполным", events)
+			}
+
+			if codes := p.PendingCodes(); len(codes) != 0 {
+				t.Errorf("после закрытия в коробе остались коды %v", codes)
+			}
+			pending, err := repository.NewPendingBoxRepository(models.PendingBoxStationManual).GetCodes(1)
+			if err != nil {
+				t.Fatalf("GetCodes: %v", err)
+			}
+			if len(pending) != 0 {
+				t.Errorf("сохраненные коды несобранного короба не очищены: %d", len(pending))
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package processors
+
+import (
+	"fmt"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"sync"
+)
+
+// Причина остановки процессора по выполнению плана
+const planReachedReason = "план задания выполнен"
+
+// ConveyorController реализуется ПЛК, который умеет останавливать конвейер по выполнению плана
+type ConveyorController interface {
+	StopConveyor() error
+	ResumeConveyor() error
+}
+
+// planTracker следит за выполнением плана задания: предупреждает при приближении к плану
+// и сообщает, когда план выполнен. Счетчики загружаются из базы при запуске и растут в памяти
+type planTracker struct {
+	mu                  sync.Mutex
+	progress            models.PlanProgress
+	warned              bool // Предупреждение о приближении к плану уже выдано
+	itemRepository      *repository.ItemRepository
+	containerRepository *repository.ContainerRepository
+}
+
+func newPlanTracker() *planTracker {
+	return &planTracker{
+		itemRepository:      repository.NewItemRepository(),
+		containerRepository: repository.NewContainerRepository(),
+	}
+}
+
+// Initialize загружает план задания и уже произведенное количество
+func (t *planTracker) Initialize(task *models.Task) error {
+	op := "processors.planTracker.Initialize"
+
+	quantity, err := t.itemRepository.CountByTaskID(task.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	boxes, err := t.containerRepository.CountByTaskID(task.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.progress = models.PlanProgress{Plan: task.Plan(), Quantity: quantity, Boxes: boxes}
+	t.warned = t.progress.IsApproaching()
+
+	if t.progress.IsReached() {
+		fmt.Printf("План задания %d уже выполнен (%d%%), продукция сверх плана\n", task.ID, t.progress.Percent())
+	}
+
+	return nil
+}
+
+// Add учитывает произведенную продукцию и возвращает true, если этим добавлением план выполнен
+func (t *planTracker) Add(quantity, boxes int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wasReached := t.progress.IsReached()
+
+	t.progress.Quantity += quantity
+	t.progress.Boxes += boxes
+
+	if t.progress.IsApproaching() && !t.warned {
+		t.warned = true
+		fmt.Printf("План задания близок к выполнению: %d%% (%d шт, %d коробов)\n",
+			t.progress.Percent(), t.progress.Quantity, t.progress.Boxes)
+	}
+
+	return !wasReached && t.progress.IsReached()
+}
+
+// Progress возвращает текущее выполнение плана
+func (t *planTracker) Progress() models.PlanProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.progress
+}
@@ -0,0 +1,86 @@
+package processors
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+)
+
+// planAdd - продукция, учтенная трекером одним вызовом Add
+type planAdd struct {
+	quantity, boxes int
+}
+
+func TestPlanTracker(t *testing.T) {
+	tests := []struct {
+		name        string
+		task        models.Task
+		produced    int // Товаров в одном коробе, сохраненном до запуска, 0 - ничего не произведено
+		adds        []planAdd
+		wantReached []bool // Результаты Add по порядку
+		wantWarned  bool   // Выдано предупреждение о приближении: при скачке сразу к выполнению его нет
+		want        models.PlanProgress
+	}{
+		{"без плана", models.Task{ID: 1}, 0,
+			[]planAdd{{10, 1}, {10, 1}}, []bool{false, false}, false,
+			models.PlanProgress{Quantity: 20, Boxes: 2}},
+		{"план по количеству", models.Task{ID: 1, PlannedQuantity: 10}, 0,
+			[]planAdd{{5, 1}, {5, 1}, {5, 1}}, []bool{false, true, false}, false,
+			models.PlanProgress{Plan: models.TaskPlan{Quantity: 10}, Quantity: 15, Boxes: 3}},
+		{"план по коробам", models.Task{ID: 1, PlannedBoxes: 2}, 0,
+			[]planAdd{{3, 1}, {3, 1}}, []bool{false, true}, false,
+			models.PlanProgress{Plan: models.TaskPlan{Boxes: 2}, Quantity: 6, Boxes: 2}},
+		{"приближение к плану", models.Task{ID: 1, PlannedQuantity: 10}, 0,
+			[]planAdd{{8, 1}, {1, 0}}, []bool{false, false}, true,
+			models.PlanProgress{Plan: models.TaskPlan{Quantity: 10}, Quantity: 9, Boxes: 1}},
+		{"ниже порога предупреждения", models.Task{ID: 1, PlannedQuantity: 10}, 0,
+			[]planAdd{{8, 1}}, []bool{false}, false,
+			models.PlanProgress{Plan: models.TaskPlan{Quantity: 10}, Quantity: 8, Boxes: 1}},
+		{"продолжение после перезапуска", models.Task{ID: 1, PlannedQuantity: 10}, 9,
+			[]planAdd{{1, 1}}, []bool{true}, true,
+			models.PlanProgress{Plan: models.TaskPlan{Quantity: 10}, Quantity: 10, Boxes: 2}},
+		{"план выполнен до запуска", models.Task{ID: 1, PlannedQuantity: 10}, 10,
+			[]planAdd{{1, 0}}, []bool{false}, false,
+			models.PlanProgress{Plan: models.TaskPlan{Quantity: 10}, Quantity: 11, Boxes: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+
+			if tt.produced > 0 {
+				var codes []string
+				for i := range tt.produced {
+					codes = append(codes, itemCode(fmt.Sprintf("AAAA%02d", i)))
+				}
+				if _, err := repository.NewContainerRepository().CreateContainerWithItems("046070547612440000001", 1, tt.task.ID,
+					models.PendingBoxStationLine, codes); err != nil {
+					t.Fatalf("CreateContainerWithItems: %v", err)
+				}
+			}
+
+			tracker := newPlanTracker()
+			if err := tracker.Initialize(&tt.task); err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+
+			var reached []bool
+			for _, add := range tt.adds {
+				reached = append(reached, tracker.Add(add.quantity, add.boxes))
+			}
+
+			if !slices.Equal(reached, tt.wantReached) {
+				t.Errorf("Add = %v, ожидалось %v", reached, tt.wantReached)
+			}
+			if tracker.warned != tt.wantWarned {
+				t.Errorf("предупреждение выдано: %v, ожидалось %v", tracker.warned, tt.wantWarned)
+			}
+			if got := tracker.Progress(); got != tt.want {
+				t.Errorf("выполнение %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
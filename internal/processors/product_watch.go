@@ -0,0 +1,45 @@
+package processors
+
+import "sync"
+
+// productWatch отмечает, что карточка продукта, загруженная процессором при запуске, изменена в EZFactory.
+// Процессор дорабатывает по загруженной карточке, новая применяется при следующем запуске
+type productWatch struct {
+	mu        sync.Mutex
+	productID int
+	changed   bool
+}
+
+func newProductWatch() *productWatch {
+	return &productWatch{}
+}
+
+// Reset запоминает продукт, карточку которого процессор загрузил при запуске
+func (w *productWatch) Reset(productID int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.productID = productID
+	w.changed = false
+}
+
+// Changed отмечает изменение карточки продукта и сообщает, касается ли оно загруженной карточки
+func (w *productWatch) Changed(productID int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.productID == 0 || w.productID != productID {
+		return false
+	}
+
+	w.changed = true
+	return true
+}
+
+// Outdated сообщает, что загруженная карточка продукта изменена в EZFactory
+func (w *productWatch) Outdated() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.changed
+}
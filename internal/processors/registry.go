@@ -0,0 +1,302 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/models"
+	"sync"
+)
+
+// ErrModeNotSupported возвращается, если для режима работы линии не зарегистрирован процессор
+var ErrModeNotSupported = errors.New("режим работы линии не поддерживается")
+
+// ProcessorFactory создает процессор режима вместе с нужным ему оборудованием
+type ProcessorFactory func() (TaskProcessor, error)
+
+// Registry выбирает процессор по режиму работы линии.
+// Режим берется из профиля продукта, а если он там не задан - из настроек линии.
+// Процессоры создаются при первом запуске задания в своем режиме и переиспользуются
+type Registry struct {
+	mu          sync.Mutex
+	dataService DataService
+	defaultMode models.ProcessorMode
+	factories   map[models.ProcessorMode]ProcessorFactory
+	processors  map[models.ProcessorMode]TaskProcessor
+	current     TaskProcessor
+	mode        models.ProcessorMode
+
+	subMu       sync.Mutex
+	subscribers map[int]chan models.StateChange
+	nextID      int
+}
+
+func NewRegistry(dataService DataService, defaultMode models.ProcessorMode) *Registry {
+	return &Registry{
+		dataService: dataService,
+		defaultMode: defaultMode,
+		factories:   make(map[models.ProcessorMode]ProcessorFactory),
+		processors:  make(map[models.ProcessorMode]TaskProcessor),
+		subscribers: make(map[int]chan models.StateChange),
+	}
+}
+
+// Register регистрирует фабрику процессора для режима
+func (r *Registry) Register(mode models.ProcessorMode, factory ProcessorFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factories[mode] = factory
+}
+
+// Start определяет режим задания и запускает соответствующий процессор
+func (r *Registry) Start(TaskID int) error {
+	op := "processors.Registry.Start"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current != nil && r.current.IsRunning() {
+		return nil
+	}
+
+	mode, err := r.resolveMode(TaskID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	processor, err := r.processor(mode)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	r.current = processor
+	r.mode = mode
+
+	return processor.Start(TaskID)
+}
+
+func (r *Registry) Stop() error {
+	current := r.currentProcessor()
+	if current == nil {
+		return nil
+	}
+	return current.Stop()
+}
+
+// Pause приостанавливает текущий процессор
+func (r *Registry) Pause(reason string) error {
+	current := r.currentProcessor()
+	if current == nil {
+		return nil
+	}
+	return current.Pause(reason)
+}
+
+func (r *Registry) IsRunning() bool {
+	current := r.currentProcessor()
+	return current != nil && current.IsRunning()
+}
+
+// Counters возвращает счетчики текущего процессора
+func (r *Registry) Counters() models.ScanCounters {
+	current := r.currentProcessor()
+	if current == nil {
+		return models.ScanCounters{ByReason: map[string]int{}}
+	}
+	return current.Counters()
+}
+
+// State возвращает состояние текущего процессора
+func (r *Registry) State() models.StateChange {
+	current := r.currentProcessor()
+	if current == nil {
+		return models.StateChange{State: models.ProcessorStateIdle}
+	}
+	return current.State()
+}
+
+// BoxProgress возвращает заполнение короба, если текущий процессор собирает короба
+func (r *Registry) BoxProgress() (models.BoxProgress, bool) {
+	reporter, ok := r.currentProcessor().(interface {
+		BoxProgress() (models.BoxProgress, bool)
+	})
+	if !ok {
+		return models.BoxProgress{}, false
+	}
+	return reporter.BoxProgress()
+}
+
+// ClosePartialBox закрывает неполный короб, если текущий процессор собирает короба
+func (r *Registry) ClosePartialBox() (models.Container, error) {
+	closer, ok := r.currentProcessor().(interface {
+		ClosePartialBox() (models.Container, error)
+	})
+	if !ok {
+		return models.Container{}, ErrNoPendingBox
+	}
+	return closer.ClosePartialBox()
+}
+
+// CameraStatuses возвращает диагностику камер текущего процессора, если он снимает слой группой камер
+func (r *Registry) CameraStatuses() []models.CameraStatus {
+	reporter, ok := r.currentProcessor().(interface {
+		CameraStatuses() []models.CameraStatus
+	})
+	if !ok {
+		return nil
+	}
+	return reporter.CameraStatuses()
+}
+
+// DeviceHealth возвращает состояние оборудования текущего процессора, если он ведет его диагностику
+func (r *Registry) DeviceHealth() []models.DeviceHealth {
+	reporter, ok := r.currentProcessor().(interface {
+		DeviceHealth() []models.DeviceHealth
+	})
+	if !ok {
+		return nil
+	}
+	return reporter.DeviceHealth()
+}
+
+// ProductChanged предупреждает текущий процессор об изменении карточки продукта
+// и сообщает, работает ли он с этим продуктом
+func (r *Registry) ProductChanged(productID int) bool {
+	listener, ok := r.currentProcessor().(interface {
+		ProductChanged(productID int) bool
+	})
+	if !ok {
+		return false
+	}
+	return listener.ProductChanged(productID)
+}
+
+// ProductOutdated сообщает, что текущий процессор работает по устаревшей карточке продукта
+func (r *Registry) ProductOutdated() bool {
+	listener, ok := r.currentProcessor().(interface {
+		ProductOutdated() bool
+	})
+	if !ok {
+		return false
+	}
+	return listener.ProductOutdated()
+}
+
+// Mode возвращает режим последнего запущенного процессора
+func (r *Registry) Mode() models.ProcessorMode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mode == "" {
+		return r.defaultMode
+	}
+	return r.mode
+}
+
+// Subscribe возвращает канал с изменениями состояния текущего процессора и функцию отписки
+func (r *Registry) Subscribe() (<-chan models.StateChange, func()) {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+
+	id := r.nextID
+	r.nextID++
+
+	ch := make(chan models.StateChange, stateSubscriberBuffer)
+	r.subscribers[id] = ch
+
+	unsubscribe := func() {
+		r.subMu.Lock()
+		defer r.subMu.Unlock()
+
+		if ch, ok := r.subscribers[id]; ok {
+			delete(r.subscribers, id)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+func (r *Registry) currentProcessor() TaskProcessor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current
+}
+
+// resolveMode определяет режим работы по профилю продукта задания
+func (r *Registry) resolveMode(TaskID int) (models.ProcessorMode, error) {
+	task, err := r.dataService.GetTaskByID(TaskID)
+	if err != nil {
+		return "", err
+	}
+
+	product, err := r.dataService.GetProductByID(task.ProductID)
+	if err != nil {
+		return "", err
+	}
+
+	// Продукт без данных этикетки работает в режиме линии
+	if product.LabelData == "" {
+		return r.defaultMode, nil
+	}
+
+	labelData, err := models.ParseLabelData(product)
+	if err != nil {
+		return "", err
+	}
+
+	mode, err := models.ParseProcessorMode(labelData.Mode)
+	if err != nil {
+		return "", err
+	}
+	if mode == "" {
+		return r.defaultMode, nil
+	}
+
+	return mode, nil
+}
+
+// processor возвращает процессор режима, создавая его при первом обращении
+func (r *Registry) processor(mode models.ProcessorMode) (TaskProcessor, error) {
+	if processor, ok := r.processors[mode]; ok {
+		return processor, nil
+	}
+
+	factory, ok := r.factories[mode]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrModeNotSupported, mode.Title())
+	}
+
+	processor, err := factory()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания процессора для режима %s: %w", mode.Title(), err)
+	}
+
+	r.processors[mode] = processor
+
+	// Подписываемся до запуска, чтобы не потерять первый переход
+	changes, _ := processor.Subscribe()
+	go r.forward(processor, changes)
+
+	return processor, nil
+}
+
+// forward пересылает подписчикам изменения состояния процессора, пока он выбран текущим
+func (r *Registry) forward(processor TaskProcessor, changes <-chan models.StateChange) {
+	for change := range changes {
+		if r.currentProcessor() != processor {
+			continue
+		}
+
+		r.subMu.Lock()
+		for _, ch := range r.subscribers {
+			select {
+			case ch <- change:
+			default:
+				// Подписчик не успевает читать, пропускаем событие
+			}
+		}
+		r.subMu.Unlock()
+	}
+}
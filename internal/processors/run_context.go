@@ -0,0 +1,34 @@
+package processors
+
+import (
+	"context"
+	"sync"
+)
+
+// runContext - контекст работы процессора, который отменяется без блокировки процессора.
+// Остановка прерывает ожидание проверки этикетки, пока закрывающий короб вызов держит блокировку
+type runContext struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// start создает контекст нового запуска
+func (r *runContext) start() context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	return ctx
+}
+
+// stop отменяет контекст текущего запуска, повторный вызов ничего не делает
+func (r *runContext) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+}
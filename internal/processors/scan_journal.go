@@ -0,0 +1,104 @@
+package processors
+
+import (
+	"fmt"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"sync"
+)
+
+// scanJournal записывает срабатывания в журнал и ведет счетчики по заданию
+type scanJournal struct {
+	mu                  sync.Mutex
+	taskID              int
+	counters            models.ScanCounters
+	scanEventRepository *repository.ScanEventRepository
+}
+
+func newScanJournal() *scanJournal {
+	return &scanJournal{
+		scanEventRepository: repository.NewScanEventRepository(),
+		counters:            models.ScanCounters{ByReason: map[string]int{}},
+	}
+}
+
+// Initialize загружает счетчики задания из журнала
+func (j *scanJournal) Initialize(taskID int) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	accepted, err := j.scanEventRepository.CountAcceptedScanEvents(taskID)
+	if err != nil {
+		return fmt.Errorf("ошибка при загрузке счетчиков сканирования: %w", err)
+	}
+
+	byReason, err := j.scanEventRepository.CountScanEventsByReason(taskID)
+	if err != nil {
+		return fmt.Errorf("ошибка при загрузке счетчиков сканирования: %w", err)
+	}
+
+	rejected := 0
+	for _, count := range byReason {
+		rejected += count
+	}
+
+	j.taskID = taskID
+	j.counters = models.ScanCounters{
+		Total:    accepted + rejected,
+		Accepted: accepted,
+		Rejected: rejected,
+		ByReason: byReason,
+	}
+
+	return nil
+}
+
+// Accept записывает в журнал успешно обработанное срабатывание
+func (j *scanJournal) Accept(raw string, codes []string, message string) {
+	j.record(raw, codes, models.ScanOutcomeAccepted, "", message)
+}
+
+// Reject записывает в журнал отклоненное срабатывание с кодом причины
+func (j *scanJournal) Reject(raw string, codes []string, reason, message string) {
+	fmt.Printf("Срабатывание отклонено (%s): %s\n", reason, message)
+	j.record(raw, codes, models.ScanOutcomeRejected, reason, message)
+}
+
+// Counters возвращает копию счетчиков
+func (j *scanJournal) Counters() models.ScanCounters {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	result := j.counters
+	result.ByReason = make(map[string]int, len(j.counters.ByReason))
+	for reason, count := range j.counters.ByReason {
+		result.ByReason[reason] = count
+	}
+
+	return result
+}
+
+func (j *scanJournal) record(raw string, codes []string, outcome, reason, message string) {
+	j.mu.Lock()
+	j.counters.Total++
+	if outcome == models.ScanOutcomeAccepted {
+		j.counters.Accepted++
+	} else {
+		j.counters.Rejected++
+		j.counters.ByReason[reason]++
+	}
+	taskID := j.taskID
+	j.mu.Unlock()
+
+	_, err := j.scanEventRepository.CreateScanEvent(models.ScanEvent{
+		TaskID:      taskID,
+		RawResponse: raw,
+		Codes:       codes,
+		Outcome:     outcome,
+		Reason:      reason,
+		Message:     message,
+	})
+	if err != nil {
+		fmt.Printf("Ошибка записи в журнал сканирования: %v\n", err)
+	}
+}
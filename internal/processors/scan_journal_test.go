@@ -0,0 +1,119 @@
+package processors
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/models"
+)
+
+// TestMain переходит в корень репозитория: миграции базы ищутся относительно рабочего каталога
+func TestMain(m *testing.M) {
+	if err := os.Chdir(filepath.Join("..", "..")); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// setupDB создает пустую базу во временном каталоге. Репозитории запоминают соединение
+// при создании, поэтому процессоры и их части создаются после setupDB
+func setupDB(t *testing.T) {
+	t.Helper()
+
+	if err := database.Connect(filepath.Join(t.TempDir(), "ezline.db")); err != nil {
+		t.Fatalf("database.Connect: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+}
+
+// journalEntry - срабатывание для записи в журнал: пустая причина - принято
+type journalEntry struct {
+	reason string
+}
+
+func writeJournal(journal *scanJournal, entries []journalEntry) {
+	for _, entry := range entries {
+		if entry.reason == "" {
+			journal.Accept("raw", []string{"code"}, "принято")
+		} else {
+			journal.Reject("raw", []string{"code"}, entry.reason, "отклонено")
+		}
+	}
+}
+
+func TestScanJournalCounters(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []journalEntry
+		want    models.ScanCounters
+	}{
+		{"пустой журнал", nil, models.ScanCounters{ByReason: map[string]int{}}},
+		{"принятые и отклоненные", []journalEntry{
+			{reason: ""},
+			{reason: models.ScanReasonNoRead},
+			{reason: ""},
+			{reason: models.ScanReasonNoRead},
+			{reason: models.ScanReasonNotUnique},
+		}, models.ScanCounters{Total: 5, Accepted: 2, Rejected: 3, ByReason: map[string]int{
+			models.ScanReasonNoRead:    2,
+			models.ScanReasonNotUnique: 1,
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+
+			journal := newScanJournal()
+			if err := journal.Initialize(1); err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+			writeJournal(journal, tt.entries)
+			assertCounters(t, "после записи", journal.Counters(), tt.want)
+
+			// После перезапуска счетчики загружаются из журнала задания
+			restarted := newScanJournal()
+			if err := restarted.Initialize(1); err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+			assertCounters(t, "после перезапуска", restarted.Counters(), tt.want)
+		})
+	}
+}
+
+func TestScanJournalCountersArePerTask(t *testing.T) {
+	setupDB(t)
+
+	journal := newScanJournal()
+	if err := journal.Initialize(1); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	writeJournal(journal, []journalEntry{{reason: models.ScanReasonNoRead}, {reason: ""}})
+
+	if err := journal.Initialize(2); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	assertCounters(t, "задание 2", journal.Counters(), models.ScanCounters{ByReason: map[string]int{}})
+
+	counters := journal.Counters()
+	counters.ByReason[models.ScanReasonNoRead] = 10
+	if journal.Counters().ByReason[models.ScanReasonNoRead] != 0 {
+		t.Error("Counters возвращает общую с журналом карту причин")
+	}
+}
+
+func assertCounters(t *testing.T, stage string, got, want models.ScanCounters) {
+	t.Helper()
+
+	if got.Total != want.Total || got.Accepted != want.Accepted || got.Rejected != want.Rejected || len(got.ByReason) != len(want.ByReason) {
+		t.Fatalf("%s: счетчики = %+v, ожидалось %+v", stage, got, want)
+	}
+	for reason, count := range want.ByReason {
+		if got.ByReason[reason] != count {
+			t.Errorf("%s: причина %s = %d, ожидалось %d", stage, reason, got.ByReason[reason], count)
+		}
+	}
+}
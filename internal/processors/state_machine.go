@@ -0,0 +1,145 @@
+package processors
+
+import (
+	"fmt"
+	"github.com/ze674/EZLine/internal/models"
+	"sync"
+	"time"
+)
+
+// Размер истории переходов и буфера канала подписчика
+const (
+	stateHistorySize      = 50
+	stateSubscriberBuffer = 16
+)
+
+// allowedTransitions описывает допустимые переходы между состояниями процессора
+var allowedTransitions = map[models.ProcessorState][]models.ProcessorState{
+	models.ProcessorStateIdle:     {models.ProcessorStateStarting},
+	models.ProcessorStateStarting: {models.ProcessorStateRunning, models.ProcessorStateFaulted},
+	models.ProcessorStateRunning:  {models.ProcessorStatePaused, models.ProcessorStateFaulted, models.ProcessorStateStopping},
+	models.ProcessorStatePaused:   {models.ProcessorStateRunning, models.ProcessorStateFaulted, models.ProcessorStateStopping},
+	models.ProcessorStateFaulted:  {models.ProcessorStateStarting, models.ProcessorStateStopping},
+	models.ProcessorStateStopping: {models.ProcessorStateIdle, models.ProcessorStateFaulted},
+}
+
+// stateMachine хранит состояние процессора, историю переходов и рассылает изменения подписчикам
+type stateMachine struct {
+	mu          sync.Mutex
+	current     models.StateChange
+	history     []models.StateChange
+	subscribers map[int]chan models.StateChange
+	nextID      int
+}
+
+func newStateMachine() *stateMachine {
+	return &stateMachine{
+		current:     models.StateChange{State: models.ProcessorStateIdle, At: time.Now()},
+		subscribers: make(map[int]chan models.StateChange),
+	}
+}
+
+// Transition переводит процессор в новое состояние с указанием причины
+func (m *stateMachine) Transition(to models.ProcessorState, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from := m.current.State
+	if !isTransitionAllowed(from, to) {
+		return fmt.Errorf("недопустимый переход состояния процессора: %s -> %s", from, to)
+	}
+
+	change := models.StateChange{
+		State:    to,
+		Previous: from,
+		Reason:   reason,
+		At:       time.Now(),
+	}
+
+	m.current = change
+	m.history = append(m.history, change)
+	if len(m.history) > stateHistorySize {
+		m.history = m.history[len(m.history)-stateHistorySize:]
+	}
+
+	for _, ch := range m.subscribers {
+		select {
+		case ch <- change:
+		default:
+			// Подписчик не успевает читать, пропускаем событие
+		}
+	}
+
+	return nil
+}
+
+// Fault переводит процессор в аварию, если это допустимо из текущего состояния
+func (m *stateMachine) Fault(err error) {
+	if transitionErr := m.Transition(models.ProcessorStateFaulted, err.Error()); transitionErr != nil {
+		fmt.Printf("Ошибка процессора: %v (%v)\n", err, transitionErr)
+	}
+}
+
+// Current возвращает текущее состояние
+func (m *stateMachine) Current() models.StateChange {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current
+}
+
+// Is проверяет, находится ли процессор в одном из указанных состояний
+func (m *stateMachine) Is(states ...models.ProcessorState) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, state := range states {
+		if m.current.State == state {
+			return true
+		}
+	}
+	return false
+}
+
+// History возвращает последние переходы состояния
+func (m *stateMachine) History() []models.StateChange {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]models.StateChange, len(m.history))
+	copy(result, m.history)
+	return result
+}
+
+// Subscribe возвращает канал с изменениями состояния и функцию отписки
+func (m *stateMachine) Subscribe() (<-chan models.StateChange, func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextID
+	m.nextID++
+
+	ch := make(chan models.StateChange, stateSubscriberBuffer)
+	m.subscribers[id] = ch
+
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if ch, ok := m.subscribers[id]; ok {
+			delete(m.subscribers, id)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+func isTransitionAllowed(from, to models.ProcessorState) bool {
+	for _, allowed := range allowedTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
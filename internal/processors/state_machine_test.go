@@ -0,0 +1,74 @@
+package processors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ze674/EZLine/internal/models"
+)
+
+func TestStateMachineTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []models.ProcessorState
+		allowed bool
+	}{
+		{"запуск и остановка", []models.ProcessorState{
+			models.ProcessorStateStarting, models.ProcessorStateRunning, models.ProcessorStateStopping, models.ProcessorStateIdle,
+		}, true},
+		{"приостановка и возобновление", []models.ProcessorState{
+			models.ProcessorStateStarting, models.ProcessorStateRunning, models.ProcessorStatePaused, models.ProcessorStateRunning,
+		}, true},
+		{"остановка приостановленного", []models.ProcessorState{
+			models.ProcessorStateStarting, models.ProcessorStateRunning, models.ProcessorStatePaused, models.ProcessorStateStopping, models.ProcessorStateIdle,
+		}, true},
+		{"авария при возобновлении", []models.ProcessorState{
+			models.ProcessorStateStarting, models.ProcessorStateRunning, models.ProcessorStatePaused, models.ProcessorStateFaulted,
+		}, true},
+		{"приостановка без запуска", []models.ProcessorState{models.ProcessorStatePaused}, false},
+		{"приостановка при запуске", []models.ProcessorState{models.ProcessorStateStarting, models.ProcessorStatePaused}, false},
+		{"работа без запуска", []models.ProcessorState{models.ProcessorStateRunning}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := newStateMachine()
+
+			var err error
+			for _, state := range tt.path {
+				if err = machine.Transition(state, ""); err != nil {
+					break
+				}
+			}
+
+			if tt.allowed && err != nil {
+				t.Errorf("переход отклонен: %v", err)
+			}
+			if !tt.allowed && err == nil {
+				t.Errorf("недопустимый переход принят, состояние %s", machine.Current().State)
+			}
+		})
+	}
+}
+
+func TestStateMachinePausedIsNotActive(t *testing.T) {
+	machine := newStateMachine()
+	for _, state := range []models.ProcessorState{models.ProcessorStateStarting, models.ProcessorStateRunning, models.ProcessorStatePaused} {
+		if err := machine.Transition(state, "задание 1 приостановлено"); err != nil {
+			t.Fatalf("Transition(%s): %v", state, err)
+		}
+	}
+
+	current := machine.Current()
+	if current.IsActive() {
+		t.Error("приостановленный процессор считается работающим с оборудованием")
+	}
+	if current.Previous != models.ProcessorStateRunning || current.Title() != "Приостановлено" {
+		t.Errorf("состояние = %+v", current)
+	}
+
+	machine.Fault(errors.New("нет связи с ПЛК"))
+	if !machine.Current().IsFaulted() {
+		t.Error("приостановленный процессор не перешел в аварию")
+	}
+}
@@ -3,6 +3,7 @@ package processors
 import (
 	"context"
 	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/validator"
 )
 
 // TaskProcessor определяет общий интерфейс для обработки заданий
@@ -13,13 +14,26 @@ type TaskProcessor interface {
 	// Stop останавливает процессор
 	Stop() error
 
+	// Pause приостанавливает процессор вместе с заданием
+	Pause(reason string) error
+
 	// IsRunning возвращает состояние процессора
 	IsRunning() bool
+
+	// Counters возвращает счетчики срабатываний по текущему заданию
+	Counters() models.ScanCounters
+
+	// State возвращает текущее состояние процессора и причину последнего перехода
+	State() models.StateChange
+
+	// Subscribe возвращает канал с изменениями состояния и функцию отписки
+	Subscribe() (<-chan models.StateChange, func())
 }
 
 type DataService interface {
 	GetTaskByID(taskID int) (models.Task, error)
 	GetProductByID(productID int) (models.Product, error)
+	GetCodePool(taskID int) (*validator.CodePool, error) // Коды, выданные для задания, nil - без проверки пула
 }
 
 type CodeReader interface {
@@ -28,6 +42,11 @@ type CodeReader interface {
 	Close() error
 }
 
+// HealthReporter реализуется оборудованием, которое запоминает результат последнего обмена
+type HealthReporter interface {
+	Health() models.DeviceHealth
+}
+
 type Printer interface {
 	Print(data string) error
 	Connect() error
@@ -4,6 +4,7 @@ package repository
 import (
 	"database/sql"
 	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/models"
 )
 
 // ActiveTaskRepository предоставляет методы для работы с активным заданием
@@ -18,8 +19,8 @@ func NewActiveTaskRepository() *ActiveTaskRepository {
 	}
 }
 
-// SaveActiveTask сохраняет ID активного задания в базе данных
-func (r *ActiveTaskRepository) SaveActiveTask(taskID int) error {
+// SaveActiveTask сохраняет ID активного задания и его план в базе данных
+func (r *ActiveTaskRepository) SaveActiveTask(taskID int, plan models.TaskPlan) error {
 	// Сначала удаляем все существующие активные задания
 	_, err := r.db.Exec("DELETE FROM active_task")
 	if err != nil {
@@ -28,8 +29,8 @@ func (r *ActiveTaskRepository) SaveActiveTask(taskID int) error {
 
 	// Добавляем новое активное задание
 	_, err = r.db.Exec(
-		"INSERT INTO active_task (task_id) VALUES (?)",
-		taskID)
+		"INSERT INTO active_task (task_id, planned_quantity, planned_boxes) VALUES (?, ?, ?)",
+		taskID, plan.Quantity, plan.Boxes)
 
 	return err
 }
@@ -48,8 +49,60 @@ func (r *ActiveTaskRepository) GetActiveTask() (int, error) {
 	return taskID, err
 }
 
+// UpdatePlan обновляет план активного задания, например после изменения задания в EZFactory
+func (r *ActiveTaskRepository) UpdatePlan(taskID int, plan models.TaskPlan) error {
+	_, err := r.db.Exec(
+		"UPDATE active_task SET planned_quantity = ?, planned_boxes = ? WHERE task_id = ?",
+		plan.Quantity, plan.Boxes, taskID)
+	return err
+}
+
+// GetPlan возвращает сохраненный план активного задания
+func (r *ActiveTaskRepository) GetPlan(taskID int) (models.TaskPlan, error) {
+	var plan models.TaskPlan
+
+	err := r.db.QueryRow(
+		"SELECT planned_quantity, planned_boxes FROM active_task WHERE task_id = ? ORDER BY created_at DESC LIMIT 1",
+		taskID).Scan(&plan.Quantity, &plan.Boxes)
+
+	if err == sql.ErrNoRows {
+		return plan, nil // Задание не активно, плана нет
+	}
+
+	return plan, err
+}
+
 // ClearActiveTask удаляет активное задание из базы данных
 func (r *ActiveTaskRepository) ClearActiveTask() error {
 	_, err := r.db.Exec("DELETE FROM active_task")
 	return err
 }
+
+// ReleaseActiveTask очищает активное задание и в той же транзакции ставит в очередь сообщения для EZFactory.
+// Если задан codeKey, в той же транзакции коды маркировки задания распределяются по статусам (см. finalizeCodesTx).
+// Либо задание освобождается и EZFactory об этом узнает, либо не меняется ничего
+func (r *ActiveTaskRepository) ReleaseActiveTask(taskID int, codeKey func(code string) string, messages []models.OutboxMessage) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if codeKey != nil {
+		if err := finalizeCodesTx(tx, taskID, codeKey); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM active_task"); err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if _, err := enqueueOutboxTx(tx, message); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -3,17 +3,34 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/markingcode"
 	"github.com/ze674/EZLine/internal/models"
+	"strings"
 	"time"
 )
 
 const (
-	StatusCreated = "created"
+	StatusCreated   = "created"
+	StatusModified  = "modified"  // Состав изменен после закрытия
+	StatusDisbanded = "disbanded" // Контейнер расформирован
 )
 
+// Результат проверки напечатанной этикетки короба
+const (
+	LabelStatusVerified   = "verified"   // Этикетка прочитана и совпала с данными короба
+	LabelStatusUnverified = "unverified" // Этикетка не прочитана или не совпала
+)
+
+// ErrTaskActive возвращается при импорте в задание, выбранное на линии:
+// ее процессоры выдают серийные номера коробов из памяти и повторили бы импортированные
+var ErrTaskActive = errors.New("задание выбрано на линии")
+
 type ContainerRepository struct {
-	db *sql.DB
+	db   *sql.DB
+	sscc markingcode.SSCCPrefix
 }
 
 func NewContainerRepository() *ContainerRepository {
@@ -22,6 +39,12 @@ func NewContainerRepository() *ContainerRepository {
 	}
 }
 
+// UseSSCC задает префикс SSCC линии. Коробам, созданным после этого, SSCC присваивается
+// по ID контейнера в той же транзакции. Без префикса короба сохраняются без SSCC
+func (r *ContainerRepository) UseSSCC(prefix markingcode.SSCCPrefix) {
+	r.sscc = prefix
+}
+
 // Создание контейнера с числовым серийным номером
 func (r *ContainerRepository) CreateContainer(code string, serialNumber int, taskID int, status string) (int64, error) {
 	result, err := r.db.Exec(
@@ -34,6 +57,144 @@ func (r *ContainerRepository) CreateContainer(code string, serialNumber int, tas
 	return result.LastInsertId()
 }
 
+// CreateContainerWithItems создает контейнер и привязанные к нему товары в одной транзакции.
+// Несобранный короб места сборки station очищается в той же транзакции
+func (r *ContainerRepository) CreateContainerWithItems(code string, serialNumber int, taskID int, station string, itemCodes []string) (int64, error) {
+	return r.createContainerWithItems(code, serialNumber, taskID, station, itemCodes, "")
+}
+
+// CreatePartialContainerWithItems создает короб, закрытый оператором до заполнения.
+// Закрытие записывается в журнал изменений контейнера с указанной причиной
+func (r *ContainerRepository) CreatePartialContainerWithItems(code string, serialNumber int, taskID int, station string, itemCodes []string, reason string) (int64, error) {
+	return r.createContainerWithItems(code, serialNumber, taskID, station, itemCodes, reason)
+}
+
+func (r *ContainerRepository) createContainerWithItems(code string, serialNumber int, taskID int, station string, itemCodes []string, partialReason string) (int64, error) {
+	partial := partialReason != ""
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO containers (code, serial_number, task_id, status, partial) VALUES (?, ?, ?, ?, ?)",
+		code, serialNumber, taskID, StatusCreated, partial)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка создания контейнера: %w", err)
+	}
+
+	containerID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.assignSSCCTx(tx, containerID); err != nil {
+		return 0, err
+	}
+
+	for _, itemCode := range itemCodes {
+		_, err := tx.Exec(
+			"INSERT INTO items (code, task_id, container_id, status) VALUES (?, ?, ?, ?)",
+			itemCode, taskID, containerID, StatusAggregated)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка создания товара %s: %w", itemCode, err)
+		}
+	}
+
+	// Коды короба больше не считаются несобранными
+	if err := clearPendingBoxTx(tx, taskID, station); err != nil {
+		return 0, err
+	}
+
+	if partial {
+		event := models.ContainerEvent{
+			ContainerID: containerID,
+			TaskID:      taskID,
+			Action:      models.ContainerActionClosePartial,
+			Reason:      partialReason,
+		}
+		if err := insertContainerEventTx(tx, event); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return containerID, nil
+}
+
+// ImportLegacyBoxes сохраняет короба из журнала агрегации старого формата одной транзакцией.
+// Короба получают следующие за последним серийные номера задания и время закрытия из журнала.
+// Импортированная история считается уже переданной, поэтому отмечается выгруженной в EZFactory.
+// В задание, выбранное на линии, импорт не выполняется и возвращается ErrTaskActive
+func (r *ContainerRepository) ImportLegacyBoxes(taskID int, boxes []models.LegacyBox) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Проверка и серийные номера в одной транзакции с записью: выбор задания на линии
+	// между ними не пройдет незамеченным
+	var active int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM active_task WHERE task_id = ?", taskID).Scan(&active); err != nil {
+		return err
+	}
+	if active > 0 {
+		return ErrTaskActive
+	}
+
+	var lastSerial int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(serial_number), 0) FROM containers WHERE task_id = ?", taskID).Scan(&lastSerial); err != nil {
+		return err
+	}
+	firstSerial := lastSerial + 1
+
+	now := time.Now()
+	for i, box := range boxes {
+		result, err := tx.Exec(
+			"INSERT INTO containers (code, serial_number, task_id, status, created_at, updated_at, sent_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			box.Code, firstSerial+i, taskID, StatusCreated, box.ClosedAt, box.ClosedAt, now)
+		if err != nil {
+			return fmt.Errorf("ошибка создания контейнера %s: %w", box.Code, err)
+		}
+
+		containerID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if err := r.assignSSCCTx(tx, containerID); err != nil {
+			return err
+		}
+
+		for _, item := range box.Items {
+			_, err := tx.Exec(
+				"INSERT INTO items (code, task_id, container_id, status, created_at) VALUES (?, ?, ?, ?, ?)",
+				item.Code, taskID, containerID, StatusAggregated, box.ClosedAt)
+			if err != nil {
+				return fmt.Errorf("ошибка создания товара %s: %w", item.Code, err)
+			}
+		}
+
+		event := models.ContainerEvent{
+			ContainerID: containerID,
+			TaskID:      taskID,
+			Action:      models.ContainerActionImport,
+			Reason:      fmt.Sprintf("%s, строка %d", box.File, box.Line),
+		}
+		if err := insertContainerEventTx(tx, event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 // Получение последнего серийного номера для задания
 func (r *ContainerRepository) GetLastSerialNumber(taskID int) (int, error) {
 	var serialNumber int
@@ -87,10 +248,365 @@ func (r *ContainerRepository) GetContainersByTaskID(taskID int) ([]models.Contai
 	return containers, nil
 }
 
+// CountByTaskID возвращает количество коробов задания без учета расформированных
+func (r *ContainerRepository) CountByTaskID(taskID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(
+		"SELECT COUNT(*) FROM containers WHERE task_id = ? AND status != ?",
+		taskID, StatusDisbanded).Scan(&count)
+	return count, err
+}
+
+// GetUnsentAggregation возвращает не выгруженные в EZFactory контейнеры задания вместе с кодами товаров
+func (r *ContainerRepository) GetUnsentAggregation(taskID int, limit int) ([]models.AggregationContainer, error) {
+	return r.queryAggregation(
+		"SELECT id, code, COALESCE(sscc, ''), serial_number, status, partial FROM containers WHERE task_id = ? AND sent_at IS NULL ORDER BY id LIMIT ?",
+		taskID, limit)
+}
+
+// GetAggregation возвращает все короба задания, кроме расформированных, вместе с кодами товаров
+func (r *ContainerRepository) GetAggregation(taskID int) ([]models.AggregationContainer, error) {
+	return r.queryAggregation(
+		"SELECT id, code, COALESCE(sscc, ''), serial_number, status, partial FROM containers WHERE task_id = ? AND status != ? ORDER BY id",
+		taskID, StatusDisbanded)
+}
+
+// queryAggregation выбирает контейнеры запросом и загружает коды их товаров
+func (r *ContainerRepository) queryAggregation(query string, args ...any) ([]models.AggregationContainer, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var containers []models.AggregationContainer
+	index := make(map[int64]int)
+
+	for rows.Next() {
+		var container models.AggregationContainer
+		if err := rows.Scan(&container.ID, &container.Code, &container.SSCC, &container.SerialNumber, &container.Status, &container.Partial); err != nil {
+			return nil, err
+		}
+		container.Items = []string{}
+		index[container.ID] = len(containers)
+		containers = append(containers, container)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(containers) == 0 {
+		return nil, nil
+	}
+
+	// Загружаем коды товаров всех выбранных контейнеров одним запросом
+	placeholders := make([]string, len(containers))
+	ids := make([]any, len(containers))
+	for i, container := range containers {
+		placeholders[i] = "?"
+		ids[i] = container.ID
+	}
+
+	itemRows, err := r.db.Query(
+		"SELECT container_id, code FROM items WHERE container_id IN ("+strings.Join(placeholders, ", ")+") AND status != ? ORDER BY id",
+		append(ids, StatusRemoved)...)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var containerID int64
+		var code string
+		if err := itemRows.Scan(&containerID, &code); err != nil {
+			return nil, err
+		}
+		i := index[containerID]
+		containers[i].Items = append(containers[i].Items, code)
+	}
+
+	return containers, itemRows.Err()
+}
+
+// MarkSent отмечает контейнеры выгруженными в EZFactory
+func (r *ContainerRepository) MarkSent(ids []int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, id := range ids {
+		if _, err := tx.Exec("UPDATE containers SET sent_at = ? WHERE id = ?", now, id); err != nil {
+			return fmt.Errorf("ошибка отметки выгрузки контейнера: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 // UpdateContainerStatus обновляет статус контейнера
 func (r *ContainerRepository) UpdateContainerStatus(id int64, status string) error {
 	_, err := r.db.Exec(
-		"UPDATE containers SET status = ?, updated_at = ? WHERE id = ?",
+		"UPDATE containers SET status = ?, updated_at = ?, sent_at = NULL WHERE id = ?",
 		status, time.Now(), id)
 	return err
 }
+
+// GetContainerByID возвращает контейнер по ID вместе с количеством товаров
+func (r *ContainerRepository) GetContainerByID(id int64) (*models.Container, error) {
+	var container models.Container
+
+	err := r.db.QueryRow(
+		`SELECT c.id, c.code, COALESCE(c.sscc, ''), c.serial_number, c.task_id, c.status, c.partial, c.label_status, c.created_at,
+		        (SELECT COUNT(*) FROM items i WHERE i.container_id = c.id AND i.status != ?)
+		 FROM containers c WHERE c.id = ?`,
+		StatusRemoved, id).Scan(&container.ID, &container.Code, &container.SSCC, &container.SerialNumber, &container.TaskID, &container.Status, &container.Partial, &container.LabelStatus,
+		&container.CreatedAt, &container.ItemsCount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &container, nil
+}
+
+// GetContainerSummariesByTaskID возвращает контейнеры задания вместе с количеством товаров
+func (r *ContainerRepository) GetContainerSummariesByTaskID(taskID int) ([]models.Container, error) {
+	rows, err := r.db.Query(
+		`SELECT c.id, c.code, COALESCE(c.sscc, ''), c.serial_number, c.task_id, c.status, c.partial, c.label_status, c.created_at, c.sent_at, COUNT(i.id)
+		 FROM containers c LEFT JOIN items i ON i.container_id = c.id AND i.status != ?
+		 WHERE c.task_id = ?
+		 GROUP BY c.id
+		 ORDER BY c.serial_number DESC`,
+		StatusRemoved, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var containers []models.Container
+
+	for rows.Next() {
+		var container models.Container
+		if err := rows.Scan(&container.ID, &container.Code, &container.SSCC, &container.SerialNumber, &container.TaskID, &container.Status,
+			&container.Partial, &container.LabelStatus, &container.CreatedAt, &container.SentAt, &container.ItemsCount); err != nil {
+			return nil, err
+		}
+		containers = append(containers, container)
+	}
+
+	return containers, nil
+}
+
+// RemoveItem убирает товар из контейнера. Запись остается со статусом removed, а код можно агрегировать повторно.
+// Если в контейнере не осталось товаров, он расформировывается
+func (r *ContainerRepository) RemoveItem(container *models.Container, item *models.Item, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE items SET status = ? WHERE id = ? AND container_id = ?", StatusRemoved, item.ID, container.ID); err != nil {
+		return fmt.Errorf("ошибка удаления товара: %w", err)
+	}
+
+	status := StatusModified
+	if container.ItemsCount <= 1 {
+		status = StatusDisbanded
+	}
+
+	if err := updateContainerStatusTx(tx, container.ID, status); err != nil {
+		return err
+	}
+
+	event := models.ContainerEvent{ContainerID: container.ID, TaskID: container.TaskID, Action: models.ContainerActionRemoveItem, ItemCode: item.Code, Reason: reason}
+	if err := insertContainerEventTx(tx, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceItem заменяет товар в контейнере новым кодом
+func (r *ContainerRepository) ReplaceItem(container *models.Container, item *models.Item, newCode string, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE items SET status = ? WHERE id = ? AND container_id = ?", StatusRemoved, item.ID, container.ID); err != nil {
+		return fmt.Errorf("ошибка удаления товара: %w", err)
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO items (code, task_id, container_id, status) VALUES (?, ?, ?, ?)",
+		newCode, container.TaskID, container.ID, StatusAggregated); err != nil {
+		return fmt.Errorf("ошибка создания товара %s: %w", newCode, err)
+	}
+
+	if err := updateContainerStatusTx(tx, container.ID, StatusModified); err != nil {
+		return err
+	}
+
+	event := models.ContainerEvent{ContainerID: container.ID, TaskID: container.TaskID, Action: models.ContainerActionReplaceItem, ItemCode: item.Code, NewItemCode: newCode, Reason: reason}
+	if err := insertContainerEventTx(tx, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddItems добавляет товары в контейнер
+func (r *ContainerRepository) AddItems(container *models.Container, codes []string, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, code := range codes {
+		if _, err := tx.Exec(
+			"INSERT INTO items (code, task_id, container_id, status) VALUES (?, ?, ?, ?)",
+			code, container.TaskID, container.ID, StatusAggregated); err != nil {
+			return fmt.Errorf("ошибка создания товара %s: %w", code, err)
+		}
+
+		event := models.ContainerEvent{ContainerID: container.ID, TaskID: container.TaskID, Action: models.ContainerActionAddItem, ItemCode: code, Reason: reason}
+		if err := insertContainerEventTx(tx, event); err != nil {
+			return err
+		}
+	}
+
+	if err := updateContainerStatusTx(tx, container.ID, StatusModified); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Disband расформировывает контейнер: все товары помечаются убранными, контейнер - расформированным
+func (r *ContainerRepository) Disband(container *models.Container, reason string) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT code FROM items WHERE container_id = ? AND status != ?", container.ID, StatusRemoved)
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	rows.Close()
+
+	if _, err := tx.Exec("UPDATE items SET status = ? WHERE container_id = ?", StatusRemoved, container.ID); err != nil {
+		return nil, fmt.Errorf("ошибка удаления товаров: %w", err)
+	}
+
+	if err := updateContainerStatusTx(tx, container.ID, StatusDisbanded); err != nil {
+		return nil, err
+	}
+
+	event := models.ContainerEvent{ContainerID: container.ID, TaskID: container.TaskID, Action: models.ContainerActionDisband, ItemCode: strings.Join(codes, " "), Reason: reason}
+	if err := insertContainerEventTx(tx, event); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// SetLabelStatus сохраняет результат проверки этикетки короба.
+// Непрошедшая проверка записывается в журнал изменений контейнера с причиной
+func (r *ContainerRepository) SetLabelStatus(container *models.Container, status string, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE containers SET label_status = ?, updated_at = ? WHERE id = ?",
+		status, time.Now(), container.ID); err != nil {
+		return fmt.Errorf("ошибка обновления статуса этикетки: %w", err)
+	}
+
+	if status == LabelStatusUnverified {
+		event := models.ContainerEvent{
+			ContainerID: container.ID,
+			TaskID:      container.TaskID,
+			Action:      models.ContainerActionLabelUnverified,
+			Reason:      reason,
+		}
+		if err := insertContainerEventTx(tx, event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ContainerSSCC возвращает SSCC, присвоенный контейнеру с этим ID при создании.
+// Пусто, если префикс не задан
+func (r *ContainerRepository) ContainerSSCC(containerID int64) string {
+	if r.sscc.IsZero() {
+		return ""
+	}
+	sscc, _ := r.sscc.SSCC(containerID)
+	return sscc
+}
+
+// assignSSCCTx присваивает контейнеру SSCC с номером, равным ID контейнера, если префикс задан
+func (r *ContainerRepository) assignSSCCTx(tx *sql.Tx, containerID int64) error {
+	if r.sscc.IsZero() {
+		return nil
+	}
+
+	sscc, err := r.sscc.SSCC(containerID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE containers SET sscc = ? WHERE id = ?", sscc, containerID); err != nil {
+		return fmt.Errorf("ошибка присвоения SSCC %s: %w", sscc, err)
+	}
+
+	return nil
+}
+
+// updateContainerStatusTx обновляет статус контейнера. Измененный контейнер выгружается в EZFactory повторно
+func updateContainerStatusTx(tx *sql.Tx, id int64, status string) error {
+	_, err := tx.Exec(
+		"UPDATE containers SET status = ?, updated_at = ?, sent_at = NULL WHERE id = ?",
+		status, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления статуса контейнера: %w", err)
+	}
+	return nil
+}
+
+func insertContainerEventTx(tx *sql.Tx, event models.ContainerEvent) error {
+	_, err := tx.Exec(
+		"INSERT INTO container_events (container_id, task_id, action, item_code, new_item_code, reason) VALUES (?, ?, ?, ?, ?, ?)",
+		event.ContainerID, event.TaskID, event.Action, event.ItemCode, event.NewItemCode, event.Reason)
+	if err != nil {
+		return fmt.Errorf("ошибка записи в журнал контейнера: %w", err)
+	}
+	return nil
+}
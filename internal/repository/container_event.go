@@ -0,0 +1,44 @@
+// internal/repository/container_event.go
+package repository
+
+import (
+	"database/sql"
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/models"
+)
+
+// ContainerEventRepository предоставляет методы для чтения журнала изменений контейнеров.
+// Записи журнала создаются в транзакциях ContainerRepository вместе с изменениями
+type ContainerEventRepository struct {
+	db *sql.DB
+}
+
+// NewContainerEventRepository создает новый репозиторий журнала изменений контейнеров
+func NewContainerEventRepository() *ContainerEventRepository {
+	return &ContainerEventRepository{
+		db: database.DB,
+	}
+}
+
+// GetEventsByContainerID возвращает журнал изменений контейнера
+func (r *ContainerEventRepository) GetEventsByContainerID(containerID int64) ([]models.ContainerEvent, error) {
+	rows, err := r.db.Query(
+		"SELECT id, container_id, task_id, action, item_code, new_item_code, reason, created_at FROM container_events WHERE container_id = ? ORDER BY id DESC",
+		containerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.ContainerEvent
+
+	for rows.Next() {
+		var event models.ContainerEvent
+		if err := rows.Scan(&event.ID, &event.ContainerID, &event.TaskID, &event.Action, &event.ItemCode, &event.NewItemCode, &event.Reason, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
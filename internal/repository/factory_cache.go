@@ -0,0 +1,179 @@
+// internal/repository/factory_cache.go
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/models"
+	"time"
+)
+
+// FactoryCacheRepository хранит последние полученные из EZFactory задания и продукты
+type FactoryCacheRepository struct {
+	db *sql.DB
+}
+
+// NewFactoryCacheRepository создает новый репозиторий кэша EZFactory
+func NewFactoryCacheRepository() *FactoryCacheRepository {
+	return &FactoryCacheRepository{
+		db: database.DB,
+	}
+}
+
+// SaveTaskList заменяет сохраненный список заданий линии
+func (r *FactoryCacheRepository) SaveTaskList(lineID int, tasks []models.Task) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM cached_tasks WHERE line_id = ?", lineID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, task := range tasks {
+		if err := saveTaskTx(tx, task, lineID, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SaveTask сохраняет одно задание
+func (r *FactoryCacheRepository) SaveTask(task models.Task) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveTaskTx(tx, task, task.LineID, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateTaskStatus меняет статус сохраненного задания, чтобы без связи список показывал статус, установленный линией
+func (r *FactoryCacheRepository) UpdateTaskStatus(taskID int, status string) error {
+	task, fetchedAt, err := r.GetTask(taskID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	task.Status = status
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec("UPDATE cached_tasks SET data = ?, fetched_at = ? WHERE id = ?", string(data), fetchedAt, taskID)
+	return err
+}
+
+// GetTaskList возвращает сохраненный список заданий линии и время самого старого из них
+func (r *FactoryCacheRepository) GetTaskList(lineID int) ([]models.Task, time.Time, error) {
+	rows, err := r.db.Query("SELECT data, fetched_at FROM cached_tasks WHERE line_id = ? ORDER BY id", lineID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	tasks := make([]models.Task, 0)
+	var oldest time.Time
+
+	for rows.Next() {
+		var data string
+		var fetchedAt time.Time
+		if err := rows.Scan(&data, &fetchedAt); err != nil {
+			return nil, time.Time{}, err
+		}
+
+		var task models.Task
+		if err := json.Unmarshal([]byte(data), &task); err != nil {
+			return nil, time.Time{}, fmt.Errorf("ошибка разбора сохраненного задания: %w", err)
+		}
+		tasks = append(tasks, task)
+
+		if oldest.IsZero() || fetchedAt.Before(oldest) {
+			oldest = fetchedAt
+		}
+	}
+
+	return tasks, oldest, rows.Err()
+}
+
+// GetTask возвращает сохраненное задание и время его получения.
+// Если задание не сохранялось, возвращается sql.ErrNoRows
+func (r *FactoryCacheRepository) GetTask(taskID int) (models.Task, time.Time, error) {
+	var task models.Task
+	var data string
+	var fetchedAt time.Time
+
+	err := r.db.QueryRow("SELECT data, fetched_at FROM cached_tasks WHERE id = ?", taskID).Scan(&data, &fetchedAt)
+	if err != nil {
+		return task, fetchedAt, err
+	}
+
+	if err := json.Unmarshal([]byte(data), &task); err != nil {
+		return task, fetchedAt, fmt.Errorf("ошибка разбора сохраненного задания: %w", err)
+	}
+
+	return task, fetchedAt, nil
+}
+
+// SaveProduct сохраняет карточку продукта вместе с данными этикетки
+func (r *FactoryCacheRepository) SaveProduct(product models.Product) error {
+	data, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		"INSERT INTO cached_products (id, data, fetched_at) VALUES (?, ?, ?) ON CONFLICT(id) DO UPDATE SET data = excluded.data, fetched_at = excluded.fetched_at",
+		product.ID, string(data), time.Now())
+	return err
+}
+
+// GetProduct возвращает сохраненную карточку продукта и время ее получения.
+// Если продукт не сохранялся, возвращается sql.ErrNoRows
+func (r *FactoryCacheRepository) GetProduct(productID int) (models.Product, time.Time, error) {
+	var product models.Product
+	var data string
+	var fetchedAt time.Time
+
+	err := r.db.QueryRow("SELECT data, fetched_at FROM cached_products WHERE id = ?", productID).Scan(&data, &fetchedAt)
+	if err != nil {
+		return product, fetchedAt, err
+	}
+
+	if err := json.Unmarshal([]byte(data), &product); err != nil {
+		return product, fetchedAt, fmt.Errorf("ошибка разбора сохраненного продукта: %w", err)
+	}
+
+	return product, fetchedAt, nil
+}
+
+func saveTaskTx(tx *sql.Tx, task models.Task, lineID int, fetchedAt time.Time) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO cached_tasks (id, line_id, data, fetched_at) VALUES (?, ?, ?, ?) ON CONFLICT(id) DO UPDATE SET line_id = excluded.line_id, data = excluded.data, fetched_at = excluded.fetched_at",
+		task.ID, lineID, string(data), fetchedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения задания %d: %w", task.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// internal/repository/heartbeat.go
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/models"
+)
+
+// HeartbeatRepository буферизует снимки состояния линии до их отправки в EZFactory
+type HeartbeatRepository struct {
+	db *sql.DB
+}
+
+// NewHeartbeatRepository создает новый репозиторий снимков состояния линии
+func NewHeartbeatRepository() *HeartbeatRepository {
+	return &HeartbeatRepository{
+		db: database.DB,
+	}
+}
+
+// Save добавляет снимок в буфер и удаляет самые старые снимки сверх limit
+func (r *HeartbeatRepository) Save(heartbeat models.LineHeartbeat, limit int) error {
+	data, err := json.Marshal(heartbeat)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT INTO heartbeats (data) VALUES (?)", string(data)); err != nil {
+		return err
+	}
+
+	if limit > 0 {
+		_, err := tx.Exec(
+			"DELETE FROM heartbeats WHERE id NOT IN (SELECT id FROM heartbeats ORDER BY id DESC LIMIT ?)",
+			limit)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetOldest возвращает самые старые снимки и ID последнего из них
+func (r *HeartbeatRepository) GetOldest(limit int) ([]models.LineHeartbeat, int64, error) {
+	rows, err := r.db.Query("SELECT id, data FROM heartbeats ORDER BY id LIMIT ?", limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var heartbeats []models.LineHeartbeat
+	var lastID int64
+
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&lastID, &data); err != nil {
+			return nil, 0, err
+		}
+
+		var heartbeat models.LineHeartbeat
+		if err := json.Unmarshal([]byte(data), &heartbeat); err != nil {
+			return nil, 0, err
+		}
+		heartbeats = append(heartbeats, heartbeat)
+	}
+
+	return heartbeats, lastID, rows.Err()
+}
+
+// DeleteUpTo удаляет отправленные снимки
+func (r *HeartbeatRepository) DeleteUpTo(id int64) error {
+	_, err := r.db.Exec("DELETE FROM heartbeats WHERE id <= ?", id)
+	return err
+}
+
+// Count возвращает количество снимков в буфере
+func (r *HeartbeatRepository) Count() (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM heartbeats").Scan(&count)
+	return count, err
+}
@@ -12,6 +12,10 @@ const (
 	StatusScanned    = "scanned"
 )
 
+// StatusRemoved - товар убран из короба при редактировании или расформировании.
+// Запись остается для истории, а код снова доступен для агрегации
+const StatusRemoved = "removed"
+
 type ItemRepository struct {
 	db *sql.DB
 }
@@ -52,11 +56,18 @@ func (r *ItemRepository) AssignItemToContainer(itemID, containerID int64) error
 	return err
 }
 
+// CountByTaskID возвращает количество товаров задания без убранных из коробов
+func (r *ItemRepository) CountByTaskID(taskID int) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM items WHERE task_id = ? AND status != ?", taskID, StatusRemoved).Scan(&count)
+	return count, err
+}
+
 // GetItemsByTaskID возвращает товары для задания
 func (r *ItemRepository) GetItemsByTaskID(taskID int) ([]models.Item, error) {
 	rows, err := r.db.Query(
-		"SELECT id, code, task_id, container_id, status, created_at FROM items WHERE task_id = ? ORDER BY created_at DESC",
-		taskID)
+		"SELECT id, code, task_id, container_id, status, created_at FROM items WHERE task_id = ? AND status != ? ORDER BY created_at DESC",
+		taskID, StatusRemoved)
 	if err != nil {
 		return nil, err
 	}
@@ -78,8 +89,8 @@ func (r *ItemRepository) GetItemsByTaskID(taskID int) ([]models.Item, error) {
 // GetItemsByContainerID возвращает товары для контейнера
 func (r *ItemRepository) GetItemsByContainerID(containerID int64) ([]models.Item, error) {
 	rows, err := r.db.Query(
-		"SELECT id, code, task_id, container_id, status, created_at FROM items WHERE container_id = ? ORDER BY created_at DESC",
-		containerID)
+		"SELECT id, code, task_id, container_id, status, created_at FROM items WHERE container_id = ? AND status != ? ORDER BY created_at DESC",
+		containerID, StatusRemoved)
 	if err != nil {
 		return nil, err
 	}
@@ -98,13 +109,13 @@ func (r *ItemRepository) GetItemsByContainerID(containerID int64) ([]models.Item
 	return items, nil
 }
 
-// GetItemByCode возвращает товар по коду
+// GetItemByCode возвращает действующий товар по коду
 func (r *ItemRepository) GetItemByCode(code string) (*models.Item, error) {
 	var item models.Item
 
 	err := r.db.QueryRow(
-		"SELECT id, code, task_id, container_id, status, created_at FROM items WHERE code = ?",
-		code).Scan(&item.ID, &item.Code, &item.TaskID, &item.ContainerID, &item.Status, &item.CreatedAt)
+		"SELECT id, code, task_id, container_id, status, created_at FROM items WHERE code = ? AND status != ?",
+		code, StatusRemoved).Scan(&item.ID, &item.Code, &item.TaskID, &item.ContainerID, &item.Status, &item.CreatedAt)
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,236 @@
+// internal/repository/marking_code.go
+package repository
+
+import (
+	"database/sql"
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/models"
+	"time"
+)
+
+// MarkingCodeRepository хранит коды маркировки, загруженные из EZFactory, и ход их загрузки
+type MarkingCodeRepository struct {
+	db *sql.DB
+}
+
+// NewMarkingCodeRepository создает новый репозиторий кодов маркировки
+func NewMarkingCodeRepository() *MarkingCodeRepository {
+	return &MarkingCodeRepository{
+		db: database.DB,
+	}
+}
+
+// GetDownload возвращает ход загрузки кодов задания.
+// Если загрузка не начиналась, возвращается sql.ErrNoRows
+func (r *MarkingCodeRepository) GetDownload(taskID int) (models.CodeDownload, error) {
+	download := models.CodeDownload{TaskID: taskID}
+
+	err := r.db.QueryRow(
+		"SELECT cursor, downloaded, completed, error, updated_at FROM code_downloads WHERE task_id = ?",
+		taskID).Scan(&download.Cursor, &download.Downloaded, &download.Completed, &download.Error, &download.UpdatedAt)
+
+	return download, err
+}
+
+// SavePage сохраняет страницу кодов вместе с курсором следующей страницы в одной транзакции,
+// поэтому после сбоя загрузка продолжается с первой несохраненной страницы
+func (r *MarkingCodeRepository) SavePage(taskID int, page models.CodePage) (models.CodeDownload, error) {
+	download := models.CodeDownload{TaskID: taskID}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return download, err
+	}
+	defer tx.Rollback()
+
+	for _, code := range page.Codes {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO marking_codes (code, task_id) VALUES (?, ?)", code, taskID); err != nil {
+			return download, err
+		}
+	}
+
+	download.Cursor = page.NextCursor
+	download.Completed = page.NextCursor == ""
+	download.UpdatedAt = time.Now()
+
+	if err := tx.QueryRow("SELECT COUNT(*) FROM marking_codes WHERE task_id = ?", taskID).Scan(&download.Downloaded); err != nil {
+		return download, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO code_downloads (task_id, cursor, downloaded, completed, error, updated_at) VALUES (?, ?, ?, ?, '', ?)
+		ON CONFLICT(task_id) DO UPDATE SET cursor = excluded.cursor, downloaded = excluded.downloaded,
+			completed = excluded.completed, error = '', updated_at = excluded.updated_at`,
+		taskID, download.Cursor, download.Downloaded, download.Completed, download.UpdatedAt)
+	if err != nil {
+		return download, err
+	}
+
+	return download, tx.Commit()
+}
+
+// SaveDownloadError запоминает ошибку загрузки кодов, сохраняя курсор для продолжения
+func (r *MarkingCodeRepository) SaveDownloadError(taskID int, downloadErr error) error {
+	_, err := r.db.Exec(`
+		INSERT INTO code_downloads (task_id, error, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(task_id) DO UPDATE SET error = excluded.error, updated_at = excluded.updated_at`,
+		taskID, downloadErr.Error(), time.Now())
+	return err
+}
+
+// GetCodes возвращает коды задания с указанным статусом
+func (r *MarkingCodeRepository) GetCodes(taskID int, status string) ([]string, error) {
+	rows, err := r.db.Query("SELECT code FROM marking_codes WHERE task_id = ? AND status = ? ORDER BY code", taskID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := make([]string, 0)
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+// GetPool возвращает все коды, выданные для задания
+func (r *MarkingCodeRepository) GetPool(taskID int) ([]string, error) {
+	rows, err := r.db.Query("SELECT code FROM marking_codes WHERE task_id = ?", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := make([]string, 0)
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+// GetUsage возвращает количество кодов задания по статусам
+func (r *MarkingCodeRepository) GetUsage(taskID int) (models.CodeUsage, error) {
+	var usage models.CodeUsage
+
+	rows, err := r.db.Query("SELECT status, COUNT(*) FROM marking_codes WHERE task_id = ? GROUP BY status", taskID)
+	if err != nil {
+		return usage, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return usage, err
+		}
+
+		switch status {
+		case models.MarkingCodeStatusIssued:
+			usage.Issued = count
+		case models.MarkingCodeStatusConsumed:
+			usage.Consumed = count
+		case models.MarkingCodeStatusSpoiled:
+			usage.Spoiled = count
+		case models.MarkingCodeStatusReturned:
+			usage.Returned = count
+		}
+	}
+
+	return usage, rows.Err()
+}
+
+// finalizeCodesTx распределяет коды завершенного задания по статусам в рамках транзакции:
+// коды товаров задания, в том числе из несобранного короба, - использованы, коды товаров, удаленных или замененных в коробах, - испорчены,
+// остальные - возвращены. Коды сравниваются по ключу key, потому что сканер читает код не в том виде, в котором его выдал EZFactory
+func finalizeCodesTx(tx *sql.Tx, taskID int, key func(code string) string) error {
+	consumed, err := queryCodeKeysTx(tx, key, `
+		SELECT code FROM items WHERE task_id = ? AND status != ?
+		UNION SELECT code FROM pending_box_codes WHERE task_id = ?`,
+		taskID, StatusRemoved, taskID)
+	if err != nil {
+		return err
+	}
+
+	spoiled, err := queryCodeKeysTx(tx, key,
+		"SELECT item_code FROM container_events WHERE task_id = ? AND action IN (?, ?)",
+		taskID, models.ContainerActionRemoveItem, models.ContainerActionReplaceItem)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT code, status FROM marking_codes WHERE task_id = ?", taskID)
+	if err != nil {
+		return err
+	}
+
+	statuses := make(map[string]string)
+	for rows.Next() {
+		var code, status string
+		if err := rows.Scan(&code, &status); err != nil {
+			rows.Close()
+			return err
+		}
+
+		codeKey := key(code)
+		switch {
+		case consumed[codeKey]:
+			statuses[code] = models.MarkingCodeStatusConsumed
+		case status != models.MarkingCodeStatusIssued:
+			// Код уже распределен при предыдущем завершении
+		case spoiled[codeKey]:
+			statuses[code] = models.MarkingCodeStatusSpoiled
+		default:
+			statuses[code] = models.MarkingCodeStatusReturned
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("UPDATE marking_codes SET status = ?, updated_at = ? WHERE code = ? AND status != ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for code, status := range statuses {
+		if _, err := stmt.Exec(status, now, code, status); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queryCodeKeysTx возвращает ключи кодов, выбранных запросом
+func queryCodeKeysTx(tx *sql.Tx, key func(code string) string, query string, args ...any) (map[string]bool, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		keys[key(code)] = true
+	}
+
+	return keys, rows.Err()
+}
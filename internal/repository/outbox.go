@@ -0,0 +1,152 @@
+// internal/repository/outbox.go
+package repository
+
+import (
+	"database/sql"
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/models"
+	"time"
+)
+
+// OutboxRepository хранит исходящие вызовы EZFactory до их успешной отправки
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+// NewOutboxRepository создает новый репозиторий исходящих сообщений
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{
+		db: database.DB,
+	}
+}
+
+// Enqueue сохраняет сообщение в очередь отправки
+func (r *OutboxRepository) Enqueue(message models.OutboxMessage) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := enqueueOutboxTx(tx, message)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// GetPending возвращает сообщения очереди в порядке постановки
+func (r *OutboxRepository) GetPending(limit int) ([]models.OutboxMessage, error) {
+	return r.queryMessages(
+		"SELECT id, kind, task_id, payload, idempotency_key, attempts, last_error, created_at, failed_at FROM outbox WHERE sent_at IS NULL AND failed_at IS NULL ORDER BY id LIMIT ?",
+		limit)
+}
+
+// GetDeliverable возвращает сообщения очереди, которые можно отправлять, в порядке постановки.
+// Сообщения задания, у которого более раннее сообщение вынесено в неотправленные, ждут его возврата в очередь
+func (r *OutboxRepository) GetDeliverable(limit int) ([]models.OutboxMessage, error) {
+	return r.queryMessages(
+		`SELECT id, kind, task_id, payload, idempotency_key, attempts, last_error, created_at, failed_at FROM outbox o
+		WHERE sent_at IS NULL AND failed_at IS NULL AND NOT EXISTS (
+			SELECT 1 FROM outbox f WHERE f.task_id = o.task_id AND f.id < o.id AND f.sent_at IS NULL AND f.failed_at IS NOT NULL
+		) ORDER BY id LIMIT ?`,
+		limit)
+}
+
+// GetFailed возвращает неотправленные сообщения, вынесенные из очереди
+func (r *OutboxRepository) GetFailed(limit int) ([]models.OutboxMessage, error) {
+	return r.queryMessages(
+		"SELECT id, kind, task_id, payload, idempotency_key, attempts, last_error, created_at, failed_at FROM outbox WHERE sent_at IS NULL AND failed_at IS NOT NULL ORDER BY id LIMIT ?",
+		limit)
+}
+
+func (r *OutboxRepository) queryMessages(query string, args ...any) ([]models.OutboxMessage, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.OutboxMessage
+
+	for rows.Next() {
+		var message models.OutboxMessage
+		if err := rows.Scan(&message.ID, &message.Kind, &message.TaskID, &message.Payload, &message.IdempotencyKey,
+			&message.Attempts, &message.LastError, &message.CreatedAt, &message.FailedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+// CountPending возвращает количество сообщений в очереди
+func (r *OutboxRepository) CountPending() (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM outbox WHERE sent_at IS NULL AND failed_at IS NULL").Scan(&count)
+	return count, err
+}
+
+// CountFailed возвращает количество неотправленных сообщений, вынесенных из очереди
+func (r *OutboxRepository) CountFailed() (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM outbox WHERE sent_at IS NULL AND failed_at IS NOT NULL").Scan(&count)
+	return count, err
+}
+
+// MarkSent отмечает сообщение отправленным
+func (r *OutboxRepository) MarkSent(id int64) error {
+	_, err := r.db.Exec(
+		"UPDATE outbox SET sent_at = ?, last_error = '' WHERE id = ?",
+		time.Now(), id)
+	return err
+}
+
+// MarkFailed увеличивает счетчик попыток и сохраняет ошибку отправки
+func (r *OutboxRepository) MarkFailed(id int64, sendErr error) error {
+	_, err := r.db.Exec(
+		"UPDATE outbox SET attempts = attempts + 1, last_error = ? WHERE id = ?",
+		sendErr.Error(), id)
+	return err
+}
+
+// MarkDead сохраняет последнюю ошибку и выносит сообщение из очереди в неотправленные
+func (r *OutboxRepository) MarkDead(id int64, sendErr error) error {
+	_, err := r.db.Exec(
+		"UPDATE outbox SET attempts = attempts + 1, last_error = ?, failed_at = ? WHERE id = ?",
+		sendErr.Error(), time.Now(), id)
+	return err
+}
+
+// Requeue возвращает неотправленное сообщение в очередь с обнуленным счетчиком попыток
+func (r *OutboxRepository) Requeue(id int64) error {
+	result, err := r.db.Exec(
+		"UPDATE outbox SET failed_at = NULL, attempts = 0 WHERE id = ? AND sent_at IS NULL AND failed_at IS NOT NULL",
+		id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// enqueueOutboxTx сохраняет сообщение в очередь отправки в рамках транзакции
+func enqueueOutboxTx(tx *sql.Tx, message models.OutboxMessage) (int64, error) {
+	result, err := tx.Exec(
+		"INSERT INTO outbox (kind, task_id, payload, idempotency_key) VALUES (?, ?, ?, ?)",
+		message.Kind, message.TaskID, message.Payload, message.IdempotencyKey)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
@@ -0,0 +1,85 @@
+// internal/repository/pending_box.go
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/models"
+)
+
+// PendingBoxRepository хранит коды несобранного короба линии или ручной станции
+type PendingBoxRepository struct {
+	db      *sql.DB
+	station string
+}
+
+// NewPendingBoxRepository создает новый репозиторий несобранного короба для места сборки
+func NewPendingBoxRepository(station string) *PendingBoxRepository {
+	return &PendingBoxRepository{
+		db:      database.DB,
+		station: station,
+	}
+}
+
+// Station возвращает место сборки, короба которого хранит репозиторий
+func (r *PendingBoxRepository) Station() string {
+	return r.station
+}
+
+// AddCodes сохраняет слой кодов, уложенный в несобранный короб
+func (r *PendingBoxRepository) AddCodes(taskID int, layer int, codes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, code := range codes {
+		_, err := tx.Exec(
+			"INSERT INTO pending_box_codes (task_id, station, layer, code) VALUES (?, ?, ?, ?)",
+			taskID, r.station, layer, code)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения кода %s несобранного короба: %w", code, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetCodes возвращает коды несобранного короба задания в порядке укладки
+func (r *PendingBoxRepository) GetCodes(taskID int) ([]models.PendingBoxCode, error) {
+	rows, err := r.db.Query(
+		"SELECT id, task_id, station, layer, code, created_at FROM pending_box_codes WHERE task_id = ? AND station = ? ORDER BY id",
+		taskID, r.station)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []models.PendingBoxCode
+
+	for rows.Next() {
+		var code models.PendingBoxCode
+		if err := rows.Scan(&code.ID, &code.TaskID, &code.Station, &code.Layer, &code.Code, &code.CreatedAt); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+// Clear удаляет коды несобранного короба задания
+func (r *PendingBoxRepository) Clear(taskID int) error {
+	_, err := r.db.Exec("DELETE FROM pending_box_codes WHERE task_id = ? AND station = ?", taskID, r.station)
+	return err
+}
+
+// clearPendingBoxTx удаляет коды несобранного короба места сборки в рамках транзакции закрытия короба
+func clearPendingBoxTx(tx *sql.Tx, taskID int, station string) error {
+	if _, err := tx.Exec("DELETE FROM pending_box_codes WHERE task_id = ? AND station = ?", taskID, station); err != nil {
+		return fmt.Errorf("ошибка очистки несобранного короба: %w", err)
+	}
+	return nil
+}
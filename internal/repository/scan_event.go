@@ -0,0 +1,96 @@
+// internal/repository/scan_event.go
+package repository
+
+import (
+	"database/sql"
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/models"
+	"strings"
+)
+
+// ScanEventRepository предоставляет методы для работы с журналом сканирования
+type ScanEventRepository struct {
+	db *sql.DB
+}
+
+// NewScanEventRepository создает новый репозиторий журнала сканирования
+func NewScanEventRepository() *ScanEventRepository {
+	return &ScanEventRepository{
+		db: database.DB,
+	}
+}
+
+// CreateScanEvent сохраняет запись о срабатывании сканера
+func (r *ScanEventRepository) CreateScanEvent(event models.ScanEvent) (int64, error) {
+	result, err := r.db.Exec(
+		"INSERT INTO scan_events (task_id, raw_response, codes, outcome, reason, message) VALUES (?, ?, ?, ?, ?, ?)",
+		event.TaskID, event.RawResponse, strings.Join(event.Codes, " "), event.Outcome, event.Reason, event.Message)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// GetScanEventsByTaskID возвращает последние записи журнала для задания
+func (r *ScanEventRepository) GetScanEventsByTaskID(taskID int, limit int) ([]models.ScanEvent, error) {
+	rows, err := r.db.Query(
+		"SELECT id, task_id, raw_response, codes, outcome, reason, message, created_at FROM scan_events WHERE task_id = ? ORDER BY id DESC LIMIT ?",
+		taskID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.ScanEvent
+
+	for rows.Next() {
+		var event models.ScanEvent
+		var codes string
+		if err := rows.Scan(&event.ID, &event.TaskID, &event.RawResponse, &codes, &event.Outcome, &event.Reason, &event.Message, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.Codes = strings.Fields(codes)
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// CountScanEventsByReason возвращает количество отклонений по причинам для задания
+func (r *ScanEventRepository) CountScanEventsByReason(taskID int) (map[string]int, error) {
+	rows, err := r.db.Query(
+		"SELECT reason, COUNT(*) FROM scan_events WHERE task_id = ? AND outcome = ? GROUP BY reason",
+		taskID, models.ScanOutcomeRejected)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+
+	for rows.Next() {
+		var reason string
+		var count int
+		if err := rows.Scan(&reason, &count); err != nil {
+			return nil, err
+		}
+		counts[reason] = count
+	}
+
+	return counts, nil
+}
+
+// CountAcceptedScanEvents возвращает количество принятых срабатываний для задания
+func (r *ScanEventRepository) CountAcceptedScanEvents(taskID int) (int, error) {
+	var count int
+
+	err := r.db.QueryRow(
+		"SELECT COUNT(*) FROM scan_events WHERE task_id = ? AND outcome = ?",
+		taskID, models.ScanOutcomeAccepted).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
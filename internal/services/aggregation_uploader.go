@@ -0,0 +1,117 @@
+// internal/services/aggregation_uploader.go
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/ze674/EZLine/internal/api"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"log"
+)
+
+// Количество коробов в одной части выгрузки по умолчанию
+const defaultUploadChunkSize = 100
+
+// AggregationUploader выгружает в EZFactory иерархию короб -> товары задания частями.
+// Выгруженные короба отмечаются в базе, поэтому после сбоя выгрузка продолжается с первой неотправленной части.
+// Выгрузка запускается сообщением очереди отправки и повторяется, пока не будет отправлена целиком
+type AggregationUploader struct {
+	factoryClient       *api.FactoryClient
+	outbox              *OutboxSender
+	containerRepository *repository.ContainerRepository
+	chunkSize           int
+}
+
+// NewAggregationUploader создает сервис выгрузки агрегации и регистрирует его в очереди отправки
+func NewAggregationUploader(factoryClient *api.FactoryClient, outbox *OutboxSender, chunkSize int) *AggregationUploader {
+	if chunkSize <= 0 {
+		chunkSize = defaultUploadChunkSize
+	}
+
+	u := &AggregationUploader{
+		factoryClient:       factoryClient,
+		outbox:              outbox,
+		containerRepository: repository.NewContainerRepository(),
+		chunkSize:           chunkSize,
+	}
+
+	outbox.Handle(models.OutboxKindAggregationUpload, u.send)
+
+	return u
+}
+
+// send выгружает неотправленные короба задания из сообщения очереди
+func (u *AggregationUploader) send(ctx context.Context, message models.OutboxMessage) error {
+	var payload models.AggregationUploadPayload
+	if err := json.Unmarshal([]byte(message.Payload), &payload); err != nil {
+		return fmt.Errorf("ошибка разбора сообщения: %w", err)
+	}
+
+	sent, err := u.Upload(ctx, payload.TaskID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Агрегация задания %d выгружена в EZFactory, коробов: %d", payload.TaskID, sent)
+
+	if payload.MarkTaskSent {
+		return u.outbox.Enqueue(models.OutboxKindTaskStatus, payload.TaskID, models.TaskStatusPayload{
+			TaskID: payload.TaskID,
+			Status: models.TaskStatusSent,
+		})
+	}
+
+	return nil
+}
+
+// Upload отправляет неотправленные короба задания частями и возвращает количество отправленных коробов
+func (u *AggregationUploader) Upload(ctx context.Context, taskID int) (int, error) {
+	op := "services.AggregationUploader.Upload"
+
+	sent := 0
+	for {
+		containers, err := u.containerRepository.GetUnsentAggregation(taskID, u.chunkSize)
+		if err != nil {
+			return sent, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(containers) == 0 {
+			return sent, nil
+		}
+
+		chunk := models.AggregationChunk{TaskID: taskID, Containers: containers}
+		key, err := chunkIdempotencyKey(chunk)
+		if err != nil {
+			return sent, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := u.factoryClient.UploadAggregation(ctx, chunk, key); err != nil {
+			return sent, fmt.Errorf("%s: %w", op, err)
+		}
+
+		ids := make([]int64, len(containers))
+		for i, container := range containers {
+			ids[i] = container.ID
+		}
+		if err := u.containerRepository.MarkSent(ids); err != nil {
+			return sent, fmt.Errorf("%s: %w", op, err)
+		}
+
+		sent += len(containers)
+	}
+}
+
+// chunkIdempotencyKey строит ключ части по ее содержимому: если часть отправлена, а отметка о выгрузке
+// не сохранилась, повтор той же части не создаст дублей, а измененный после выгрузки короб уйдет с новым ключом
+func chunkIdempotencyKey(chunk models.AggregationChunk) (string, error) {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("aggregation-%d-%s", chunk.TaskID, hex.EncodeToString(sum[:16])), nil
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ze674/EZLine/internal/api"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+)
+
+// createBoxes сохраняет короба задания, в каждом по два кода
+func createBoxes(t *testing.T, taskID int, count int) {
+	t.Helper()
+
+	containers := repository.NewContainerRepository()
+	for i := 1; i <= count; i++ {
+		codes := []string{fmt.Sprintf("item-%d-%d-1", taskID, i), fmt.Sprintf("item-%d-%d-2", taskID, i)}
+		if _, err := containers.CreateContainerWithItems(fmt.Sprintf("box-%d-%d", taskID, i), i, taskID, models.PendingBoxStationLine, codes); err != nil {
+			t.Fatalf("CreateContainerWithItems: %v", err)
+		}
+	}
+}
+
+func TestAggregationUploaderSendsChunksAndMarksTaskSent(t *testing.T) {
+	setupDB(t)
+	factory, client := newTestFactory(t)
+
+	outbox := NewOutboxSender(time.Second, 20)
+	NewTaskService(client, outbox, nil, 1)
+	NewAggregationUploader(client, outbox, 2)
+
+	createBoxes(t, 1, 5)
+
+	enqueueStatus(t, outbox, 1, models.TaskStatusCompleted)
+	if err := outbox.Enqueue(models.OutboxKindAggregationUpload, 1, models.AggregationUploadPayload{TaskID: 1, MarkTaskSent: true}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	// Статус "отправлено" ставится в очередь после выгрузки и уходит следующим проходом
+	for i := 0; i < 2; i++ {
+		if err := outbox.flush(context.Background()); err != nil {
+			t.Fatalf("flush: %v", err)
+		}
+	}
+
+	uploads := factory.Uploads()
+	if len(uploads) != 3 {
+		t.Fatalf("частей выгрузки = %d, ожидалось 3", len(uploads))
+	}
+	var boxes []string
+	for _, upload := range uploads {
+		if upload.IdempotencyKey == "" {
+			t.Error("часть выгрузки без ключа идемпотентности")
+		}
+		for _, container := range upload.Chunk.Containers {
+			if len(container.Items) != 2 {
+				t.Errorf("короб %s выгружен с %d кодами, ожидалось 2", container.Code, len(container.Items))
+			}
+			boxes = append(boxes, container.Code)
+		}
+	}
+	if got := strings.Join(boxes, ","); got != "box-1-1,box-1-2,box-1-3,box-1-4,box-1-5" {
+		t.Errorf("выгруженные короба = %s", got)
+	}
+
+	got := statuses(factory)
+	if len(got) != 2 || got[0] != models.TaskStatusCompleted || got[1] != models.TaskStatusSent {
+		t.Errorf("статусы = %v, ожидались \"завершено\", затем \"отправлено\"", got)
+	}
+
+	unsent, err := repository.NewContainerRepository().GetUnsentAggregation(1, 100)
+	if err != nil {
+		t.Fatalf("GetUnsentAggregation: %v", err)
+	}
+	if len(unsent) != 0 {
+		t.Errorf("неотправленных коробов = %d, ожидалось 0", len(unsent))
+	}
+}
+
+func TestAggregationUploaderResumesAfterFailure(t *testing.T) {
+	setupDB(t)
+	factory, client := newTestFactory(t)
+
+	outbox := NewOutboxSender(time.Second, 20)
+	uploader := NewAggregationUploader(client, outbox, 2)
+
+	createBoxes(t, 2, 5)
+
+	// Связь пропадает после первой части
+	var calls atomic.Int32
+	client.HTTPClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if calls.Add(1) == 2 {
+			factory.down.Store(true)
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	sent, err := uploader.Upload(context.Background(), 2)
+	if !api.IsUnavailable(err) {
+		t.Fatalf("Upload без связи: %v, ожидалась недоступность", err)
+	}
+	if sent != 2 {
+		t.Fatalf("отправлено коробов до сбоя = %d, ожидалось 2", sent)
+	}
+
+	factory.down.Store(false)
+	sent, err = uploader.Upload(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Upload после восстановления связи: %v", err)
+	}
+	if sent != 3 {
+		t.Errorf("отправлено коробов после сбоя = %d, ожидалось 3", sent)
+	}
+
+	// Каждый короб выгружен ровно один раз
+	seen := make(map[string]int)
+	for _, upload := range factory.Uploads() {
+		for _, container := range upload.Chunk.Containers {
+			seen[container.Code]++
+		}
+	}
+	for i := 1; i <= 5; i++ {
+		code := fmt.Sprintf("box-2-%d", i)
+		if seen[code] != 1 {
+			t.Errorf("короб %s выгружен %d раз, ожидался 1", code, seen[code])
+		}
+	}
+}
+
+// roundTripFunc позволяет вмешаться в запросы клиента
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,272 @@
+// internal/services/container_service.go
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/internal/validator"
+	"strings"
+)
+
+// ContainerDetails содержит контейнер, его товары и журнал изменений
+type ContainerDetails struct {
+	Container models.Container
+	Items     []models.Item
+	Events    []models.ContainerEvent
+}
+
+// ContainerService предоставляет операции редактирования и расформирования контейнеров
+type ContainerService struct {
+	taskService         *TaskService
+	uniqueValidator     *CodeUniquenessValidator
+	codeLength          int
+	containerRepository *repository.ContainerRepository
+	itemRepository      *repository.ItemRepository
+	eventRepository     *repository.ContainerEventRepository
+}
+
+// NewContainerService создает сервис для работы с контейнерами.
+// Валидатор уникальности должен быть общим с процессорами, чтобы их кэш оставался согласованным
+func NewContainerService(taskService *TaskService, uniqueValidator *CodeUniquenessValidator, codeLength int) *ContainerService {
+	return &ContainerService{
+		taskService:         taskService,
+		uniqueValidator:     uniqueValidator,
+		codeLength:          codeLength,
+		containerRepository: repository.NewContainerRepository(),
+		itemRepository:      repository.NewItemRepository(),
+		eventRepository:     repository.NewContainerEventRepository(),
+	}
+}
+
+// GetContainersByTaskID возвращает контейнеры задания
+func (s *ContainerService) GetContainersByTaskID(taskID int) ([]models.Container, error) {
+	return s.containerRepository.GetContainerSummariesByTaskID(taskID)
+}
+
+// FindContainerByCode возвращает ID контейнера по его коду или по коду уложенного в него товара
+func (s *ContainerService) FindContainerByCode(code string) (int64, error) {
+	code = strings.TrimSpace(code)
+
+	container, err := s.containerRepository.GetContainerByCode(code)
+	if err == nil {
+		return container.ID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	// Код товара - открываем короб, в который он уложен
+	item, err := s.itemRepository.GetItemByCode(code)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && item.ContainerID == nil) {
+		return 0, fmt.Errorf("контейнер %s не найден", code)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return *item.ContainerID, nil
+}
+
+// GetContainerDetails возвращает контейнер вместе с товарами и журналом изменений
+func (s *ContainerService) GetContainerDetails(containerID int64) (ContainerDetails, error) {
+	container, err := s.getContainer(containerID)
+	if err != nil {
+		return ContainerDetails{}, err
+	}
+
+	items, err := s.itemRepository.GetItemsByContainerID(containerID)
+	if err != nil {
+		return ContainerDetails{}, fmt.Errorf("ошибка при получении товаров контейнера: %w", err)
+	}
+
+	events, err := s.eventRepository.GetEventsByContainerID(containerID)
+	if err != nil {
+		return ContainerDetails{}, fmt.Errorf("ошибка при получении журнала контейнера: %w", err)
+	}
+
+	return ContainerDetails{
+		Container: *container,
+		Items:     items,
+		Events:    events,
+	}, nil
+}
+
+// RemoveItem удаляет товар из контейнера, код освобождается для повторной агрегации
+func (s *ContainerService) RemoveItem(containerID int64, itemCode, reason string) error {
+	container, err := s.getEditableContainer(containerID)
+	if err != nil {
+		return err
+	}
+
+	item, err := s.getContainerItem(container, itemCode)
+	if err != nil {
+		return err
+	}
+
+	if err := s.containerRepository.RemoveItem(container, item, reason); err != nil {
+		return err
+	}
+
+	s.updateUsedCodes(container, nil, []string{item.Code})
+	return nil
+}
+
+// ReplaceItem заменяет поврежденный товар в контейнере новым
+func (s *ContainerService) ReplaceItem(containerID int64, oldCode, newCode, reason string) error {
+	container, err := s.getEditableContainer(containerID)
+	if err != nil {
+		return err
+	}
+
+	item, err := s.getContainerItem(container, oldCode)
+	if err != nil {
+		return err
+	}
+
+	newCode = strings.TrimSpace(newCode)
+	if err := s.checkNewCodes(container, []string{newCode}); err != nil {
+		return err
+	}
+
+	if err := s.containerRepository.ReplaceItem(container, item, newCode, reason); err != nil {
+		return err
+	}
+
+	s.updateUsedCodes(container, []string{newCode}, []string{item.Code})
+	return nil
+}
+
+// AddItems добавляет товары в контейнер
+func (s *ContainerService) AddItems(containerID int64, codes []string, reason string) error {
+	container, err := s.getEditableContainer(containerID)
+	if err != nil {
+		return err
+	}
+
+	if len(codes) == 0 {
+		return fmt.Errorf("не указаны коды для добавления")
+	}
+
+	if err := s.checkNewCodes(container, codes); err != nil {
+		return err
+	}
+
+	if err := s.containerRepository.AddItems(container, codes, reason); err != nil {
+		return err
+	}
+
+	s.updateUsedCodes(container, codes, nil)
+	return nil
+}
+
+// DisbandContainer расформировывает контейнер, все его коды освобождаются
+func (s *ContainerService) DisbandContainer(containerID int64, reason string) error {
+	container, err := s.getEditableContainer(containerID)
+	if err != nil {
+		return err
+	}
+
+	codes, err := s.containerRepository.Disband(container, reason)
+	if err != nil {
+		return err
+	}
+
+	s.updateUsedCodes(container, nil, codes)
+	return nil
+}
+
+// updateUsedCodes обновляет кэш использованных кодов процессоров.
+// Кэш хранит коды только активного задания, правки коробов других заданий его не касаются
+func (s *ContainerService) updateUsedCodes(container *models.Container, used, freed []string) {
+	if container.TaskID != s.taskService.GetActiveTaskID() {
+		return
+	}
+
+	s.uniqueValidator.UnmarkCodes(freed)
+	s.uniqueValidator.MarkCodesAsUsed(used)
+}
+
+func (s *ContainerService) getContainer(containerID int64) (*models.Container, error) {
+	container, err := s.containerRepository.GetContainerByID(containerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("контейнер %d не найден", containerID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении контейнера: %w", err)
+	}
+	return container, nil
+}
+
+func (s *ContainerService) getEditableContainer(containerID int64) (*models.Container, error) {
+	container, err := s.getContainer(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if container.Status == repository.StatusDisbanded {
+		return nil, fmt.Errorf("контейнер %s расформирован", container.Code)
+	}
+
+	return container, nil
+}
+
+func (s *ContainerService) getContainerItem(container *models.Container, code string) (*models.Item, error) {
+	item, err := s.itemRepository.GetItemByCode(strings.TrimSpace(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("товар %s не найден", code)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении товара: %w", err)
+	}
+
+	if item.ContainerID == nil || *item.ContainerID != container.ID {
+		return nil, fmt.Errorf("товар %s не находится в контейнере %s", code, container.Code)
+	}
+
+	return item, nil
+}
+
+// checkNewCodes проверяет коды, добавляемые в контейнер: формат, GTIN продукта и уникальность
+func (s *ContainerService) checkNewCodes(container *models.Container, codes []string) error {
+	task, err := s.taskService.GetTaskByID(container.TaskID)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении задания: %w", err)
+	}
+
+	product, err := s.taskService.GetProductByID(task.ProductID)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении продукта: %w", err)
+	}
+
+	pool, err := s.taskService.GetCodePool(task.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении кодов задания: %w", err)
+	}
+
+	codeValidator := validator.NewCodeValidator(product.GTIN, s.codeLength)
+	codeValidator.UsePool(pool)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if seen[code] {
+			return fmt.Errorf("код %s указан несколько раз", code)
+		}
+		seen[code] = true
+
+		if result := codeValidator.ValidateCode(code); !result.Valid {
+			return fmt.Errorf("код %s: %s", code, result.Message)
+		}
+
+		_, err := s.itemRepository.GetItemByCode(code)
+		if err == nil {
+			return fmt.Errorf("код %s уже использован", code)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("ошибка при проверке кода %s: %w", code, err)
+		}
+	}
+
+	return nil
+}
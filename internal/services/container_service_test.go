@@ -0,0 +1,189 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+)
+
+// newTestContainerService создает сервис для активного задания 1 и короб этого задания с кодами AAAAA1 и AAAAA2
+func newTestContainerService(t *testing.T) (*ContainerService, int64) {
+	t.Helper()
+
+	_, client := newTestFactory(t)
+	outbox := NewOutboxSender(time.Second, 20)
+	taskService := NewTaskService(client, outbox, NewMarkingCodeService(client, outbox, 100, 6), 1)
+	if err := repository.NewActiveTaskRepository().SaveActiveTask(1, models.TaskPlan{}); err != nil {
+		t.Fatalf("SaveActiveTask: %v", err)
+	}
+	taskService.activeTaskID = 1
+
+	containerID, err := repository.NewContainerRepository().CreateContainerWithItems("046070547612440000001", 1, 1,
+		models.PendingBoxStationLine, []string{markingCode("AAAAA1"), markingCode("AAAAA2")})
+	if err != nil {
+		t.Fatalf("CreateContainerWithItems: %v", err)
+	}
+
+	unique := NewCodeUniquenessValidator()
+	if err := unique.Initialize(1); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	return NewContainerService(taskService, unique, len(markingCode("AAAAA1"))), containerID
+}
+
+func TestContainerServiceEdits(t *testing.T) {
+	tests := []struct {
+		name        string
+		edit        func(s *ContainerService, id int64) error
+		wantErr     bool
+		wantStatus  string
+		wantCodes   []string // Коды в коробе после правки
+		wantActions []string // Записи журнала, от последней к первой
+		wantFreed   []string // Коды, освобожденные для повторной агрегации
+	}{
+		{"удаление товара", func(s *ContainerService, id int64) error {
+			return s.RemoveItem(id, markingCode("AAAAA2"), "брак")
+		}, false, repository.StatusModified, []string{"AAAAA1"},
+			[]string{models.ContainerActionRemoveItem}, []string{"AAAAA2"}},
+		{"удаление всех товаров", func(s *ContainerService, id int64) error {
+			if err := s.RemoveItem(id, markingCode("AAAAA1"), "брак"); err != nil {
+				return err
+			}
+			return s.RemoveItem(id, markingCode("AAAAA2"), "брак")
+		}, false, repository.StatusDisbanded, nil,
+			[]string{models.ContainerActionRemoveItem, models.ContainerActionRemoveItem}, []string{"AAAAA1", "AAAAA2"}},
+		{"удаление чужого товара", func(s *ContainerService, id int64) error {
+			return s.RemoveItem(id, markingCode("AAAAA3"), "брак")
+		}, true, repository.StatusCreated, []string{"AAAAA1", "AAAAA2"}, nil, nil},
+		{"замена товара", func(s *ContainerService, id int64) error {
+			return s.ReplaceItem(id, markingCode("AAAAA1"), markingCode("AAAAA3"), "повреждена упаковка")
+		}, false, repository.StatusModified, []string{"AAAAA2", "AAAAA3"},
+			[]string{models.ContainerActionReplaceItem}, []string{"AAAAA1"}},
+		{"замена на код из короба", func(s *ContainerService, id int64) error {
+			return s.ReplaceItem(id, markingCode("AAAAA1"), markingCode("AAAAA2"), "повреждена упаковка")
+		}, true, repository.StatusCreated, []string{"AAAAA1", "AAAAA2"}, nil, nil},
+		{"добавление товаров", func(s *ContainerService, id int64) error {
+			return s.AddItems(id, []string{markingCode("AAAAA3"), markingCode("AAAAA4")}, "досборка")
+		}, false, repository.StatusModified, []string{"AAAAA1", "AAAAA2", "AAAAA3", "AAAAA4"},
+			[]string{models.ContainerActionAddItem, models.ContainerActionAddItem}, nil},
+		{"добавление кода другого продукта", func(s *ContainerService, id int64) error {
+			return s.AddItems(id, []string{"0104607054766165" + "21AAAAA3" + "\x1d93vHpW"}, "досборка")
+		}, true, repository.StatusCreated, []string{"AAAAA1", "AAAAA2"}, nil, nil},
+		{"расформирование", func(s *ContainerService, id int64) error {
+			return s.DisbandContainer(id, "пересборка")
+		}, false, repository.StatusDisbanded, nil,
+			[]string{models.ContainerActionDisband}, []string{"AAAAA1", "AAAAA2"}},
+		{"правка расформированного короба", func(s *ContainerService, id int64) error {
+			if err := s.DisbandContainer(id, "пересборка"); err != nil {
+				return err
+			}
+			return s.AddItems(id, []string{markingCode("AAAAA3")}, "досборка")
+		}, true, repository.StatusDisbanded, nil,
+			[]string{models.ContainerActionDisband}, []string{"AAAAA1", "AAAAA2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+
+			s, containerID := newTestContainerService(t)
+
+			if err := tt.edit(s, containerID); (err != nil) != tt.wantErr {
+				t.Fatalf("правка = %v", err)
+			}
+
+			details, err := s.GetContainerDetails(containerID)
+			if err != nil {
+				t.Fatalf("GetContainerDetails: %v", err)
+			}
+
+			if details.Container.Status != tt.wantStatus {
+				t.Errorf("статус %s, ожидался %s", details.Container.Status, tt.wantStatus)
+			}
+
+			var codes []string
+			for _, item := range details.Items {
+				codes = append(codes, item.Code)
+			}
+			var wantCodes []string
+			for _, serial := range tt.wantCodes {
+				wantCodes = append(wantCodes, markingCode(serial))
+			}
+			slices.Sort(codes)
+			if !slices.Equal(codes, wantCodes) {
+				t.Errorf("коды в коробе %q, ожидались %q", codes, wantCodes)
+			}
+
+			var actions []string
+			for _, event := range details.Events {
+				actions = append(actions, event.Action)
+			}
+			if !slices.Equal(actions, tt.wantActions) {
+				t.Errorf("журнал %v, ожидался %v", actions, tt.wantActions)
+			}
+
+			for _, serial := range tt.wantCodes {
+				if !s.uniqueValidator.IsCodeUsed(markingCode(serial)) {
+					t.Errorf("код %s в коробе не отмечен использованным", serial)
+				}
+			}
+			for _, serial := range tt.wantFreed {
+				if s.uniqueValidator.IsCodeUsed(markingCode(serial)) {
+					t.Errorf("код %s не освобожден", serial)
+				}
+			}
+		})
+	}
+}
+
+func TestContainerServiceAuditRecordsCodesAndReason(t *testing.T) {
+	setupDB(t)
+
+	s, containerID := newTestContainerService(t)
+
+	if err := s.ReplaceItem(containerID, markingCode("AAAAA1"), markingCode("AAAAA3"), "повреждена упаковка"); err != nil {
+		t.Fatalf("ReplaceItem: %v", err)
+	}
+	if err := s.DisbandContainer(containerID, "пересборка"); err != nil {
+		t.Fatalf("DisbandContainer: %v", err)
+	}
+
+	details, err := s.GetContainerDetails(containerID)
+	if err != nil {
+		t.Fatalf("GetContainerDetails: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  models.ContainerEvent
+		want models.ContainerEvent
+	}{
+		{"расформирование", details.Events[0], models.ContainerEvent{
+			Action:   models.ContainerActionDisband,
+			ItemCode: markingCode("AAAAA2") + " " + markingCode("AAAAA3"),
+			Reason:   "пересборка",
+		}},
+		{"замена", details.Events[1], models.ContainerEvent{
+			Action:      models.ContainerActionReplaceItem,
+			ItemCode:    markingCode("AAAAA1"),
+			NewItemCode: markingCode("AAAAA3"),
+			Reason:      "повреждена упаковка",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got.ContainerID != containerID || tt.got.TaskID != 1 {
+				t.Errorf("запись относится к коробу %d задания %d", tt.got.ContainerID, tt.got.TaskID)
+			}
+			if tt.got.Action != tt.want.Action || tt.got.ItemCode != tt.want.ItemCode ||
+				tt.got.NewItemCode != tt.want.NewItemCode || tt.got.Reason != tt.want.Reason {
+				t.Errorf("запись = %+v, ожидалась %+v", tt.got, tt.want)
+			}
+		})
+	}
+}
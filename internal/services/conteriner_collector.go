@@ -2,6 +2,7 @@
 package services
 
 import (
+	"github.com/ze674/EZLine/internal/models"
 	"sync"
 )
 
@@ -111,6 +112,63 @@ func (c *ContainerCollector) GetLayerCapacity() int {
 	return c.layerCapacity
 }
 
+// GetTotalLayers возвращает количество слоев в коробе
+func (c *ContainerCollector) GetTotalLayers() int {
+	return c.totalLayers
+}
+
+// NextLayerSize возвращает ожидаемое количество кодов в следующем слое.
+// Последний слой может быть неполным, если емкость короба не кратна емкости слоя
+func (c *ContainerCollector) NextLayerSize() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	remaining := c.containerCapacity - len(c.pendingCodes)
+	if remaining < c.layerCapacity {
+		return remaining
+	}
+	return c.layerCapacity
+}
+
+// Restore заменяет накопленные коды сохраненными, например после перезапуска.
+// Проверка емкости слоя не выполняется: коды уже были приняты ранее
+func (c *ContainerCollector) Restore(codes []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pendingCodes = make([]string, len(codes))
+	copy(c.pendingCodes, codes)
+}
+
+// RemoveLast удаляет последние n накопленных кодов (откат слоя)
+func (c *ContainerCollector) RemoveLast(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n > len(c.pendingCodes) {
+		n = len(c.pendingCodes)
+	}
+	c.pendingCodes = c.pendingCodes[:len(c.pendingCodes)-n]
+}
+
+// Progress возвращает состояние заполнения короба
+func (c *ContainerCollector) Progress() models.BoxProgress {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	layers := 0
+	if c.layerCapacity > 0 {
+		layers = (len(c.pendingCodes) + c.layerCapacity - 1) / c.layerCapacity
+	}
+
+	return models.BoxProgress{
+		Count:       len(c.pendingCodes),
+		Capacity:    c.containerCapacity,
+		Layer:       layers,
+		TotalLayers: c.totalLayers,
+	}
+}
+
 // IsFull проверяет, достигли ли мы емкости короба
 func (c *ContainerCollector) IsFull() bool {
 	c.mu.Lock()
@@ -0,0 +1,73 @@
+// internal/services/export_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/export"
+	"github.com/ze674/EZLine/internal/repository"
+	"io"
+)
+
+// Ошибки выгрузки агрегации
+var (
+	ErrNothingToExport = errors.New("у задания нет собранных коробов")
+	ErrInvalidDocument = errors.New("документ не соответствует формату")
+)
+
+// ExportService формирует файлы агрегации задания из сохраненных коробов и товаров
+type ExportService struct {
+	containerRepository *repository.ContainerRepository
+}
+
+// NewExportService создает сервис выгрузки агрегации в файлы
+func NewExportService() *ExportService {
+	return &ExportService{
+		containerRepository: repository.NewContainerRepository(),
+	}
+}
+
+// WriteCodeNamesSerial записывает агрегацию задания в XML CodeNamesSerial и возвращает количество кодов
+func (s *ExportService) WriteCodeNamesSerial(w io.Writer, taskID int, outID string) (int, error) {
+	op := "services.ExportService.WriteCodeNamesSerial"
+
+	containers, err := s.containerRepository.GetAggregation(taskID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(containers) == 0 {
+		return 0, fmt.Errorf("%s: %w", op, ErrNothingToExport)
+	}
+
+	count, err := export.WriteCodeNamesSerial(w, containers, outID)
+	if err != nil {
+		return count, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// GISMTAggregation формирует документ агрегации ГИС МТ для задания и проверяет его структуру.
+// Документ, не прошедший проверку, не возвращается: ошибка содержит все найденные нарушения
+func (s *ExportService) GISMTAggregation(taskID int, inn string, serialLength int) (export.GISMTAggregationDocument, error) {
+	op := "services.ExportService.GISMTAggregation"
+
+	containers, err := s.containerRepository.GetAggregation(taskID)
+	if err != nil {
+		return export.GISMTAggregationDocument{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(containers) == 0 {
+		return export.GISMTAggregationDocument{}, fmt.Errorf("%s: %w", op, ErrNothingToExport)
+	}
+
+	document, err := export.BuildGISMTAggregation(containers, inn, serialLength)
+	if err != nil {
+		return export.GISMTAggregationDocument{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidDocument, err)
+	}
+
+	if err := document.Validate(); err != nil {
+		return export.GISMTAggregationDocument{}, fmt.Errorf("%s: %w:\n%w", op, ErrInvalidDocument, err)
+	}
+
+	return document, nil
+}
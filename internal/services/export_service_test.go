@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ze674/EZLine/internal/export"
+	"github.com/ze674/EZLine/internal/markingcode"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+)
+
+// markingCode возвращает код маркировки с разделителем GS и криптохвостом
+func markingCode(serial string) string {
+	return "0104607054766164" + "21" + serial + markingcode.GroupSeparator + "93vHpW"
+}
+
+func TestExportServiceGISMTAggregationUsesBoxSSCC(t *testing.T) {
+	setupDB(t)
+
+	prefix := markingcode.SSCCPrefix{Extension: 1, CompanyPrefix: "4607054"}
+
+	containers := repository.NewContainerRepository()
+	containers.UseSSCC(prefix)
+	if _, err := containers.CreateContainerWithItems("046070547612440000001", 1, 1, models.PendingBoxStationLine,
+		[]string{markingCode("AAAAA1"), markingCode("AAAAA2")}); err != nil {
+		t.Fatalf("CreateContainerWithItems: %v", err)
+	}
+	if _, err := containers.CreatePartialContainerWithItems("046070547612440000002", 2, 1, models.PendingBoxStationManual,
+		[]string{markingCode("AAAAA3")}, "закрыт неполным: 1 из 2 шт"); err != nil {
+		t.Fatalf("CreatePartialContainerWithItems: %v", err)
+	}
+
+	if err := repository.NewFactoryCacheRepository().SaveTask(models.Task{ID: 1, LineID: 1, ProductID: 1}); err != nil {
+		t.Fatalf("SaveTask: %v", err)
+	}
+
+	importer := NewImportService()
+	importer.UseSSCC(prefix)
+	legacy := []models.LegacyBox{{
+		Code:     "4607054761244310320254150001",
+		ClosedAt: time.Now(),
+		File:     "input.txt",
+		Line:     1,
+		Items:    []models.LegacyItem{{Code: markingCode("AAAAA4"), Line: 2}},
+	}}
+	if _, err := importer.ImportLegacyBoxes(1, legacy, false); err != nil {
+		t.Fatalf("ImportLegacyBoxes: %v", err)
+	}
+
+	document, err := NewExportService().GISMTAggregation(1, "7707083893", 0)
+	if err != nil {
+		t.Fatalf("GISMTAggregation: %v", err)
+	}
+
+	if len(document.AggregationUnits) != 3 {
+		t.Fatalf("агрегатов %d, ожидалось 3", len(document.AggregationUnits))
+	}
+	for i, unit := range document.AggregationUnits {
+		sscc, err := prefix.SSCC(int64(i + 1))
+		if err != nil {
+			t.Fatalf("SSCC: %v", err)
+		}
+		if unit.UnitSerialNumber != "00"+sscc {
+			t.Errorf("агрегат %d: код %s, ожидался SSCC 00%s", i+1, unit.UnitSerialNumber, sscc)
+		}
+	}
+	if got := document.AggregationUnits[2].Sntins; len(got) != 1 || got[0] != "0104607054766164"+"21AAAAA4" {
+		t.Errorf("коды импортированного короба = %v", got)
+	}
+}
+
+func TestExportServiceGISMTAggregationRejectsBoxWithoutSSCC(t *testing.T) {
+	setupDB(t)
+
+	containers := repository.NewContainerRepository()
+	id, err := containers.CreateContainerWithItems("046070547612440000001", 1, 1, models.PendingBoxStationLine, []string{markingCode("AAAAA1")})
+	if err != nil {
+		t.Fatalf("CreateContainerWithItems: %v", err)
+	}
+	if sscc := containers.ContainerSSCC(id); sscc != "" {
+		t.Fatalf("SSCC без префикса = %q", sscc)
+	}
+
+	_, err = NewExportService().GISMTAggregation(1, "7707083893", 0)
+	if !errors.Is(err, ErrInvalidDocument) || !errors.Is(err, export.ErrNoSSCC) {
+		t.Errorf("ошибка = %v, ожидалась %v", err, fmt.Errorf("%w: %w", ErrInvalidDocument, export.ErrNoSSCC))
+	}
+}
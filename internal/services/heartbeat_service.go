@@ -0,0 +1,232 @@
+// internal/services/heartbeat_service.go
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/ze674/EZLine/internal/api"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"log"
+	"sync"
+	"time"
+)
+
+// Количество снимков в одном запросе к EZFactory
+const heartbeatBatchSize = 100
+
+// HeartbeatSource - процессор, состояние которого передается в EZFactory
+type HeartbeatSource interface {
+	State() models.StateChange
+}
+
+// DeviceHealthSource - оборудование линии, которое запоминает результат последнего обмена
+type DeviceHealthSource interface {
+	Health() models.DeviceHealth
+}
+
+// cameraStatusSource реализуется процессорами, которые ведут диагностику камер
+type cameraStatusSource interface {
+	CameraStatuses() []models.CameraStatus
+}
+
+// deviceHealthSource реализуется процессорами, которые ведут диагностику своего оборудования (ПЛК)
+type deviceHealthSource interface {
+	DeviceHealth() []models.DeviceHealth
+}
+
+// heartbeatProcessor - процессор с названием для EZFactory
+type heartbeatProcessor struct {
+	name   string
+	source HeartbeatSource
+}
+
+// HeartbeatService периодически снимает состояние линии и отправляет его в EZFactory.
+// Снимки сохраняются в базе и отправляются по порядку, поэтому без связи они копятся
+// и уходят после ее восстановления. Буфер ограничен, самые старые снимки отбрасываются
+type HeartbeatService struct {
+	mu            sync.Mutex
+	factoryClient *api.FactoryClient
+	taskService   *TaskService
+	repository    *repository.HeartbeatRepository
+	scanEvents    *repository.ScanEventRepository
+	lineID        int
+	version       string
+	interval      time.Duration
+	bufferLimit   int
+	processors    []heartbeatProcessor
+	devices       []DeviceHealthSource // Оборудование, общее для процессоров
+	offline       bool                 // Последняя отправка не удалась
+}
+
+// NewHeartbeatService создает сервис состояния линии
+func NewHeartbeatService(factoryClient *api.FactoryClient, taskService *TaskService, lineID int, version string, interval time.Duration, bufferLimit int) *HeartbeatService {
+	return &HeartbeatService{
+		factoryClient: factoryClient,
+		taskService:   taskService,
+		repository:    repository.NewHeartbeatRepository(),
+		scanEvents:    repository.NewScanEventRepository(),
+		lineID:        lineID,
+		version:       version,
+		interval:      interval,
+		bufferLimit:   bufferLimit,
+	}
+}
+
+// AddProcessor добавляет процессор, состояние которого передается в EZFactory
+func (s *HeartbeatService) AddProcessor(name string, source HeartbeatSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.processors = append(s.processors, heartbeatProcessor{name: name, source: source})
+}
+
+// AddDevice добавляет оборудование, общее для процессоров, например принтер этикеток
+func (s *HeartbeatService) AddDevice(device DeviceHealthSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.devices = append(s.devices, device)
+}
+
+// Run снимает и отправляет состояние линии до отмены контекста
+func (s *HeartbeatService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick сохраняет новый снимок и отправляет буфер
+func (s *HeartbeatService) tick(ctx context.Context) {
+	heartbeat, err := s.Snapshot()
+	if err != nil {
+		log.Printf("Ошибка снятия состояния линии: %v", err)
+		return
+	}
+
+	if err := s.repository.Save(heartbeat, s.bufferLimit); err != nil {
+		log.Printf("Ошибка сохранения состояния линии: %v", err)
+		return
+	}
+
+	if err := s.flush(ctx); err != nil {
+		if !s.offline {
+			log.Printf("Состояние линии не отправлено в EZFactory, снимки сохраняются до восстановления связи: %v", err)
+		}
+		s.offline = true
+		return
+	}
+
+	if s.offline {
+		log.Printf("Связь с EZFactory восстановлена, накопленные снимки состояния линии отправлены")
+	}
+	s.offline = false
+}
+
+// flush отправляет накопленные снимки частями, начиная с самых старых
+func (s *HeartbeatService) flush(ctx context.Context) error {
+	op := "services.HeartbeatService.flush"
+
+	for {
+		heartbeats, lastID, err := s.repository.GetOldest(heartbeatBatchSize)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if len(heartbeats) == 0 {
+			return nil
+		}
+
+		// Часть определяется первым и последним снимком, при повторе ключ тот же
+		key := heartbeats[0].ID + "-" + heartbeats[len(heartbeats)-1].ID
+		if err := s.factoryClient.SendHeartbeats(ctx, s.lineID, heartbeats, key); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := s.repository.DeleteUpTo(lastID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+}
+
+// Snapshot снимает текущее состояние линии
+func (s *HeartbeatService) Snapshot() (models.LineHeartbeat, error) {
+	op := "services.HeartbeatService.Snapshot"
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return models.LineHeartbeat{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	heartbeat := models.LineHeartbeat{
+		ID:           hex.EncodeToString(id),
+		LineID:       s.lineID,
+		At:           time.Now(),
+		Version:      s.version,
+		ActiveTaskID: s.taskService.GetActiveTaskID(),
+	}
+
+	s.mu.Lock()
+	processors := append([]heartbeatProcessor(nil), s.processors...)
+	devices := append([]DeviceHealthSource(nil), s.devices...)
+	s.mu.Unlock()
+
+	for _, processor := range processors {
+		state := processor.source.State()
+		heartbeat.Processors = append(heartbeat.Processors, models.ProcessorHeartbeat{
+			Name:   processor.name,
+			State:  state.State,
+			Reason: state.Reason,
+			Since:  state.At,
+		})
+
+		if cameras, ok := processor.source.(cameraStatusSource); ok {
+			for _, camera := range cameras.CameraStatuses() {
+				heartbeat.Devices = append(heartbeat.Devices, models.DeviceHealth{
+					Name:   camera.Name,
+					OK:     !camera.LastMissed,
+					Error:  camera.LastError,
+					LastAt: camera.LastAt,
+				})
+			}
+		}
+
+		if reporter, ok := processor.source.(deviceHealthSource); ok {
+			heartbeat.Devices = append(heartbeat.Devices, reporter.DeviceHealth()...)
+		}
+	}
+
+	for _, device := range devices {
+		heartbeat.Devices = append(heartbeat.Devices, device.Health())
+	}
+
+	if heartbeat.ActiveTaskID != 0 {
+		progress, err := s.taskService.GetPlanProgress(heartbeat.ActiveTaskID)
+		if err != nil {
+			return models.LineHeartbeat{}, fmt.Errorf("%s: %w", op, err)
+		}
+		heartbeat.Counters.Boxes = progress.Boxes
+		heartbeat.Counters.Items = progress.Quantity
+
+		// Отклонения считаются по журналу всего задания, а не с запуска процессоров
+		byReason, err := s.scanEvents.CountScanEventsByReason(heartbeat.ActiveTaskID)
+		if err != nil {
+			return models.LineHeartbeat{}, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, count := range byReason {
+			heartbeat.Counters.Rejects += count
+		}
+	}
+
+	return heartbeat, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+)
+
+// heartbeatLine - процессор линии, который ведет диагностику ПЛК
+type heartbeatLine struct {
+	plc models.DeviceHealth
+}
+
+func (l heartbeatLine) State() models.StateChange {
+	return models.StateChange{State: models.ProcessorStateRunning}
+}
+
+func (l heartbeatLine) DeviceHealth() []models.DeviceHealth {
+	return []models.DeviceHealth{l.plc}
+}
+
+// heartbeatDevice - общее оборудование с заданным состоянием
+type heartbeatDevice models.DeviceHealth
+
+func (d heartbeatDevice) Health() models.DeviceHealth {
+	return models.DeviceHealth(d)
+}
+
+func TestHeartbeatServiceSnapshot(t *testing.T) {
+	setupDB(t)
+	_, client := newTestFactory(t)
+
+	outbox := NewOutboxSender(time.Second, 20)
+	taskService := NewTaskService(client, outbox, nil, 1)
+	if err := repository.NewActiveTaskRepository().SaveActiveTask(1, models.TaskPlan{}); err != nil {
+		t.Fatalf("SaveActiveTask: %v", err)
+	}
+	taskService.activeTaskID = 1
+
+	// Журнал задания 1 до перезапуска процессоров и отклонение другого задания
+	events := repository.NewScanEventRepository()
+	for _, event := range []models.ScanEvent{
+		{TaskID: 1, Outcome: models.ScanOutcomeAccepted},
+		{TaskID: 1, Outcome: models.ScanOutcomeRejected, Reason: models.ScanReasonNoRead},
+		{TaskID: 1, Outcome: models.ScanOutcomeRejected, Reason: models.ScanReasonNotUnique},
+		{TaskID: 2, Outcome: models.ScanOutcomeRejected, Reason: models.ScanReasonNoRead},
+	} {
+		if _, err := events.CreateScanEvent(event); err != nil {
+			t.Fatalf("CreateScanEvent: %v", err)
+		}
+	}
+
+	plc := models.DeviceHealth{Name: "ПЛК", OK: true, LastAt: time.Now()}
+	printer := models.DeviceHealth{Name: "Принтер этикеток", Error: "нет подключения"}
+
+	heartbeats := NewHeartbeatService(client, taskService, 1, "test", time.Second, 10)
+	heartbeats.AddProcessor("line", heartbeatLine{plc: plc})
+	heartbeats.AddDevice(heartbeatDevice(printer))
+
+	heartbeat, err := heartbeats.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	if heartbeat.ActiveTaskID != 1 || heartbeat.Counters.Rejects != 2 {
+		t.Errorf("задание %d, отклонено %d, ожидалось задание 1 и 2 отклонения",
+			heartbeat.ActiveTaskID, heartbeat.Counters.Rejects)
+	}
+
+	tests := []struct {
+		name   string
+		device models.DeviceHealth
+	}{
+		{"ПЛК процессора", plc},
+		{"общий принтер", printer},
+	}
+	if len(heartbeat.Devices) != len(tests) {
+		t.Fatalf("оборудование = %+v", heartbeat.Devices)
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := heartbeat.Devices[i]
+			if got.Name != tt.device.Name || got.OK != tt.device.OK || got.Error != tt.device.Error {
+				t.Errorf("состояние = %+v, ожидалось %+v", got, tt.device)
+			}
+		})
+	}
+}
+
+func TestHeartbeatServiceBuffersWhileOffline(t *testing.T) {
+	tests := []struct {
+		name         string
+		limit        int
+		ticks        []bool // Доступность EZFactory на каждом такте
+		wantBuffered int    // Снимков в буфере после всех тактов
+		wantReceived int    // Снимков, полученных EZFactory
+	}{
+		{"связь есть", 10, []bool{true, true, true}, 0, 3},
+		{"связи нет", 10, []bool{false, false, false}, 3, 0},
+		{"связь восстановлена", 10, []bool{false, false, true}, 0, 3},
+		{"буфер переполнен", 3, []bool{false, false, false, false, false}, 3, 0},
+		{"отправка после переполнения", 3, []bool{false, false, false, false, false, true}, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+			factory, client := newTestFactory(t)
+
+			taskService := NewTaskService(client, NewOutboxSender(time.Second, 20), nil, 1)
+			heartbeats := NewHeartbeatService(client, taskService, 1, "test", time.Second, tt.limit)
+
+			var saved []string // ID снимков по порядку снятия
+			for _, online := range tt.ticks {
+				factory.down.Store(!online)
+				heartbeats.tick(context.Background())
+
+				buffered, _, err := heartbeats.repository.GetOldest(tt.limit + 1)
+				if err != nil {
+					t.Fatalf("GetOldest: %v", err)
+				}
+				if len(buffered) > tt.limit {
+					t.Fatalf("в буфере %d снимков при ограничении %d", len(buffered), tt.limit)
+				}
+				if len(buffered) > 0 {
+					saved = append(saved, buffered[len(buffered)-1].ID)
+				} else if received := factory.Heartbeats(); len(received) > 0 {
+					saved = append(saved, received[len(received)-1].ID)
+				}
+			}
+
+			count, err := heartbeats.repository.Count()
+			if err != nil {
+				t.Fatalf("Count: %v", err)
+			}
+			if count != tt.wantBuffered {
+				t.Errorf("в буфере %d снимков, ожидалось %d", count, tt.wantBuffered)
+			}
+
+			var received []string
+			for _, heartbeat := range factory.Heartbeats() {
+				received = append(received, heartbeat.ID)
+			}
+			// EZFactory получает самые новые снимки в порядке снятия
+			if want := saved[len(saved)-tt.wantReceived:]; !slices.Equal(received, want) {
+				t.Errorf("получены снимки %v, ожидались %v", received, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// internal/services/import_service.go
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/markingcode"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+)
+
+// ErrUnknownTask возвращается при импорте в задание, которого нет среди полученных линией из EZFactory
+var ErrUnknownTask = errors.New("задание не найдено среди заданий линии")
+
+// ImportService переносит журналы агрегации старого формата в контейнеры и товары задания
+type ImportService struct {
+	containerRepository  *repository.ContainerRepository
+	itemRepository       *repository.ItemRepository
+	activeTaskRepository *repository.ActiveTaskRepository
+	cache                *repository.FactoryCacheRepository // Задания, полученные линией из EZFactory
+}
+
+// NewImportService создает сервис импорта журналов агрегации
+func NewImportService() *ImportService {
+	return &ImportService{
+		containerRepository:  repository.NewContainerRepository(),
+		itemRepository:       repository.NewItemRepository(),
+		activeTaskRepository: repository.NewActiveTaskRepository(),
+		cache:                repository.NewFactoryCacheRepository(),
+	}
+}
+
+// UseSSCC задает префикс SSCC, присваиваемого импортированным коробам
+func (s *ImportService) UseSSCC(prefix markingcode.SSCCPrefix) {
+	s.containerRepository.UseSSCC(prefix)
+}
+
+// ImportLegacyBoxes проверяет короба журналов на повторы и сохраняет их в задание.
+// Повторы внутри импорта и коды, уже сохраненные в базе, пропускаются и возвращаются как проблемы.
+// Короб, у которого не осталось новых товаров, пропускается целиком.
+// При dryRun база не меняется, а результат показывает, что было бы импортировано.
+// Задание должно быть известно линии и не выбрано на ней: серийные номера коробов выбранного
+// задания процессоры выдают из памяти, и импортированные короба получили бы те же номера
+func (s *ImportService) ImportLegacyBoxes(taskID int, boxes []models.LegacyBox, dryRun bool) (models.ImportResult, error) {
+	op := "services.ImportService.ImportLegacyBoxes"
+
+	result := models.ImportResult{DryRun: dryRun}
+
+	if _, _, err := s.cache.GetTask(taskID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return result, fmt.Errorf("%s: %w: %d", op, ErrUnknownTask, taskID)
+		}
+		return result, fmt.Errorf("%s: %w", op, err)
+	}
+
+	activeTaskID, err := s.activeTaskRepository.GetActiveTask()
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", op, err)
+	}
+	if activeTaskID == taskID {
+		return result, fmt.Errorf("%s: %w: %d, приостановите или завершите его", op, repository.ErrTaskActive, taskID)
+	}
+
+	// Где код встретился впервые: файл и строка
+	seenBoxes := make(map[string]string)
+	seenItems := make(map[string]string)
+	var accepted []models.LegacyBox
+
+	for _, box := range boxes {
+		problem := func(line int, code, message string) {
+			result.Problems = append(result.Problems, models.ImportProblem{File: box.File, Line: line, Code: code, Message: message})
+		}
+
+		if first, ok := seenBoxes[box.Code]; ok {
+			problem(box.Line, box.Code, fmt.Sprintf("повтор короба (%s), короб пропущен", first))
+			continue
+		}
+		seenBoxes[box.Code] = fmt.Sprintf("%s:%d", box.File, box.Line)
+
+		existing, err := s.containerRepository.GetContainerByCode(box.Code)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return result, fmt.Errorf("%s: %w", op, err)
+		}
+		if existing != nil {
+			problem(box.Line, box.Code, fmt.Sprintf("короб уже есть в базе (задание %d), короб пропущен", existing.TaskID))
+			continue
+		}
+
+		items := make([]models.LegacyItem, 0, len(box.Items))
+		for _, item := range box.Items {
+			if first, ok := seenItems[item.Code]; ok {
+				problem(item.Line, item.Code, fmt.Sprintf("повтор кода товара (%s)", first))
+				continue
+			}
+			seenItems[item.Code] = fmt.Sprintf("%s:%d", box.File, item.Line)
+
+			existing, err := s.itemRepository.GetItemByCode(item.Code)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return result, fmt.Errorf("%s: %w", op, err)
+			}
+			if existing != nil {
+				problem(item.Line, item.Code, fmt.Sprintf("код товара уже есть в базе (задание %d)", existing.TaskID))
+				continue
+			}
+
+			items = append(items, item)
+		}
+
+		if len(items) == 0 {
+			problem(box.Line, box.Code, "в коробе нет новых товаров, короб пропущен")
+			continue
+		}
+
+		box.Items = items
+		accepted = append(accepted, box)
+		result.Boxes++
+		result.Items += len(items)
+	}
+
+	if dryRun || len(accepted) == 0 {
+		return result, nil
+	}
+
+	if err := s.containerRepository.ImportLegacyBoxes(taskID, accepted); err != nil {
+		return result, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+)
+
+func TestImportServiceChecksTargetTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		taskID  int
+		dryRun  bool
+		wantErr error
+	}{
+		{"задание линии", 2, false, nil},
+		{"проверка задания линии", 2, true, nil},
+		{"неизвестное задание", 5, false, ErrUnknownTask},
+		{"неизвестное задание при проверке", 5, true, ErrUnknownTask},
+		{"задание, выбранное на линии", 1, false, repository.ErrTaskActive},
+		{"проверка задания, выбранного на линии", 1, true, repository.ErrTaskActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDB(t)
+
+			cache := repository.NewFactoryCacheRepository()
+			for _, task := range []models.Task{{ID: 1, LineID: 1, ProductID: 1}, {ID: 2, LineID: 1, ProductID: 1}} {
+				if err := cache.SaveTask(task); err != nil {
+					t.Fatalf("SaveTask: %v", err)
+				}
+			}
+			if err := repository.NewActiveTaskRepository().SaveActiveTask(1, models.TaskPlan{}); err != nil {
+				t.Fatalf("SaveActiveTask: %v", err)
+			}
+
+			boxes := []models.LegacyBox{{
+				Code:     "4607054761244310320254150001",
+				ClosedAt: time.Now(),
+				File:     "input.txt",
+				Line:     1,
+				Items:    []models.LegacyItem{{Code: markingCode("AAAAA1"), Line: 2}},
+			}}
+
+			result, err := NewImportService().ImportLegacyBoxes(tt.taskID, boxes, tt.dryRun)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ошибка = %v, ожидалась %v", err, tt.wantErr)
+			}
+
+			imported, err := repository.NewContainerRepository().CountByTaskID(tt.taskID)
+			if err != nil {
+				t.Fatalf("CountByTaskID: %v", err)
+			}
+			want := 0
+			if tt.wantErr == nil && !tt.dryRun {
+				want = 1
+			}
+			if imported != want {
+				t.Errorf("импортировано коробов %d, ожидалось %d (результат %+v)", imported, want, result)
+			}
+		})
+	}
+}
+
+func TestImportServiceContinuesTaskSerials(t *testing.T) {
+	setupDB(t)
+
+	if err := repository.NewFactoryCacheRepository().SaveTask(models.Task{ID: 2, LineID: 1, ProductID: 1}); err != nil {
+		t.Fatalf("SaveTask: %v", err)
+	}
+
+	containers := repository.NewContainerRepository()
+	if _, err := containers.CreateContainerWithItems("046070547612440000007", 7, 2, models.PendingBoxStationLine, []string{markingCode("AAAAA1")}); err != nil {
+		t.Fatalf("CreateContainerWithItems: %v", err)
+	}
+
+	boxes := []models.LegacyBox{{
+		Code:     "4607054761244310320254150001",
+		ClosedAt: time.Now(),
+		File:     "input.txt",
+		Line:     1,
+		Items:    []models.LegacyItem{{Code: markingCode("AAAAA2"), Line: 2}},
+	}}
+	if _, err := NewImportService().ImportLegacyBoxes(2, boxes, false); err != nil {
+		t.Fatalf("ImportLegacyBoxes: %v", err)
+	}
+
+	last, err := containers.GetLastSerialNumber(2)
+	if err != nil {
+		t.Fatalf("GetLastSerialNumber: %v", err)
+	}
+	if last != 8 {
+		t.Errorf("последний серийный номер %d, ожидался 8", last)
+	}
+}
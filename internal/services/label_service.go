@@ -123,6 +123,17 @@ func (s *LabelService) GetPacker() string {
 
 // PrintLabel - удобный метод для печати с использованием Builder
 func (s *LabelService) PrintLabel(task *models.Task, product *models.Product, serialNumber string) error {
+	return s.PrintLabelData(s.BuildLabelData(task, product, serialNumber, 0))
+}
+
+// PrintLabelWithQuantity печатает этикетку неполного короба с фактическим количеством и весом
+func (s *LabelService) PrintLabelWithQuantity(task *models.Task, product *models.Product, serialNumber string, quantity int) error {
+	return s.PrintLabelData(s.BuildLabelData(task, product, serialNumber, quantity))
+}
+
+// BuildLabelData собирает данные этикетки короба.
+// Если quantity больше нуля, количество и вес берутся фактические, а не из карточки продукта
+func (s *LabelService) BuildLabelData(task *models.Task, product *models.Product, serialNumber string, quantity int) models.LabelData {
 	// Создаем билдер
 	labelBuilder := models.NewLabelBuilder()
 
@@ -132,9 +143,15 @@ func (s *LabelService) PrintLabel(task *models.Task, product *models.Product, se
 	labelBuilder.WithPacker(s.GetPacker())
 	labelBuilder.WithSerialNumber(serialNumber)
 
+	if quantity > 0 {
+		labelBuilder.WithQuantity(quantity)
+	}
+
 	// Собираем этикетку
-	labelData := labelBuilder.Build()
+	return labelBuilder.Build()
+}
 
-	// Рендерим и печатаем
+// PrintLabelData печатает собранную этикетку по стандартному шаблону
+func (s *LabelService) PrintLabelData(labelData models.LabelData) error {
 	return s.RenderAndPrint(labelData, "standard.txt")
 }
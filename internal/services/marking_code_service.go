@@ -0,0 +1,232 @@
+// internal/services/marking_code_service.go
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/api"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/internal/validator"
+	"log"
+	"sync"
+)
+
+// Количество кодов на странице загрузки по умолчанию
+const defaultCodePageSize = 1000
+
+// MarkingCodeService загружает из EZFactory коды маркировки, выданные для задания, и отчитывается об их использовании.
+// Коды сохраняются в базе постранично вместе с курсором, поэтому прерванная загрузка продолжается с места остановки
+type MarkingCodeService struct {
+	mu            sync.Mutex
+	factoryClient *api.FactoryClient
+	outbox        *OutboxSender
+	repository    *repository.MarkingCodeRepository
+	pageSize      int
+	serialLength  int          // Длина серийного номера в кодах без разделителей GS
+	downloading   map[int]bool // Задания, коды которых загружаются сейчас
+}
+
+// NewMarkingCodeService создает сервис кодов маркировки и регистрирует отправку отчетов в очереди.
+// serialLength нужен, чтобы сравнивать коды без разделителей GS с кодами пула
+func NewMarkingCodeService(factoryClient *api.FactoryClient, outbox *OutboxSender, pageSize int, serialLength int) *MarkingCodeService {
+	if pageSize <= 0 {
+		pageSize = defaultCodePageSize
+	}
+
+	s := &MarkingCodeService{
+		factoryClient: factoryClient,
+		outbox:        outbox,
+		repository:    repository.NewMarkingCodeRepository(),
+		pageSize:      pageSize,
+		serialLength:  serialLength,
+		downloading:   make(map[int]bool),
+	}
+
+	outbox.Handle(models.OutboxKindCodeReport, s.sendReport)
+
+	return s
+}
+
+// DownloadAsync запускает загрузку кодов задания в фоне
+func (s *MarkingCodeService) DownloadAsync(taskID int) {
+	go func() {
+		if _, err := s.Download(context.Background(), taskID); err != nil {
+			log.Printf("Ошибка загрузки кодов задания %d: %v", taskID, err)
+		}
+	}()
+}
+
+// Download загружает коды задания постранично, продолжая с сохраненного курсора.
+// Если коды задания уже загружаются, возвращает текущий ход загрузки
+func (s *MarkingCodeService) Download(ctx context.Context, taskID int) (models.CodeDownload, error) {
+	op := "services.MarkingCodeService.Download"
+
+	s.mu.Lock()
+	if s.downloading[taskID] {
+		s.mu.Unlock()
+		download, _, err := s.GetDownload(taskID)
+		return download, err
+	}
+	s.downloading[taskID] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.downloading, taskID)
+		s.mu.Unlock()
+	}()
+
+	download, _, err := s.GetDownload(taskID)
+	if err != nil {
+		return download, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for !download.Completed {
+		page, err := s.factoryClient.GetTaskCodes(ctx, taskID, download.Cursor, s.pageSize)
+		if err != nil {
+			if saveErr := s.repository.SaveDownloadError(taskID, err); saveErr != nil {
+				log.Printf("Ошибка сохранения хода загрузки кодов задания %d: %v", taskID, saveErr)
+			}
+			return download, fmt.Errorf("%s: %w", op, err)
+		}
+
+		download, err = s.repository.SavePage(taskID, page)
+		if err != nil {
+			return download, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Printf("Коды задания %d загружены: %d", taskID, download.Downloaded)
+	return download, nil
+}
+
+// GetDownload возвращает ход загрузки кодов задания. Второе значение false, если загрузка не начиналась
+func (s *MarkingCodeService) GetDownload(taskID int) (models.CodeDownload, bool, error) {
+	download, err := s.repository.GetDownload(taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.CodeDownload{TaskID: taskID}, false, nil
+	}
+	if err != nil {
+		return download, false, err
+	}
+
+	return download, true, nil
+}
+
+// IsDownloading сообщает, загружаются ли сейчас коды задания
+func (s *MarkingCodeService) IsDownloading(taskID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.downloading[taskID]
+}
+
+// GetUsage возвращает количество кодов задания по статусам
+func (s *MarkingCodeService) GetUsage(taskID int) (models.CodeUsage, error) {
+	return s.repository.GetUsage(taskID)
+}
+
+// Pool возвращает коды, выданные для задания, для проверки принадлежности кода заданию.
+// Если EZFactory не выдал кодов, возвращает nil: задание работает с пулом на внешнем носителе.
+// Пока загрузка не завершена, тоже возвращает nil: по неполному пулу были бы отбракованы коды
+// из незагруженных страниц, а линия должна работать и без связи с EZFactory
+func (s *MarkingCodeService) Pool(taskID int) (*validator.CodePool, error) {
+	download, started, err := s.GetDownload(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !started || download.Downloaded == 0 {
+		return nil, nil
+	}
+
+	if !download.Completed {
+		log.Printf("Коды задания %d загружены не полностью (%d), принадлежность кодов заданию не проверяется", taskID, download.Downloaded)
+		return nil, nil
+	}
+
+	codes, err := s.repository.GetPool(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	return validator.NewCodePool(codes, s.serialLength), nil
+}
+
+// PoolWarning возвращает предупреждение, если коды задания загружены не полностью и не проверяются по пулу
+func (s *MarkingCodeService) PoolWarning(taskID int) string {
+	download, started, err := s.GetDownload(taskID)
+	if err != nil || !started || download.Downloaded == 0 || download.Completed {
+		return ""
+	}
+
+	return fmt.Sprintf("Коды маркировки задания загружены не полностью (%d). Принадлежность кодов заданию не проверяется: "+
+		"после загрузки перезапустите линию.", download.Downloaded)
+}
+
+// FinishTask готовит сообщение с отчетом об использовании кодов завершенного задания.
+// Коды распределяются по статусам ключом CodeKey в транзакции, которая завершает задание и ставит отчет в очередь
+func (s *MarkingCodeService) FinishTask(taskID int) ([]models.OutboxMessage, error) {
+	op := "services.MarkingCodeService.FinishTask"
+
+	usage, err := s.repository.GetUsage(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Коды задания не загружались из EZFactory, отчитываться не о чем
+	if usage.Total() == 0 {
+		return nil, nil
+	}
+
+	message, err := s.outbox.Prepare(models.OutboxKindCodeReport, taskID, models.CodeReportPayload{TaskID: taskID})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return []models.OutboxMessage{message}, nil
+}
+
+// CodeKey возвращает ключ сравнения выданного кода с отсканированным: код идентификации без криптохвоста
+func (s *MarkingCodeService) CodeKey(code string) string {
+	return validator.NormalizeCode(code, s.serialLength)
+}
+
+// logUsage записывает в журнал распределение кодов завершенного задания
+func (s *MarkingCodeService) logUsage(taskID int) {
+	usage, err := s.repository.GetUsage(taskID)
+	if err != nil {
+		log.Printf("Ошибка получения использования кодов задания %d: %v", taskID, err)
+		return
+	}
+
+	log.Printf("Коды задания %d: использовано %d, испорчено %d, возвращено %d",
+		taskID, usage.Consumed, usage.Spoiled, usage.Returned)
+}
+
+// sendReport отправляет в EZFactory отчет об использовании кодов задания из очереди
+func (s *MarkingCodeService) sendReport(ctx context.Context, message models.OutboxMessage) error {
+	var payload models.CodeReportPayload
+	if err := json.Unmarshal([]byte(message.Payload), &payload); err != nil {
+		return fmt.Errorf("ошибка разбора сообщения: %w", err)
+	}
+
+	report := models.CodeUsageReport{TaskID: payload.TaskID}
+
+	var err error
+	if report.Consumed, err = s.repository.GetCodes(payload.TaskID, models.MarkingCodeStatusConsumed); err != nil {
+		return err
+	}
+	if report.Spoiled, err = s.repository.GetCodes(payload.TaskID, models.MarkingCodeStatusSpoiled); err != nil {
+		return err
+	}
+	if report.Returned, err = s.repository.GetCodes(payload.TaskID, models.MarkingCodeStatusReturned); err != nil {
+		return err
+	}
+
+	return s.factoryClient.ReportCodeUsage(ctx, report, message.IdempotencyKey)
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+)
+
+const testCodeTail = "\x1d91EE10\x1d92dGVzdA=="
+
+func TestMarkingCodeServicePoolWhileDownloadIncomplete(t *testing.T) {
+	setupDB(t)
+	_, client := newTestFactory(t)
+
+	outbox := NewOutboxSender(time.Second, 20)
+	codes := NewMarkingCodeService(client, outbox, 2, 9)
+
+	_, err := repository.NewMarkingCodeRepository().SavePage(1, models.CodePage{
+		Codes:      []string{"010460705476616421AAAAAAAA1" + testCodeTail},
+		NextCursor: "1",
+	})
+	if err != nil {
+		t.Fatalf("SavePage: %v", err)
+	}
+
+	// Неполный пул не блокирует запуск линии и не отбраковывает коды незагруженных страниц
+	pool, err := codes.Pool(1)
+	if err != nil {
+		t.Fatalf("Pool: %v", err)
+	}
+	if pool != nil {
+		t.Errorf("пул неполной загрузки = %d кодов, ожидался nil", pool.Len())
+	}
+	if codes.PoolWarning(1) == "" {
+		t.Error("нет предупреждения о неполной загрузке кодов")
+	}
+}
+
+func TestMarkingCodeServiceFinishTaskMatchesScannedCodes(t *testing.T) {
+	setupDB(t)
+	factory, client := newTestFactory(t)
+
+	outbox := NewOutboxSender(time.Second, 20)
+	codes := NewMarkingCodeService(client, outbox, 100, 9)
+
+	const (
+		used     = "010460705476616421AAAAAAAA1"
+		removed  = "010460705476616421AAAAAAAA2"
+		returned = "010460705476616421AAAAAAAA3"
+	)
+
+	_, err := repository.NewMarkingCodeRepository().SavePage(1, models.CodePage{
+		Codes: []string{used + testCodeTail, removed + testCodeTail, returned + testCodeTail},
+	})
+	if err != nil {
+		t.Fatalf("SavePage: %v", err)
+	}
+
+	pool, err := codes.Pool(1)
+	if err != nil {
+		t.Fatalf("Pool: %v", err)
+	}
+	if pool == nil || !pool.Contains(used) {
+		t.Fatal("код без криптохвоста не найден в пуле")
+	}
+	if codes.PoolWarning(1) != "" {
+		t.Errorf("предупреждение после полной загрузки: %s", codes.PoolWarning(1))
+	}
+
+	// Сканер прочитал коды без криптохвоста, один товар удален из короба
+	containers := repository.NewContainerRepository()
+	id, err := containers.CreateContainerWithItems("box-1", 1, 1, models.PendingBoxStationLine, []string{used, removed})
+	if err != nil {
+		t.Fatalf("CreateContainerWithItems: %v", err)
+	}
+	container, err := containers.GetContainerByID(id)
+	if err != nil {
+		t.Fatalf("GetContainerByID: %v", err)
+	}
+	item, err := repository.NewItemRepository().GetItemByCode(removed)
+	if err != nil {
+		t.Fatalf("GetItemByCode: %v", err)
+	}
+	if err := containers.RemoveItem(container, item, "брак"); err != nil {
+		t.Fatalf("RemoveItem: %v", err)
+	}
+
+	messages, err := codes.FinishTask(1)
+	if err != nil {
+		t.Fatalf("FinishTask: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Kind != models.OutboxKindCodeReport {
+		t.Fatalf("сообщения = %+v, ожидался отчет по кодам", messages)
+	}
+
+	// Коды распределяются только вместе с освобождением задания: при ошибке транзакции они остаются выданными
+	activeTasks := repository.NewActiveTaskRepository()
+	if err := activeTasks.SaveActiveTask(1, models.TaskPlan{}); err != nil {
+		t.Fatalf("SaveActiveTask: %v", err)
+	}
+	duplicate := []models.OutboxMessage{messages[0], messages[0]}
+	if err := activeTasks.ReleaseActiveTask(1, codes.CodeKey, duplicate); err == nil {
+		t.Fatal("ReleaseActiveTask с повторяющимся ключом идемпотентности завершился без ошибки")
+	}
+	usage, err := codes.GetUsage(1)
+	if err != nil {
+		t.Fatalf("GetUsage: %v", err)
+	}
+	if usage.Issued != 3 {
+		t.Errorf("использование кодов после отката = %+v, ожидалось 3 выданных", usage)
+	}
+	if taskID, _ := activeTasks.GetActiveTask(); taskID != 1 {
+		t.Errorf("активное задание после отката = %d, ожидалось 1", taskID)
+	}
+
+	if err := activeTasks.ReleaseActiveTask(1, codes.CodeKey, messages); err != nil {
+		t.Fatalf("ReleaseActiveTask: %v", err)
+	}
+
+	usage, err = codes.GetUsage(1)
+	if err != nil {
+		t.Fatalf("GetUsage: %v", err)
+	}
+	if usage.Consumed != 1 || usage.Spoiled != 1 || usage.Returned != 1 || usage.Issued != 0 {
+		t.Errorf("использование кодов = %+v, ожидалось по одному использованному, испорченному и возвращенному", usage)
+	}
+
+	// Отчет уходит с кодами в том виде, в котором их выдал EZFactory
+	if err := codes.sendReport(context.Background(), messages[0]); err != nil {
+		t.Fatalf("sendReport: %v", err)
+	}
+	reports := factory.CodeReports()
+	if len(reports) != 1 || len(reports[0].Report.Consumed) != 1 || reports[0].Report.Consumed[0] != used+testCodeTail {
+		t.Errorf("отчеты = %+v, ожидался использованный код %q с криптохвостом", reports, used)
+	}
+}
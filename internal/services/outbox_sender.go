@@ -0,0 +1,225 @@
+// internal/services/outbox_sender.go
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ze674/EZLine/internal/api"
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+	"log"
+	"sync"
+	"time"
+)
+
+// Количество сообщений, выбираемых из очереди за один проход
+const outboxBatchSize = 100
+
+// ErrNoOutboxHandler возвращается при отправке сообщения, для типа которого не зарегистрирован обработчик
+var ErrNoOutboxHandler = errors.New("нет обработчика для сообщений этого типа")
+
+// OutboxHandler отправляет в EZFactory сообщение одного типа
+type OutboxHandler func(ctx context.Context, message models.OutboxMessage) error
+
+// OutboxSender отправляет исходящие вызовы EZFactory в фоне.
+// Сообщения сохраняются в базе и отправляются строго по порядку: при ошибке отправка
+// останавливается и повторяется позже, чтобы, например, статус "завершено" не обогнал "в работе".
+// Сообщение, которое EZFactory не примет и при повторе, переносится в неотправленные,
+// чтобы не задерживать другие задания; следующие сообщения его задания ждут, пока оператор
+// не вернет его в очередь, иначе после возврата старый статус затер бы новый
+type OutboxSender struct {
+	mu          sync.Mutex
+	repository  *repository.OutboxRepository
+	handlers    map[string]OutboxHandler
+	retryDelay  time.Duration
+	maxAttempts int // Попыток до переноса в неотправленные, 0 - без ограничения
+	wake        chan struct{}
+}
+
+// NewOutboxSender создает отправителя исходящих сообщений
+func NewOutboxSender(retryDelay time.Duration, maxAttempts int) *OutboxSender {
+	return &OutboxSender{
+		repository:  repository.NewOutboxRepository(),
+		handlers:    make(map[string]OutboxHandler),
+		retryDelay:  retryDelay,
+		maxAttempts: maxAttempts,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Handle регистрирует обработчик для типа сообщений
+func (s *OutboxSender) Handle(kind string, handler OutboxHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[kind] = handler
+}
+
+// Enqueue сохраняет сообщение в очередь с новым ключом идемпотентности и будит отправителя
+func (s *OutboxSender) Enqueue(kind string, taskID int, payload any) error {
+	op := "services.OutboxSender.Enqueue"
+
+	message, err := s.Prepare(kind, taskID, payload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.repository.Enqueue(message); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.Notify()
+	return nil
+}
+
+// Prepare формирует сообщение очереди с ключом идемпотентности, не сохраняя его.
+// Используется, когда сообщение сохраняется в одной транзакции с локальными изменениями;
+// после сохранения нужно вызвать Notify
+func (s *OutboxSender) Prepare(kind string, taskID int, payload any) (models.OutboxMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return models.OutboxMessage{}, err
+	}
+
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return models.OutboxMessage{}, err
+	}
+
+	return models.OutboxMessage{Kind: kind, TaskID: taskID, Payload: string(data), IdempotencyKey: key}, nil
+}
+
+// Notify запускает внеочередную отправку
+func (s *OutboxSender) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+		// Отправка уже запрошена
+	}
+}
+
+// Pending возвращает сообщения, ожидающие отправки
+func (s *OutboxSender) Pending() ([]models.OutboxMessage, error) {
+	return s.repository.GetPending(outboxBatchSize)
+}
+
+// CountPending возвращает количество сообщений, ожидающих отправки
+func (s *OutboxSender) CountPending() (int, error) {
+	return s.repository.CountPending()
+}
+
+// Failed возвращает сообщения, перенесенные в неотправленные
+func (s *OutboxSender) Failed() ([]models.OutboxMessage, error) {
+	return s.repository.GetFailed(outboxBatchSize)
+}
+
+// CountFailed возвращает количество неотправленных сообщений
+func (s *OutboxSender) CountFailed() (int, error) {
+	return s.repository.CountFailed()
+}
+
+// Requeue возвращает неотправленное сообщение в очередь и запускает отправку.
+// Сообщение уходит раньше задержанных им сообщений своего задания
+func (s *OutboxSender) Requeue(id int64) error {
+	op := "services.OutboxSender.Requeue"
+
+	if err := s.repository.Requeue(id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.Notify()
+	return nil
+}
+
+// Run отправляет сообщения до отмены контекста
+func (s *OutboxSender) Run(ctx context.Context) {
+	for {
+		if err := s.flush(ctx); err != nil {
+			log.Printf("Ошибка отправки в EZFactory, повтор через %s: %v", s.retryDelay, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(s.retryDelay):
+		}
+	}
+}
+
+// flush отправляет накопленные сообщения по порядку до первой ошибки.
+// Сообщения, которые не будут приняты и при повторе, переносятся в неотправленные
+// и задерживают следующие сообщения своего задания
+func (s *OutboxSender) flush(ctx context.Context) error {
+	messages, err := s.repository.GetDeliverable(outboxBatchSize)
+	if err != nil {
+		return err
+	}
+
+	held := make(map[int]bool) // Задания, сообщение которых перенесено в неотправленные при этой отправке
+	for _, message := range messages {
+		if held[message.TaskID] {
+			continue
+		}
+
+		if err := s.send(ctx, message); err != nil {
+			if s.giveUp(message, err) {
+				log.Printf("Сообщение %d (%s) перенесено в неотправленные: %v", message.ID, message.Kind, err)
+				if markErr := s.repository.MarkDead(message.ID, err); markErr != nil {
+					return markErr
+				}
+				held[message.TaskID] = true
+				continue
+			}
+
+			if markErr := s.repository.MarkFailed(message.ID, err); markErr != nil {
+				return markErr
+			}
+			return fmt.Errorf("сообщение %d (%s): %w", message.ID, message.Kind, err)
+		}
+
+		if err := s.repository.MarkSent(message.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *OutboxSender) send(ctx context.Context, message models.OutboxMessage) error {
+	s.mu.Lock()
+	handler, ok := s.handlers[message.Kind]
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoOutboxHandler, message.Kind)
+	}
+	return handler(ctx, message)
+}
+
+// giveUp сообщает, что сообщение нужно перенести в неотправленные.
+// Отклоненное EZFactory сообщение не будет принято и при повторе. Недоступность EZFactory
+// и остановка отправителя попытки не ограничивают: без связи линия работает сколько угодно долго
+func (s *OutboxSender) giveUp(message models.OutboxMessage, err error) bool {
+	switch {
+	case errors.Is(err, api.ErrRejected), errors.Is(err, api.ErrNotFound), errors.Is(err, ErrNoOutboxHandler):
+		return true
+	case api.IsUnavailable(err), errors.Is(err, context.Canceled):
+		return false
+	default:
+		return s.maxAttempts > 0 && message.Attempts+1 >= s.maxAttempts
+	}
+}
+
+// newIdempotencyKey генерирует случайный ключ идемпотентности
+func newIdempotencyKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
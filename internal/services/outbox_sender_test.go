@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ze674/EZLine/internal/api"
+	"github.com/ze674/EZLine/internal/database"
+	"github.com/ze674/EZLine/internal/mockfactory"
+	"github.com/ze674/EZLine/internal/models"
+)
+
+// TestMain переходит в корень репозитория: миграции базы ищутся относительно рабочего каталога
+func TestMain(m *testing.M) {
+	if err := os.Chdir(filepath.Join("..", "..")); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// setupDB создает пустую базу во временном каталоге. Репозитории запоминают соединение
+// при создании, поэтому сервисы создаются после setupDB
+func setupDB(t *testing.T) {
+	t.Helper()
+
+	if err := database.Connect(filepath.Join(t.TempDir(), "ezline.db")); err != nil {
+		t.Fatalf("database.Connect: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+}
+
+// testFactory - имитация EZFactory, которую можно сделать недоступной
+type testFactory struct {
+	*mockfactory.Server
+	down atomic.Bool // EZFactory отвечает 503 на все запросы
+}
+
+func (f *testFactory) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.down.Load() {
+			http.Error(w, "недоступен", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newTestFactory запускает имитацию EZFactory с заданиями 1 и 2 продукта 1 и клиент к ней без повторов
+func newTestFactory(t *testing.T) (*testFactory, *api.FactoryClient) {
+	t.Helper()
+
+	factory := &testFactory{Server: mockfactory.NewServer(mockfactory.Fixture{
+		Tasks: []models.Task{
+			{ID: 1, LineID: 1, ProductID: 1},
+			{ID: 2, LineID: 1, ProductID: 1},
+		},
+		Products: []models.Product{{ID: 1, GTIN: "04607054766164"}},
+	})}
+
+	server := httptest.NewServer(factory.middleware(factory.Handler()))
+	t.Cleanup(server.Close)
+
+	client := api.NewFactoryClient(server.URL)
+	client.MaxRetries = 0
+	client.RetryDelay = time.Millisecond
+	return factory, client
+}
+
+// statuses возвращает статусы, полученные имитацией EZFactory, в порядке получения
+func statuses(factory *testFactory) []string {
+	var result []string
+	for _, change := range factory.StatusChanges() {
+		result = append(result, change.Status)
+	}
+	return result
+}
+
+func enqueueStatus(t *testing.T, outbox *OutboxSender, taskID int, status string) {
+	t.Helper()
+
+	err := outbox.Enqueue(models.OutboxKindTaskStatus, taskID, models.TaskStatusPayload{TaskID: taskID, Status: status})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+}
+
+func TestOutboxSenderDeliversInOrder(t *testing.T) {
+	setupDB(t)
+	factory, client := newTestFactory(t)
+
+	outbox := NewOutboxSender(time.Second, 3)
+	NewTaskService(client, outbox, nil, 1)
+
+	enqueueStatus(t, outbox, 1, models.TaskStatusInProgress)
+	enqueueStatus(t, outbox, 1, models.TaskStatusPaused)
+	enqueueStatus(t, outbox, 1, models.TaskStatusCompleted)
+
+	if err := outbox.flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	got := statuses(factory)
+	want := []string{models.TaskStatusInProgress, models.TaskStatusPaused, models.TaskStatusCompleted}
+	if len(got) != len(want) {
+		t.Fatalf("статусы = %v, ожидалось %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("статусы = %v, ожидалось %v", got, want)
+		}
+	}
+
+	if pending, _ := outbox.CountPending(); pending != 0 {
+		t.Errorf("в очереди осталось %d сообщений", pending)
+	}
+}
+
+func TestOutboxSenderWaitsWhileFactoryUnavailable(t *testing.T) {
+	setupDB(t)
+	factory, client := newTestFactory(t)
+
+	// Даже одна неудачная попытка не переносит сообщение в неотправленные, пока EZFactory недоступен
+	outbox := NewOutboxSender(time.Second, 1)
+	NewTaskService(client, outbox, nil, 1)
+
+	enqueueStatus(t, outbox, 1, models.TaskStatusInProgress)
+	enqueueStatus(t, outbox, 1, models.TaskStatusCompleted)
+
+	factory.down.Store(true)
+	for i := 0; i < 3; i++ {
+		if err := outbox.flush(context.Background()); !api.IsUnavailable(err) {
+			t.Fatalf("flush без связи: %v, ожидалась недоступность", err)
+		}
+	}
+
+	pending, err := outbox.Pending()
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != 2 || pending[0].Attempts != 3 || pending[1].Attempts != 0 {
+		t.Fatalf("очередь = %+v, ожидались 2 сообщения, первое с 3 попытками", pending)
+	}
+	if failed, _ := outbox.CountFailed(); failed != 0 {
+		t.Errorf("неотправленных = %d, ожидалось 0", failed)
+	}
+
+	factory.down.Store(false)
+	if err := outbox.flush(context.Background()); err != nil {
+		t.Fatalf("flush после восстановления связи: %v", err)
+	}
+
+	changes := factory.StatusChanges()
+	if len(changes) != 2 || changes[0].Status != models.TaskStatusInProgress || changes[1].Status != models.TaskStatusCompleted {
+		t.Fatalf("статусы = %v, ожидались \"в работе\", затем \"завершено\"", statuses(factory))
+	}
+	if changes[0].IdempotencyKey != pending[0].IdempotencyKey {
+		t.Errorf("ключ идемпотентности = %q, ожидался сохраненный %q", changes[0].IdempotencyKey, pending[0].IdempotencyKey)
+	}
+}
+
+func TestOutboxSenderSetsAsideRejectedMessage(t *testing.T) {
+	setupDB(t)
+	factory, client := newTestFactory(t)
+
+	outbox := NewOutboxSender(time.Second, 20)
+	NewTaskService(client, outbox, nil, 1)
+
+	// Задания 99 нет в EZFactory: сообщение не будет принято и не должно задерживать следующие
+	enqueueStatus(t, outbox, 99, models.TaskStatusCompleted)
+	enqueueStatus(t, outbox, 1, models.TaskStatusCompleted)
+
+	if err := outbox.flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	changes := factory.StatusChanges()
+	if len(changes) != 1 || changes[0].TaskID != 1 {
+		t.Fatalf("изменения статусов = %+v, ожидалось только задание 1", changes)
+	}
+
+	failed, err := outbox.Failed()
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if len(failed) != 1 || failed[0].TaskID != 99 || failed[0].FailedAt == nil || failed[0].LastError == "" {
+		t.Fatalf("неотправленные = %+v, ожидалось сообщение задания 99 с ошибкой", failed)
+	}
+
+	if err := outbox.Requeue(failed[0].ID); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	if pending, _ := outbox.CountPending(); pending != 1 {
+		t.Errorf("в очереди %d сообщений после возврата, ожидалось 1", pending)
+	}
+	if count, _ := outbox.CountFailed(); count != 0 {
+		t.Errorf("неотправленных после возврата = %d, ожидалось 0", count)
+	}
+
+	if err := outbox.Requeue(failed[0].ID); err == nil {
+		t.Error("повторный возврат сообщения из очереди должен завершиться ошибкой")
+	}
+}
+
+func TestOutboxSenderSetsAsideMessageWithoutHandler(t *testing.T) {
+	setupDB(t)
+	_, client := newTestFactory(t)
+
+	outbox := NewOutboxSender(time.Second, 20)
+	NewTaskService(client, outbox, nil, 1)
+
+	if err := outbox.Enqueue("unknown", 2, struct{}{}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	enqueueStatus(t, outbox, 1, models.TaskStatusCompleted)
+
+	if err := outbox.flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	failed, err := outbox.Failed()
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if len(failed) != 1 || failed[0].Kind != "unknown" {
+		t.Fatalf("неотправленные = %+v, ожидалось сообщение без обработчика", failed)
+	}
+	if pending, _ := outbox.CountPending(); pending != 0 {
+		t.Errorf("в очереди осталось %d сообщений", pending)
+	}
+}
+
+func TestOutboxSenderHoldsTaskBehindSetAsideMessage(t *testing.T) {
+	setupDB(t)
+
+	outbox := NewOutboxSender(time.Second, 20)
+
+	// EZFactory отклоняет статус "в работе" задания 1, пока оператор не исправит причину
+	rejected := map[string]bool{models.TaskStatusInProgress: true}
+	var delivered []string
+	outbox.Handle(models.OutboxKindTaskStatus, func(ctx context.Context, message models.OutboxMessage) error {
+		var payload models.TaskStatusPayload
+		if err := json.Unmarshal([]byte(message.Payload), &payload); err != nil {
+			return err
+		}
+		if rejected[payload.Status] {
+			return &api.Error{Kind: api.ErrRejected, Message: "отклонено"}
+		}
+		delivered = append(delivered, fmt.Sprintf("%d:%s", payload.TaskID, payload.Status))
+		return nil
+	})
+
+	enqueueStatus(t, outbox, 1, models.TaskStatusInProgress)
+	enqueueStatus(t, outbox, 1, models.TaskStatusCompleted)
+	enqueueStatus(t, outbox, 2, models.TaskStatusCompleted)
+
+	if err := outbox.flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	// Статус "завершено" задания 1 ждет отклоненный статус, другое задание не задерживается
+	if len(delivered) != 1 || delivered[0] != "2:"+models.TaskStatusCompleted {
+		t.Fatalf("доставлено = %v, ожидалось только завершение задания 2", delivered)
+	}
+	if pending, _ := outbox.CountPending(); pending != 1 {
+		t.Errorf("в очереди %d сообщений, ожидалось задержанное 1", pending)
+	}
+
+	// Повторная отправка не обходит задержку
+	if err := outbox.flush(context.Background()); err != nil {
+		t.Fatalf("повторный flush: %v", err)
+	}
+	if len(delivered) != 1 {
+		t.Fatalf("доставлено = %v, задержанное сообщение ушло раньше отклоненного", delivered)
+	}
+
+	failed, err := outbox.Failed()
+	if err != nil || len(failed) != 1 {
+		t.Fatalf("неотправленные = %+v (%v), ожидалось одно", failed, err)
+	}
+
+	delete(rejected, models.TaskStatusInProgress)
+	if err := outbox.Requeue(failed[0].ID); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	if err := outbox.flush(context.Background()); err != nil {
+		t.Fatalf("flush после возврата: %v", err)
+	}
+
+	want := []string{
+		"2:" + models.TaskStatusCompleted,
+		"1:" + models.TaskStatusInProgress,
+		"1:" + models.TaskStatusCompleted,
+	}
+	if fmt.Sprint(delivered) != fmt.Sprint(want) {
+		t.Errorf("доставлено = %v, ожидалось %v", delivered, want)
+	}
+}
+
+func TestOutboxSenderGiveUp(t *testing.T) {
+	outbox := NewOutboxSender(time.Second, 3)
+
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+		want     bool
+	}{
+		{"отклонено", 0, &api.Error{Kind: api.ErrRejected}, true},
+		{"не найдено", 0, &api.Error{Kind: api.ErrNotFound}, true},
+		{"нет обработчика", 0, ErrNoOutboxHandler, true},
+		{"недоступен", 100, &api.Error{Kind: api.ErrNetwork}, false},
+		{"ошибка сервера", 100, &api.Error{Kind: api.ErrServer}, false},
+		{"остановка", 100, context.Canceled, false},
+		{"прочая ошибка до предела", 1, errors.New("сбой"), false},
+		{"прочая ошибка на пределе", 2, errors.New("сбой"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := outbox.giveUp(models.OutboxMessage{Attempts: tt.attempts}, tt.err)
+			if got != tt.want {
+				t.Errorf("giveUp = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,30 +2,69 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/ze674/EZLine/internal/api"
 	"github.com/ze674/EZLine/internal/models"
 	"github.com/ze674/EZLine/internal/repository"
+	"github.com/ze674/EZLine/internal/validator"
+	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// PausableProcessor - процессор линии или ручной станции, который приостанавливается вместе с заданием
+type PausableProcessor interface {
+	Pause(reason string) error
+}
+
 // TaskService предоставляет методы для работы с заданиями
 type TaskService struct {
 	mu             sync.Mutex
 	factoryClient  *api.FactoryClient
+	outbox         *OutboxSender // Изменения статусов отправляются в EZFactory в фоне
+	codes          *MarkingCodeService
 	lineID         int
 	activeTaskID   int // Тут храним ID активного задания
 	activeTaskRepo *repository.ActiveTaskRepository
+	itemRepo       *repository.ItemRepository
+	containerRepo  *repository.ContainerRepository
+	cache          *repository.FactoryCacheRepository // Копии заданий и продуктов на случай недоступности EZFactory
+	processors     []PausableProcessor                // Процессоры, работающие по активному заданию
+
+	offlineMu     sync.Mutex
+	offlineDataAt time.Time // Время получения данных из кэша; нулевое, если EZFactory доступен
+
+	tasksVersion atomic.Uint64 // Растет с каждым уведомлением EZFactory, по нему обновляется список заданий
 }
 
 // NewTaskService создает новый сервис для управления заданиями
-func NewTaskService(factoryClient *api.FactoryClient, lineID int) *TaskService {
-	return &TaskService{
+func NewTaskService(factoryClient *api.FactoryClient, outbox *OutboxSender, codes *MarkingCodeService, lineID int) *TaskService {
+	s := &TaskService{
 		factoryClient:  factoryClient,
+		outbox:         outbox,
+		codes:          codes,
 		lineID:         lineID,
 		activeTaskID:   0, // Изначально нет активного задания
 		activeTaskRepo: repository.NewActiveTaskRepository(),
+		itemRepo:       repository.NewItemRepository(),
+		containerRepo:  repository.NewContainerRepository(),
+		cache:          repository.NewFactoryCacheRepository(),
 	}
+
+	outbox.Handle(models.OutboxKindTaskStatus, s.sendTaskStatus)
+
+	return s
+}
+
+// UseProcessors задает процессоры линии и станций, которые приостанавливаются вместе с заданием
+func (s *TaskService) UseProcessors(processors ...PausableProcessor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.processors = processors
 }
 
 // LoadActiveTask загружает ID активного задания из базы данных
@@ -39,8 +78,8 @@ func (s *TaskService) LoadActiveTask() error {
 	}
 
 	if taskID > 0 {
-		// Получаем актуальную информацию о задании с сервера
-		task, err := s.factoryClient.GetTaskByID(taskID)
+		// Получаем актуальную информацию о задании с сервера, без связи - из кэша
+		task, err := s.GetTaskByID(taskID)
 		if err != nil {
 			return err
 		}
@@ -49,9 +88,18 @@ func (s *TaskService) LoadActiveTask() error {
 		if task.Status != models.TaskStatusCompleted {
 			s.activeTaskID = taskID
 
-			// Если задание не в работе, обновляем его статус
-			if task.Status != models.TaskStatusInProgress {
-				if err := s.factoryClient.UpdateTaskStatus(taskID, models.TaskStatusInProgress); err != nil {
+			// План мог измениться в EZFactory, пока линия была выключена
+			if err := s.activeTaskRepo.UpdatePlan(taskID, task.Plan()); err != nil {
+				return err
+			}
+
+			// Продолжаем загрузку кодов задания, прерванную перезапуском
+			s.codes.DownloadAsync(taskID)
+
+			// Если задание не в работе, обновляем его статус. Отмененное в EZFactory задание
+			// остается активным, чтобы оператор завершил его, но в работу не возвращается
+			if task.Status != models.TaskStatusInProgress && task.Status != models.TaskStatusCancelled {
+				if err := s.reportTaskStatus(taskID, models.TaskStatusInProgress); err != nil {
 					return err
 				}
 			}
@@ -71,19 +119,203 @@ func (s *TaskService) GetLineID() int {
 	return s.lineID
 }
 
-// GetTasks получает список заданий с сервера
+// GetTasks получает список заданий с сервера.
+// Если EZFactory недоступен, возвращает последний сохраненный список
 func (s *TaskService) GetTasks() ([]models.Task, error) {
-	return s.factoryClient.GetTasks(s.lineID)
+	tasks, err := s.factoryClient.GetTasks(context.Background(), s.lineID)
+	if err == nil {
+		if cacheErr := s.cache.SaveTaskList(s.lineID, tasks); cacheErr != nil {
+			log.Printf("Ошибка сохранения заданий в кэш: %v", cacheErr)
+		}
+		s.setOnline()
+		return tasks, nil
+	}
+
+	// Кэш используется только при недоступности EZFactory, остальные ошибки показываются оператору
+	if !api.IsUnavailable(err) {
+		return nil, err
+	}
+
+	cached, fetchedAt, cacheErr := s.cache.GetTaskList(s.lineID)
+	if cacheErr != nil || len(cached) == 0 {
+		return nil, err
+	}
+
+	log.Printf("EZFactory недоступен, список заданий из кэша от %s: %v", fetchedAt.Format("15:04"), err)
+	s.setOffline(fetchedAt)
+	return cached, nil
 }
 
-// GetTaskByID получает информацию о задании по ID
+// GetTaskByID получает информацию о задании по ID.
+// Если EZFactory недоступен, возвращает сохраненное задание
 func (s *TaskService) GetTaskByID(taskID int) (models.Task, error) {
-	return s.factoryClient.GetTaskByID(taskID)
+	task, err := s.factoryClient.GetTaskByID(context.Background(), taskID)
+	if err == nil {
+		if cacheErr := s.cache.SaveTask(task); cacheErr != nil {
+			log.Printf("Ошибка сохранения задания %d в кэш: %v", taskID, cacheErr)
+		}
+		s.setOnline()
+		return task, nil
+	}
+
+	if !api.IsUnavailable(err) {
+		return task, err
+	}
+
+	cached, fetchedAt, cacheErr := s.cache.GetTask(taskID)
+	if cacheErr != nil {
+		return task, err
+	}
+
+	log.Printf("EZFactory недоступен, задание %d из кэша от %s: %v", taskID, fetchedAt.Format("15:04"), err)
+	s.setOffline(fetchedAt)
+	return cached, nil
 }
 
+// GetProductByID получает карточку продукта вместе с данными этикетки.
+// Если EZFactory недоступен, возвращает сохраненную карточку
 func (s *TaskService) GetProductByID(productID int) (models.Product, error) {
 	// Вызов API для получения информации о продукте
-	return s.factoryClient.GetProductByID(productID)
+	product, err := s.factoryClient.GetProductByID(context.Background(), productID)
+	if err == nil {
+		if cacheErr := s.cache.SaveProduct(product); cacheErr != nil {
+			log.Printf("Ошибка сохранения продукта %d в кэш: %v", productID, cacheErr)
+		}
+		s.setOnline()
+		return product, nil
+	}
+
+	if !api.IsUnavailable(err) {
+		return product, err
+	}
+
+	cached, fetchedAt, cacheErr := s.cache.GetProduct(productID)
+	if cacheErr != nil {
+		return product, err
+	}
+
+	log.Printf("EZFactory недоступен, продукт %d из кэша от %s: %v", productID, fetchedAt.Format("15:04"), err)
+	s.setOffline(fetchedAt)
+	return cached, nil
+}
+
+// ApplyFactoryEvent обновляет сохраненные задание или продукт по уведомлению EZFactory
+// и сообщает, касается ли уведомление активного задания.
+// Данные запрашиваются без обращения к кэшу: если EZFactory недоступен, уведомление не применяется
+func (s *TaskService) ApplyFactoryEvent(event models.FactoryEvent) (bool, error) {
+	ctx := context.Background()
+	activeTaskID := s.GetActiveTaskID()
+	affectsActive := false
+
+	switch event.Type {
+	case models.FactoryEventTaskAssigned, models.FactoryEventTaskCancelled:
+		task, err := s.factoryClient.GetTaskByID(ctx, event.TaskID)
+		if err != nil {
+			return false, fmt.Errorf("ошибка при получении задания %d: %w", event.TaskID, err)
+		}
+		if err := s.cache.SaveTask(task); err != nil {
+			return false, fmt.Errorf("ошибка сохранения задания %d в кэш: %w", event.TaskID, err)
+		}
+		affectsActive = event.TaskID == activeTaskID
+
+	case models.FactoryEventProductUpdated:
+		product, err := s.factoryClient.GetProductByID(ctx, event.ProductID)
+		if err != nil {
+			return false, fmt.Errorf("ошибка при получении продукта %d: %w", event.ProductID, err)
+		}
+		if err := s.cache.SaveProduct(product); err != nil {
+			return false, fmt.Errorf("ошибка сохранения продукта %d в кэш: %w", event.ProductID, err)
+		}
+
+		if activeTaskID != 0 {
+			task, _, err := s.cache.GetTask(activeTaskID)
+			if err != nil {
+				return false, fmt.Errorf("ошибка при получении активного задания: %w", err)
+			}
+			affectsActive = task.ProductID == event.ProductID
+		}
+
+	default:
+		return false, fmt.Errorf("неизвестный тип уведомления: %s", event.Type)
+	}
+
+	s.setOnline()
+	s.tasksVersion.Add(1)
+	return affectsActive, nil
+}
+
+// TasksVersion возвращает номер версии списка заданий, меняющийся при уведомлениях EZFactory
+func (s *TaskService) TasksVersion() uint64 {
+	return s.tasksVersion.Load()
+}
+
+// ActiveTaskWarnings возвращает предупреждения по активному заданию, полученные от EZFactory
+func (s *TaskService) ActiveTaskWarnings() []string {
+	s.mu.Lock()
+	activeTaskID := s.activeTaskID
+	s.mu.Unlock()
+
+	var warnings []string
+	if activeTaskID == 0 {
+		return warnings
+	}
+
+	if task, _, err := s.cache.GetTask(activeTaskID); err == nil && task.Status == models.TaskStatusCancelled {
+		warnings = append(warnings, "Задание отменено в EZFactory. Остановите линию и завершите задание.")
+	}
+	if poolWarning := s.codes.PoolWarning(activeTaskID); poolWarning != "" {
+		warnings = append(warnings, poolWarning)
+	}
+
+	return warnings
+}
+
+// OfflineDataTime возвращает время получения данных, показанных из кэша.
+// Второе значение false, если последнее обращение к EZFactory было успешным
+func (s *TaskService) OfflineDataTime() (time.Time, bool) {
+	s.offlineMu.Lock()
+	defer s.offlineMu.Unlock()
+
+	return s.offlineDataAt, !s.offlineDataAt.IsZero()
+}
+
+func (s *TaskService) setOnline() {
+	s.offlineMu.Lock()
+	defer s.offlineMu.Unlock()
+
+	s.offlineDataAt = time.Time{}
+}
+
+func (s *TaskService) setOffline(fetchedAt time.Time) {
+	s.offlineMu.Lock()
+	defer s.offlineMu.Unlock()
+
+	s.offlineDataAt = fetchedAt
+}
+
+// GetCodePool возвращает коды маркировки, выданные EZFactory для задания
+func (s *TaskService) GetCodePool(taskID int) (*validator.CodePool, error) {
+	return s.codes.Pool(taskID)
+}
+
+// GetCodeDownload возвращает ход загрузки и использование кодов задания
+func (s *TaskService) GetCodeDownload(taskID int) (models.CodeDownload, models.CodeUsage, bool, error) {
+	download, _, err := s.codes.GetDownload(taskID)
+	if err != nil {
+		return download, models.CodeUsage{}, false, err
+	}
+
+	usage, err := s.codes.GetUsage(taskID)
+	if err != nil {
+		return download, usage, false, err
+	}
+
+	return download, usage, s.codes.IsDownloading(taskID), nil
+}
+
+// DownloadCodes запускает загрузку кодов задания в фоне, например после ошибки загрузки
+func (s *TaskService) DownloadCodes(taskID int) {
+	s.codes.DownloadAsync(taskID)
 }
 
 // GetActiveTaskID возвращает ID активного задания
@@ -94,6 +326,29 @@ func (s *TaskService) GetActiveTaskID() int {
 	return s.activeTaskID
 }
 
+// GetPlanProgress возвращает выполнение плана задания: сохраненный план и произведенное количество
+func (s *TaskService) GetPlanProgress(taskID int) (models.PlanProgress, error) {
+	var progress models.PlanProgress
+	var err error
+
+	progress.Plan, err = s.activeTaskRepo.GetPlan(taskID)
+	if err != nil {
+		return progress, fmt.Errorf("ошибка при получении плана задания: %w", err)
+	}
+
+	progress.Quantity, err = s.itemRepo.CountByTaskID(taskID)
+	if err != nil {
+		return progress, fmt.Errorf("ошибка при подсчете продукции: %w", err)
+	}
+
+	progress.Boxes, err = s.containerRepo.CountByTaskID(taskID)
+	if err != nil {
+		return progress, fmt.Errorf("ошибка при подсчете коробов: %w", err)
+	}
+
+	return progress, nil
+}
+
 // SelectTask выбирает задание (меняет его статус на "в работе")
 func (s *TaskService) SelectTask(taskID int) error {
 	s.mu.Lock()
@@ -101,7 +356,7 @@ func (s *TaskService) SelectTask(taskID int) error {
 
 	// Если уже есть активное задание с другим ID, запрещаем выбор нового
 	if s.activeTaskID != 0 && s.activeTaskID != taskID {
-		return fmt.Errorf("уже выполняется задание ID=%d, завершите его перед выбором нового", s.activeTaskID)
+		return fmt.Errorf("уже выполняется задание ID=%d, завершите или приостановите его перед выбором нового", s.activeTaskID)
 	}
 
 	// Если это то же самое задание, что уже активно - ничего не делаем
@@ -110,28 +365,72 @@ func (s *TaskService) SelectTask(taskID int) error {
 	}
 
 	// Получаем информацию о задании
-	task, err := s.factoryClient.GetTaskByID(taskID)
+	task, err := s.GetTaskByID(taskID)
 	if err != nil {
 		return fmt.Errorf("ошибка при получении информации о задании: %w", err)
 	}
 
+	if task.Status == models.TaskStatusCancelled {
+		return fmt.Errorf("задание ID=%d отменено в EZFactory", taskID)
+	}
+
+	// Заранее сохраняем карточку продукта, чтобы задание можно было запустить без связи с EZFactory
+	if _, err := s.GetProductByID(task.ProductID); err != nil {
+		log.Printf("Не удалось получить продукт %d задания %d: %v", task.ProductID, taskID, err)
+	}
+
 	// Устанавливаем ID активного задания
 	s.activeTaskID = taskID
 
-	// Если задание еще не в работе, обновляем его статус через API
+	// Если задание еще не в работе, обновляем его статус через очередь отправки
 	if task.Status != models.TaskStatusInProgress {
-		err = s.factoryClient.UpdateTaskStatus(taskID, models.TaskStatusInProgress)
+		err = s.reportTaskStatus(taskID, models.TaskStatusInProgress)
 		if err != nil {
 			// Логируем ошибку, но продолжаем работу (задание уже считается выбранным)
 			return fmt.Errorf("задание выбрано, но возникла ошибка при обновлении статуса: %w", err)
 		}
 	}
 
-	err = s.activeTaskRepo.SaveActiveTask(taskID)
+	err = s.activeTaskRepo.SaveActiveTask(taskID, task.Plan())
 	if err != nil {
 		return fmt.Errorf("ошибка при сохранении активного задания: %w", err)
 	}
 
+	// Коды маркировки задания загружаются в фоне, пока оператор готовит линию
+	s.codes.DownloadAsync(taskID)
+
+	return nil
+}
+
+// PauseTask приостанавливает активное задание вместе с его процессорами и освобождает линию для другого задания.
+// Несобранный короб, коды и серийные номера коробов остаются в базе
+// и восстанавливаются процессором при возобновлении задания
+func (s *TaskService) PauseTask() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	taskID := s.activeTaskID
+	if taskID == 0 {
+		return fmt.Errorf("задание не выбрано")
+	}
+
+	// Процессоры переходят в "приостановлено" и освобождают оборудование
+	for _, processor := range s.processors {
+		if err := processor.Pause(fmt.Sprintf("задание %d приостановлено", taskID)); err != nil {
+			return fmt.Errorf("ошибка приостановки процессора: %w", err)
+		}
+	}
+
+	// Статус "приостановлено" уходит в EZFactory, только если задание освобождено локально
+	status, err := s.outbox.Prepare(models.OutboxKindTaskStatus, taskID, models.TaskStatusPayload{TaskID: taskID, Status: models.TaskStatusPaused})
+	if err != nil {
+		return fmt.Errorf("ошибка при обновлении статуса задания: %w", err)
+	}
+
+	if err := s.releaseActiveTask(taskID, models.TaskStatusPaused, nil, []models.OutboxMessage{status}); err != nil {
+		return fmt.Errorf("ошибка при очистке активного задания: %w", err)
+	}
+
 	return nil
 }
 
@@ -145,23 +444,93 @@ func (s *TaskService) FinishTask() error {
 		return fmt.Errorf("задание не выбрано")
 	}
 
-	// Проверяем, что это активное задание
-	if s.activeTaskID != taskID {
-		return fmt.Errorf("задание с ID %d не является активным", taskID)
+	// Статус "завершено" ставится в очередь первым: выгрузка агрегации затем переведет задание в "отправлено"
+	status, err := s.outbox.Prepare(models.OutboxKindTaskStatus, taskID, models.TaskStatusPayload{TaskID: taskID, Status: models.TaskStatusCompleted})
+	if err != nil {
+		return fmt.Errorf("ошибка при обновлении статуса задания: %w", err)
 	}
+	messages := []models.OutboxMessage{status}
 
-	// Обновляем статус задания на "завершено"
-	err := s.factoryClient.UpdateTaskStatus(taskID, models.TaskStatusCompleted)
+	// Отчитываемся об использовании кодов задания, коды распределяются по статусам при освобождении задания
+	report, err := s.codes.FinishTask(taskID)
 	if err != nil {
-		return fmt.Errorf("ошибка при обновлении статуса задания: %w", err)
+		return fmt.Errorf("ошибка при подготовке отчета по кодам: %w", err)
 	}
+	messages = append(messages, report...)
 
-	// Сбрасываем активное задание
-	s.activeTaskID = 0
+	// Выгружаем агрегацию задания, после выгрузки задание перейдет в статус "отправлено"
+	upload, err := s.outbox.Prepare(models.OutboxKindAggregationUpload, taskID, models.AggregationUploadPayload{
+		TaskID:       taskID,
+		MarkTaskSent: true,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при постановке выгрузки агрегации: %w", err)
+	}
+	messages = append(messages, upload)
 
-	if err := s.activeTaskRepo.ClearActiveTask(); err != nil {
+	if err := s.releaseActiveTask(taskID, models.TaskStatusCompleted, s.codes.CodeKey, messages); err != nil {
 		return fmt.Errorf("ошибка при очистке активного задания: %w", err)
 	}
+	if len(report) > 0 {
+		s.codes.logUsage(taskID)
+	}
+
+	return nil
+}
+
+// releaseActiveTask освобождает линию от задания и в той же транзакции ставит в очередь сообщения EZFactory,
+// а при заданном codeKey распределяет коды маркировки задания по статусам.
+// При ошибке задание остается активным, коды не меняются, а в EZFactory ничего не отправляется
+func (s *TaskService) releaseActiveTask(taskID int, status string, codeKey func(code string) string, messages []models.OutboxMessage) error {
+	if err := s.activeTaskRepo.ReleaseActiveTask(taskID, codeKey, messages); err != nil {
+		return err
+	}
+	s.outbox.Notify()
+
+	s.activeTaskID = 0
+
+	// Статус в кэше меняем сразу, чтобы без связи список заданий показывал состояние линии
+	if err := s.cache.UpdateTaskStatus(taskID, status); err != nil {
+		log.Printf("Ошибка обновления статуса задания %d в кэше: %v", taskID, err)
+	}
 
 	return nil
 }
+
+// UploadAggregation ставит в очередь выгрузку еще не отправленных коробов задания.
+// Для неактивного задания после выгрузки устанавливается статус "отправлено"
+func (s *TaskService) UploadAggregation(taskID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.enqueueAggregationUpload(taskID, taskID != s.activeTaskID)
+}
+
+// reportTaskStatus ставит изменение статуса задания в очередь отправки в EZFactory.
+// Статус отправляется в фоне, поэтому оператор может продолжать работу без связи с сервером
+func (s *TaskService) reportTaskStatus(taskID int, status string) error {
+	// Статус в кэше меняем сразу, чтобы без связи список заданий показывал состояние линии
+	if err := s.cache.UpdateTaskStatus(taskID, status); err != nil {
+		log.Printf("Ошибка обновления статуса задания %d в кэше: %v", taskID, err)
+	}
+
+	return s.outbox.Enqueue(models.OutboxKindTaskStatus, taskID, models.TaskStatusPayload{TaskID: taskID, Status: status})
+}
+
+// enqueueAggregationUpload ставит выгрузку агрегации задания в очередь отправки
+func (s *TaskService) enqueueAggregationUpload(taskID int, markTaskSent bool) error {
+	return s.outbox.Enqueue(models.OutboxKindAggregationUpload, taskID, models.AggregationUploadPayload{
+		TaskID:       taskID,
+		MarkTaskSent: markTaskSent,
+	})
+}
+
+// sendTaskStatus отправляет в EZFactory сообщение об изменении статуса задания из очереди
+func (s *TaskService) sendTaskStatus(ctx context.Context, message models.OutboxMessage) error {
+	var payload models.TaskStatusPayload
+	if err := json.Unmarshal([]byte(message.Payload), &payload); err != nil {
+		return fmt.Errorf("ошибка разбора сообщения: %w", err)
+	}
+
+	return s.factoryClient.UpdateTaskStatusWithKey(ctx, payload.TaskID, payload.Status, message.IdempotencyKey)
+}
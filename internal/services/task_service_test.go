@@ -0,0 +1,113 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ze674/EZLine/internal/models"
+	"github.com/ze674/EZLine/internal/repository"
+)
+
+func TestTaskServiceCacheFallback(t *testing.T) {
+	fetches := []struct {
+		name  string
+		fetch func(s *TaskService, id int) error
+	}{
+		{"список заданий", func(s *TaskService, id int) error {
+			_, err := s.GetTasks()
+			return err
+		}},
+		{"задание", func(s *TaskService, id int) error {
+			_, err := s.GetTaskByID(id)
+			return err
+		}},
+		{"продукт", func(s *TaskService, id int) error {
+			_, err := s.GetProductByID(id)
+			return err
+		}},
+	}
+
+	tests := []struct {
+		name        string
+		cached      bool // Данные получены из EZFactory до отказа
+		down        bool // EZFactory недоступен
+		wantErr     bool
+		wantOffline bool
+	}{
+		{"EZFactory доступен", false, false, false, false},
+		{"отказ после получения данных", true, true, false, true},
+		{"отказ без сохраненных данных", false, true, true, false},
+		{"EZFactory снова доступен", true, false, false, false},
+	}
+
+	for _, fetch := range fetches {
+		for _, tt := range tests {
+			t.Run(fetch.name+"/"+tt.name, func(t *testing.T) {
+				setupDB(t)
+				factory, client := newTestFactory(t)
+				s := NewTaskService(client, NewOutboxSender(time.Second, 20), nil, 1)
+
+				if tt.cached {
+					if err := fetch.fetch(s, 1); err != nil {
+						t.Fatalf("получение до отказа: %v", err)
+					}
+					factory.down.Store(true)
+					if err := fetch.fetch(s, 1); err != nil {
+						t.Fatalf("получение из кэша: %v", err)
+					}
+				}
+				factory.down.Store(tt.down)
+
+				if err := fetch.fetch(s, 1); (err != nil) != tt.wantErr {
+					t.Fatalf("ошибка = %v", err)
+				}
+				if _, offline := s.OfflineDataTime(); offline != tt.wantOffline {
+					t.Errorf("данные из кэша: %v, ожидалось %v", offline, tt.wantOffline)
+				}
+			})
+		}
+	}
+}
+
+func TestTaskServiceCacheKeepsFactoryErrors(t *testing.T) {
+	setupDB(t)
+	_, client := newTestFactory(t)
+	s := NewTaskService(client, NewOutboxSender(time.Second, 20), nil, 1)
+
+	// Задание удалено в EZFactory, но осталось в кэше: отказ EZFactory не подменяется кэшем
+	if err := repository.NewFactoryCacheRepository().SaveTask(models.Task{ID: 99, LineID: 1, ProductID: 1}); err != nil {
+		t.Fatalf("SaveTask: %v", err)
+	}
+
+	if _, err := s.GetTaskByID(99); err == nil {
+		t.Error("задание, которого нет в EZFactory, получено из кэша")
+	}
+	if _, offline := s.OfflineDataTime(); offline {
+		t.Error("ответ EZFactory принят за недоступность")
+	}
+}
+
+func TestTaskServiceOfflineDataTime(t *testing.T) {
+	setupDB(t)
+	factory, client := newTestFactory(t)
+	s := NewTaskService(client, NewOutboxSender(time.Second, 20), nil, 1)
+
+	before := time.Now().Add(-time.Second)
+	if _, err := s.GetTaskByID(1); err != nil {
+		t.Fatalf("GetTaskByID: %v", err)
+	}
+	factory.down.Store(true)
+
+	task, err := s.GetTaskByID(1)
+	if err != nil {
+		t.Fatalf("GetTaskByID: %v", err)
+	}
+	if task.ID != 1 || task.ProductID != 1 {
+		t.Errorf("задание из кэша = %+v", task)
+	}
+
+	fetchedAt, offline := s.OfflineDataTime()
+	if !offline || fetchedAt.Before(before) || fetchedAt.After(time.Now()) {
+		t.Errorf("время данных %s (из кэша: %v), ожидалось время получения из EZFactory", fetchedAt, offline)
+	}
+}
@@ -123,6 +123,16 @@ func (v *CodeUniquenessValidator) MarkCodesAsUsed(codes []string) {
 	}
 }
 
+// UnmarkCodes снимает отметку об использовании с кодов, например после расформирования короба
+func (v *CodeUniquenessValidator) UnmarkCodes(codes []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, code := range codes {
+		delete(v.usedCodes, code)
+	}
+}
+
 // GetUsedCodesCount возвращает количество использованных кодов
 func (v *CodeUniquenessValidator) GetUsedCodesCount() int {
 	v.mu.Lock()
@@ -0,0 +1,47 @@
+package validator
+
+import "github.com/ze674/EZLine/internal/markingcode"
+
+// CodePool - коды маркировки, выданные для задания. Коды сравниваются по коду идентификации
+// 01 + GTIN + 21 + серийный номер, поэтому код из пула с криптохвостом совпадает с кодом,
+// который сканер прочитал без криптохвоста, без разделителей GS или с префиксом символики
+type CodePool struct {
+	serialLength int
+	codes        map[string]struct{}
+}
+
+// NewCodePool создает пул кодов. serialLength - длина серийного номера в кодах без разделителей GS
+func NewCodePool(codes []string, serialLength int) *CodePool {
+	pool := &CodePool{
+		serialLength: serialLength,
+		codes:        make(map[string]struct{}, len(codes)),
+	}
+
+	for _, code := range codes {
+		pool.codes[NormalizeCode(code, serialLength)] = struct{}{}
+	}
+
+	return pool
+}
+
+// Contains сообщает, выдан ли код для задания
+func (p *CodePool) Contains(code string) bool {
+	_, ok := p.codes[NormalizeCode(code, p.serialLength)]
+	return ok
+}
+
+// Len возвращает количество кодов в пуле
+func (p *CodePool) Len() int {
+	return len(p.codes)
+}
+
+// NormalizeCode приводит код маркировки к коду идентификации 01 + GTIN + 21 + серийный номер.
+// Код, который не удалось разобрать, возвращается без изменений
+func NormalizeCode(code string, serialLength int) string {
+	parsed, err := markingcode.Parse(code, serialLength)
+	if err != nil {
+		return code
+	}
+
+	return parsed.KI()
+}
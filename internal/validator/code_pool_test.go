@@ -0,0 +1,42 @@
+package validator
+
+import "testing"
+
+func TestCodePoolMatchesIdentificationCode(t *testing.T) {
+	const (
+		ki   = "010460705476616421ABC123def"
+		tail = "\x1d91EE10\x1d92dGVzdA=="
+	)
+
+	pool := NewCodePool([]string{ki + tail}, 9)
+
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"код из пула", ki + tail, true},
+		{"без криптохвоста", ki, true},
+		{"без разделителей GS", ki + "91EE1092dGVzdA==", true},
+		{"с префиксом символики", "]C1" + ki + tail, true},
+		{"другой серийный номер", "010460705476616421ABC123deg", false},
+		{"не код маркировки", "mock", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pool.Contains(tt.code); got != tt.want {
+				t.Errorf("Contains(%q) = %v, ожидалось %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeValidatorSkipsEmptyPool(t *testing.T) {
+	v := NewCodeValidator("04607054766164", 27)
+	v.UsePool(NewCodePool(nil, 9))
+
+	if result := v.ValidateCode("010460705476616421ABC123def"); !result.Valid {
+		t.Errorf("код отбракован пустым пулом: %s", result.Message)
+	}
+}
@@ -9,6 +9,7 @@ import (
 var (
 	ErrInvalidGTIN   = errors.New("GTIN не найден в коде")
 	ErrInvalidLength = errors.New("код имеет некорректную длину")
+	ErrNotInPool     = errors.New("код не выдан для задания")
 )
 
 // Результат валидации
@@ -27,6 +28,9 @@ type ValidationResults struct {
 type CodeValidator struct {
 	GTIN       string // Код GTIN продукта, который должен содержаться в штрих-коде
 	LengthCode int    // Ожидаемая длина штрих-кода
+
+	// Коды, выданные для задания, nil - принадлежность кода заданию не проверяется
+	pool *CodePool
 }
 
 // NewCodeValidator создает новый экземпляр валидатора
@@ -37,6 +41,16 @@ func NewCodeValidator(gtin string, lengthCode int) *CodeValidator {
 	}
 }
 
+// UsePool ограничивает допустимые коды кодами, выданными для задания. Пустой пул не проверяется
+func (v *CodeValidator) UsePool(pool *CodePool) {
+	if pool == nil || pool.Len() == 0 {
+		v.pool = nil
+		return
+	}
+
+	v.pool = pool
+}
+
 // ValidateCode проверяет код и возвращает результат валидации
 func (v *CodeValidator) ValidateCode(code string) ValidationResult {
 	result := ValidationResult{
@@ -58,6 +72,15 @@ func (v *CodeValidator) ValidateCode(code string) ValidationResult {
 		return result
 	}
 
+	// Проверка, что код выдан для задания
+	if v.pool != nil {
+		if !v.pool.Contains(code) {
+			result.Valid = false
+			result.Message = ErrNotInPool.Error()
+			return result
+		}
+	}
+
 	result.Message = "Код валиден"
 	return result
 }
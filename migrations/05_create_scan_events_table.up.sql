@@ -0,0 +1,13 @@
+CREATE TABLE scan_events (
+                             id INTEGER PRIMARY KEY AUTOINCREMENT,
+                             task_id INTEGER NOT NULL,              -- К какому заданию относится
+                             raw_response TEXT NOT NULL DEFAULT '', -- Сырой ответ сканера
+                             codes TEXT NOT NULL DEFAULT '',        -- Разобранные коды через пробел
+                             outcome TEXT NOT NULL,                 -- Итог (принят, отклонен)
+                             reason TEXT NOT NULL DEFAULT '',       -- Код причины отклонения
+                             message TEXT NOT NULL DEFAULT '',      -- Подробности для оператора
+                             created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Индекс для быстрого поиска по заданию
+CREATE INDEX idx_scan_events_task_id ON scan_events(task_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS container_events;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE container_events (
+                                  id INTEGER PRIMARY KEY AUTOINCREMENT,
+                                  container_id INTEGER NOT NULL,         -- Контейнер, с которым выполнена операция
+                                  task_id INTEGER NOT NULL,              -- К какому заданию относится
+                                  action TEXT NOT NULL,                  -- Операция (удаление, замена, добавление, расформирование)
+                                  item_code TEXT NOT NULL DEFAULT '',    -- Код товара, затронутого операцией
+                                  new_item_code TEXT NOT NULL DEFAULT '',-- Новый код товара при замене
+                                  reason TEXT NOT NULL DEFAULT '',       -- Причина, указанная оператором
+                                  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                                  FOREIGN KEY (container_id) REFERENCES containers(id)
+);
+
+-- Индекс для быстрого поиска по контейнеру
+CREATE INDEX idx_container_events_container_id ON container_events(container_id);
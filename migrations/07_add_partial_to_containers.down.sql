@@ -0,0 +1,2 @@
+-- migrations/07_add_partial_to_containers.down.sql
+ALTER TABLE containers DROP COLUMN partial;
\ No newline at end of file
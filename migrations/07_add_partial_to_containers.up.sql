@@ -0,0 +1,3 @@
+-- migrations/07_add_partial_to_containers.up.sql
+-- Признак короба, закрытого оператором до заполнения
+ALTER TABLE containers ADD COLUMN partial INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
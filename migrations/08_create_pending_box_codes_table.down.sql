@@ -0,0 +1 @@
+DROP TABLE IF EXISTS pending_box_codes;
\ No newline at end of file
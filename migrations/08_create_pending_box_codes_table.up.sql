@@ -0,0 +1,10 @@
+CREATE TABLE pending_box_codes (
+                                   id INTEGER PRIMARY KEY AUTOINCREMENT,
+                                   task_id INTEGER NOT NULL,              -- К какому заданию относится
+                                   layer INTEGER NOT NULL,                -- Номер слоя в коробе
+                                   code TEXT NOT NULL,                    -- Код товара, уложенного в короб
+                                   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Индекс для быстрого поиска по заданию
+CREATE INDEX idx_pending_box_codes_task_id ON pending_box_codes(task_id);
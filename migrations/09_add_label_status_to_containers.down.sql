@@ -0,0 +1,2 @@
+-- migrations/09_add_label_status_to_containers.down.sql
+ALTER TABLE containers DROP COLUMN label_status;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- migrations/09_add_label_status_to_containers.up.sql
+-- Результат проверки напечатанной этикетки короба: пусто - не проверялась
+ALTER TABLE containers ADD COLUMN label_status TEXT NOT NULL DEFAULT '';
\ No newline at end of file
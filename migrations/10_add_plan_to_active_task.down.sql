@@ -0,0 +1,3 @@
+-- migrations/10_add_plan_to_active_task.down.sql
+ALTER TABLE active_task DROP COLUMN planned_boxes;
+ALTER TABLE active_task DROP COLUMN planned_quantity;
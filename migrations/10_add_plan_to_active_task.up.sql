@@ -0,0 +1,4 @@
+-- migrations/10_add_plan_to_active_task.up.sql
+-- План задания сохраняется вместе с активным заданием
+ALTER TABLE active_task ADD COLUMN planned_quantity INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE active_task ADD COLUMN planned_boxes INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- migrations/11_create_outbox_table.down.sql
+DROP TABLE outbox;
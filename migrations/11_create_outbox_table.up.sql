@@ -0,0 +1,16 @@
+-- migrations/11_create_outbox_table.up.sql
+-- Исходящие вызовы EZFactory, отправляются фоновым процессом по порядку
+CREATE TABLE outbox (
+                        id INTEGER PRIMARY KEY AUTOINCREMENT,
+                        kind TEXT NOT NULL,                    -- Тип сообщения
+                        task_id INTEGER NOT NULL DEFAULT 0,    -- К какому заданию относится
+                        payload TEXT NOT NULL,                 -- Данные вызова в JSON
+                        idempotency_key TEXT NOT NULL UNIQUE,  -- Ключ для безопасного повтора на стороне EZFactory
+                        attempts INTEGER NOT NULL DEFAULT 0,   -- Количество неудачных попыток отправки
+                        last_error TEXT NOT NULL DEFAULT '',   -- Ошибка последней попытки
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        sent_at TIMESTAMP                      -- Время успешной отправки, NULL - ожидает отправки
+);
+
+-- Индекс для выборки неотправленных сообщений
+CREATE INDEX idx_outbox_sent_at ON outbox(sent_at);
@@ -0,0 +1,2 @@
+-- migrations/12_add_sent_at_to_containers.down.sql
+ALTER TABLE containers DROP COLUMN sent_at;
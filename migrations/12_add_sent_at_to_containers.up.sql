@@ -0,0 +1,3 @@
+-- migrations/12_add_sent_at_to_containers.up.sql
+-- Время выгрузки короба в EZFactory: NULL - не выгружен или изменен после выгрузки
+ALTER TABLE containers ADD COLUMN sent_at TIMESTAMP;
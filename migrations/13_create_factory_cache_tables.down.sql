@@ -0,0 +1,3 @@
+-- migrations/13_create_factory_cache_tables.down.sql
+DROP TABLE cached_products;
+DROP TABLE cached_tasks;
@@ -0,0 +1,16 @@
+-- migrations/13_create_factory_cache_tables.up.sql
+-- Копии заданий и продуктов EZFactory для работы без связи с сервером
+CREATE TABLE cached_tasks (
+                              id INTEGER PRIMARY KEY,              -- ID задания в EZFactory
+                              line_id INTEGER NOT NULL,            -- Линия задания
+                              data TEXT NOT NULL,                  -- Задание в JSON
+                              fetched_at TIMESTAMP NOT NULL        -- Время получения из EZFactory
+);
+
+CREATE INDEX idx_cached_tasks_line_id ON cached_tasks(line_id);
+
+CREATE TABLE cached_products (
+                                 id INTEGER PRIMARY KEY,           -- ID продукта в EZFactory
+                                 data TEXT NOT NULL,               -- Карточка продукта с данными этикетки в JSON
+                                 fetched_at TIMESTAMP NOT NULL
+);
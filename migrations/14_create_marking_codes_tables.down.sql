@@ -0,0 +1,3 @@
+-- migrations/14_create_marking_codes_tables.down.sql
+DROP TABLE code_downloads;
+DROP TABLE marking_codes;
@@ -0,0 +1,20 @@
+-- migrations/14_create_marking_codes_tables.up.sql
+-- Коды маркировки, выданные EZFactory для задания
+CREATE TABLE marking_codes (
+                               code TEXT PRIMARY KEY,                -- Код маркировки
+                               task_id INTEGER NOT NULL,             -- Задание, для которого выдан код
+                               status TEXT NOT NULL DEFAULT 'issued',-- Выдан, использован, испорчен, возвращен
+                               updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_marking_codes_task_id ON marking_codes(task_id, status);
+
+-- Ход загрузки кодов задания для продолжения после сбоя
+CREATE TABLE code_downloads (
+                                task_id INTEGER PRIMARY KEY,
+                                cursor TEXT NOT NULL DEFAULT '',      -- Курсор следующей страницы
+                                downloaded INTEGER NOT NULL DEFAULT 0,-- Загружено кодов
+                                completed BOOLEAN NOT NULL DEFAULT 0,
+                                error TEXT NOT NULL DEFAULT '',       -- Ошибка последней попытки
+                                updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
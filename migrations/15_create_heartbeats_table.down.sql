@@ -0,0 +1,2 @@
+-- migrations/15_create_heartbeats_table.down.sql
+DROP TABLE heartbeats;
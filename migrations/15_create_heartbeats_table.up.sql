@@ -0,0 +1,7 @@
+-- migrations/15_create_heartbeats_table.up.sql
+-- Снимки состояния линии, ожидающие отправки в EZFactory
+CREATE TABLE heartbeats (
+                            id INTEGER PRIMARY KEY AUTOINCREMENT,
+                            data TEXT NOT NULL,                   -- Снимок в JSON
+                            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
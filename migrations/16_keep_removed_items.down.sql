@@ -0,0 +1,21 @@
+-- migrations/16_keep_removed_items.down.sql
+DELETE FROM items WHERE status = 'removed';
+
+CREATE TABLE items_old (
+                           id INTEGER PRIMARY KEY AUTOINCREMENT,
+                           code TEXT NOT NULL UNIQUE,             -- Уникальный код товара
+                           task_id INTEGER NOT NULL,              -- К какому заданию относится
+                           container_id INTEGER,                  -- Привязка к контейнеру (может быть NULL)
+                           status TEXT NOT NULL,                  -- Статус (отсканирован, агрегирован и т.д.)
+                           created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                           FOREIGN KEY (container_id) REFERENCES containers(id)
+);
+
+INSERT INTO items_old (id, code, task_id, container_id, status, created_at)
+SELECT id, code, task_id, container_id, status, created_at FROM items;
+
+DROP TABLE items;
+ALTER TABLE items_old RENAME TO items;
+
+CREATE INDEX idx_items_task_id ON items(task_id);
+CREATE INDEX idx_items_container_id ON items(container_id);
@@ -0,0 +1,23 @@
+-- migrations/16_keep_removed_items.up.sql
+-- Товары, убранные из коробов, остаются в базе со статусом removed.
+-- Код должен быть уникален только среди действующих товаров, поэтому таблица пересоздается
+-- с частичным уникальным индексом вместо ограничения UNIQUE
+CREATE TABLE items_new (
+                           id INTEGER PRIMARY KEY AUTOINCREMENT,
+                           code TEXT NOT NULL,                    -- Код товара, уникален среди действующих товаров
+                           task_id INTEGER NOT NULL,              -- К какому заданию относится
+                           container_id INTEGER,                  -- Привязка к контейнеру (может быть NULL)
+                           status TEXT NOT NULL,                  -- Статус (отсканирован, агрегирован, убран из короба)
+                           created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                           FOREIGN KEY (container_id) REFERENCES containers(id)
+);
+
+INSERT INTO items_new (id, code, task_id, container_id, status, created_at)
+SELECT id, code, task_id, container_id, status, created_at FROM items;
+
+DROP TABLE items;
+ALTER TABLE items_new RENAME TO items;
+
+CREATE UNIQUE INDEX idx_items_code ON items(code) WHERE status != 'removed';
+CREATE INDEX idx_items_task_id ON items(task_id);
+CREATE INDEX idx_items_container_id ON items(container_id);
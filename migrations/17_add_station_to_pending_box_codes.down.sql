@@ -0,0 +1,3 @@
+-- migrations/17_add_station_to_pending_box_codes.down.sql
+DROP INDEX idx_pending_box_codes_task_station;
+ALTER TABLE pending_box_codes DROP COLUMN station;
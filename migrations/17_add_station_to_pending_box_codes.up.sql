@@ -0,0 +1,6 @@
+-- migrations/17_add_station_to_pending_box_codes.up.sql
+-- Несобранный короб хранится отдельно для линии и ручной станции.
+-- Ранее сохраненные коды относим к линии
+ALTER TABLE pending_box_codes ADD COLUMN station TEXT NOT NULL DEFAULT 'line';
+
+CREATE INDEX idx_pending_box_codes_task_station ON pending_box_codes(task_id, station);
@@ -0,0 +1,2 @@
+-- migrations/18_add_failed_at_to_outbox.down.sql
+ALTER TABLE outbox DROP COLUMN failed_at;
@@ -0,0 +1,4 @@
+-- migrations/18_add_failed_at_to_outbox.up.sql
+-- Время переноса сообщения в неотправленные: EZFactory его отклонил или исчерпаны попытки.
+-- NULL - сообщение в очереди. Неотправленные не задерживают очередь и возвращаются в нее оператором
+ALTER TABLE outbox ADD COLUMN failed_at TIMESTAMP;
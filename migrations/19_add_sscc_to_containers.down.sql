@@ -0,0 +1,3 @@
+-- migrations/19_add_sscc_to_containers.down.sql
+DROP INDEX IF EXISTS idx_containers_sscc;
+ALTER TABLE containers DROP COLUMN sscc;
@@ -0,0 +1,5 @@
+-- migrations/19_add_sscc_to_containers.up.sql
+-- SSCC короба для документа агрегации ГИС МТ: формируется из префикса компании линии и ID контейнера.
+-- NULL - короб закрыт до настройки префикса компании
+ALTER TABLE containers ADD COLUMN sscc TEXT;
+CREATE UNIQUE INDEX idx_containers_sscc ON containers(sscc) WHERE sscc IS NOT NULL;
@@ -29,7 +29,7 @@ func Page(content templ.Component) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"ru\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>EZLine - Управление производственной линией</title><script src=\"/static/js/htmx.min.js\"></script><link href=\"/static/css/tailwind.css\" rel=\"stylesheet\"><!-- Дополнительные стили --><style>\r\n            /* Дополнительные стили, если нужны */\r\n        </style></head><body class=\"bg-gray-100 min-h-screen\"><header class=\"bg-gray-800 text-white shadow-md\"><div class=\"container mx-auto p-4\"><div class=\"flex justify-between items-center\"><h1 class=\"text-2xl font-bold\">EZLine</h1><nav><ul class=\"flex space-x-4\"><li><a href=\"/\" class=\"hover:underline\">Главная</a></li><li><a href=\"/tasks\" class=\"hover:underline\">Задания</a></li></ul></nav></div></div></header><main class=\"container mx-auto p-4 mt-8\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"ru\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>EZLine - Управление производственной линией</title><script src=\"/static/js/htmx.min.js\"></script><link href=\"/static/css/tailwind.css\" rel=\"stylesheet\"><!-- Дополнительные стили --><style>\n            /* Дополнительные стили, если нужны */\n        </style></head><body class=\"bg-gray-100 min-h-screen\"><header class=\"bg-gray-800 text-white shadow-md\"><div class=\"container mx-auto p-4\"><div class=\"flex justify-between items-center\"><h1 class=\"text-2xl font-bold\">EZLine</h1><nav><ul class=\"flex space-x-4\"><li><a href=\"/\" class=\"hover:underline\">Главная</a></li><li><a href=\"/tasks\" class=\"hover:underline\">Задания</a></li><li><span hx-get=\"/outbox/badge\" hx-trigger=\"load\" hx-swap=\"outerHTML\"></span></li></ul></nav></div></div></header><main class=\"container mx-auto p-4 mt-8\"><div hx-get=\"/tasks/offline\" hx-trigger=\"load\" hx-swap=\"outerHTML\"></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}